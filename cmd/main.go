@@ -4,9 +4,14 @@ import (
 	"coze-agent-platform/config"
 	"coze-agent-platform/middleware"
 	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
 	"coze-agent-platform/routers"
+	"coze-agent-platform/search"
+	"coze-agent-platform/services"
 	"coze-agent-platform/utils"
+	"coze-agent-platform/utils/coze"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -47,6 +52,33 @@ func main() {
 	// 初始化Redis
 	utils.InitRedis()
 
+	// 注册对话后端
+	providers.Register(coze.NewProvider())
+	providers.Register(providers.NewOpenAIProvider())
+	providers.Register(providers.NewFakeProvider())
+
+	// 选择搜索后端
+	searchIndex, err := search.NewIndexFromEnv()
+	if err != nil {
+		log.Fatal("初始化搜索后端失败:", err)
+	}
+	search.SetIndex(searchIndex)
+
+	// 工作空间的Coze凭证从数据库加载
+	coze.SetWorkspaceConfigLoader(services.LoadWorkspaceCozeConfig)
+
+	// 注册内置工具
+	coze.RegisterBuiltinTools()
+
+	// 注册默认工作流
+	services.RegisterDefaultWorkflows()
+
+	// 启动异步工作流状态同步任务
+	services.StartWorkflowRunPoller(10 * time.Second)
+
+	// 启动对话消息同步任务
+	services.StartMessageSyncer(time.Minute)
+
 	// 设置Gin模式
 	if config.Cfg.App.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
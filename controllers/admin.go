@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var messageSyncService = services.NewMessageSyncService()
+
+// SyncConversationMessages 同步对话消息
+// @Summary 同步对话消息
+// @Description 将本地消息与Coze对话历史对齐：修正Coze消息ID、补录缺失消息，并标记内容不一致或Coze中不存在的消息（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/admin/conversations/{id}/sync [post]
+func SyncConversationMessages(c *gin.Context) {
+	conversationId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "对话ID格式错误")
+		return
+	}
+
+	result, err := messageSyncService.SyncConversationMessages(uint(conversationId))
+	if err != nil {
+		utils.ErrorWithCause(c, "同步对话消息失败: ", err)
+		return
+	}
+
+	utils.SuccessWithMessage(c, "同步完成", result)
+}
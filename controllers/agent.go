@@ -1,10 +1,14 @@
 package controllers
 
 import (
+	"coze-agent-platform/middleware"
 	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
 	"coze-agent-platform/services"
 	"coze-agent-platform/utils"
+	"errors"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,7 +18,11 @@ type CreateAgentRequest struct {
 	Description string `json:"description"`
 	Avatar      string `json:"avatar"`
 	Prompt      string `json:"prompt"`
-	Config      string `json:"config"`
+	Config      string `json:"config"` // JSON配置，variables声明对话时可传入的Bot变量
+
+	CozeBotID      string `json:"coze_bot_id"` // 绑定已有的Bot，只用于对话，不能发布；发布时创建的Bot不能更换
+	CozeWorkflowID string `json:"coze_workflow_id"`
+	Provider       string `json:"provider"` // 对话后端：coze、openai、fake，为空时使用coze
 }
 
 // CreateAgent 创建Agent
@@ -38,6 +46,15 @@ func CreateAgent(c *gin.Context) {
 		return
 	}
 
+	if err := validateAgentProvider(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err := validateAgentConfig(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
 	agent := &models.Agent{
 		Name:        req.Name,
 		Description: req.Description,
@@ -46,6 +63,13 @@ func CreateAgent(c *gin.Context) {
 		Config:      req.Config,
 		Status:      1,
 		UserID:      userId,
+
+		CozeWorkflowID: req.CozeWorkflowID,
+		Provider:       req.Provider,
+	}
+	if err := applyAgentBot(agent, req.CozeBotID); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
 	}
 
 	agentService := services.NewAgentService()
@@ -70,21 +94,7 @@ func CreateAgent(c *gin.Context) {
 // @Failure 404 {object} utils.Response
 // @Router /api/agents/{id} [get]
 func GetAgent(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "ID格式错误")
-		return
-	}
-
-	agentService := services.NewAgentService()
-	agent, err := agentService.GetAgentByID(uint(id))
-	if err != nil {
-		utils.NotFound(c, "Agent不存在")
-		return
-	}
-
-	utils.Success(c, agent)
+	utils.Success(c, authorizedAgent(c))
 }
 
 // ListAgents 获取Agent列表
@@ -105,22 +115,21 @@ func ListAgents(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
 
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 10
+	}
+
 	agentService := services.NewAgentService()
-	agents, total, err := agentService.ListAgents(page, size)
+	agents, total, err := agentService.ListAgentsByUserID(userId, page, size)
 	if err != nil {
 		utils.InternalServerError(c, "查询失败")
 		return
 	}
 
-	// 过滤当前用户的Agent
-	var userAgents []*models.Agent
-	for _, agent := range agents {
-		if agent.UserID == userId {
-			userAgents = append(userAgents, agent)
-		}
-	}
-
-	utils.PageSuccess(c, userAgents, total, page, size)
+	utils.PageSuccess(c, agents, total, page, size)
 }
 
 // UpdateAgent 更新Agent
@@ -137,39 +146,39 @@ func ListAgents(c *gin.Context) {
 // @Failure 404 {object} utils.Response
 // @Router /api/agents/{id} [put]
 func UpdateAgent(c *gin.Context) {
-	userId := c.GetUint("user_id")
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "ID格式错误")
-		return
-	}
-
 	var req CreateAgentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误："+err.Error())
 		return
 	}
 
-	agentService := services.NewAgentService()
-	agent, err := agentService.GetAgentByID(uint(id))
-	if err != nil {
-		utils.NotFound(c, "Agent不存在")
+	if err := validateAgentProvider(&req); err != nil {
+		utils.BadRequest(c, err.Error())
 		return
 	}
-
-	// 检查权限
-	if agent.UserID != userId {
-		utils.Unauthorized(c, "无权限操作")
+	if err := validateAgentConfig(&req); err != nil {
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
+	agentService := services.NewAgentService()
+	agent := authorizedAgent(c)
+
 	// 更新Agent信息
 	agent.Name = req.Name
 	agent.Description = req.Description
 	agent.Avatar = req.Avatar
 	agent.Prompt = req.Prompt
 	agent.Config = req.Config
+	if err := applyAgentBot(agent, req.CozeBotID); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	agent.CozeWorkflowID = req.CozeWorkflowID
+	agent.Provider = req.Provider
+	if agent.PublishStatus == models.AgentPublishPublished {
+		agent.PublishStatus = models.AgentPublishModified
+	}
 
 	if err := agentService.UpdateAgent(agent); err != nil {
 		utils.InternalServerError(c, "更新失败")
@@ -192,31 +201,116 @@ func UpdateAgent(c *gin.Context) {
 // @Failure 404 {object} utils.Response
 // @Router /api/agents/{id} [delete]
 func DeleteAgent(c *gin.Context) {
-	userId := c.GetUint("user_id")
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "ID格式错误")
+	agent := authorizedAgent(c)
+
+	if err := services.NewAgentService().DeleteAgent(agent.ID); err != nil {
+		utils.InternalServerError(c, "删除失败")
 		return
 	}
 
-	agentService := services.NewAgentService()
-	agent, err := agentService.GetAgentByID(uint(id))
-	if err != nil {
-		utils.NotFound(c, "Agent不存在")
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// PublishAgent 发布Agent到Coze
+// @Summary 发布Agent到Coze
+// @Description 将Agent的名称、描述、提示词、模型设置和知识库绑定同步到Coze Bot并发布到API渠道，未绑定Bot时先在Coze中创建
+// @Tags Agent
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "Agent ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/agents/{id}/publish [post]
+func PublishAgent(c *gin.Context) {
+	agent := authorizedAgent(c)
+
+	if err := services.NewAgentPublishService().PublishAgent(agent); err != nil {
+		utils.ErrorWithCause(c, "发布失败: ", err)
 		return
 	}
 
-	// 检查权限
-	if agent.UserID != userId {
-		utils.Unauthorized(c, "无权限操作")
+	utils.SuccessWithMessage(c, "发布成功", agent)
+}
+
+// GetAgentDrift 获取Agent与Coze Bot的差异
+// @Summary 获取Agent与Coze Bot的差异
+// @Description 比较Agent本地定义与Coze Bot已发布的定义，列出不一致的字段
+// @Tags Agent
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "Agent ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/agents/{id}/drift [get]
+func GetAgentDrift(c *gin.Context) {
+	agent := authorizedAgent(c)
+
+	drift, err := services.NewAgentPublishService().GetAgentDrift(agent)
+	if err != nil {
+		utils.ErrorWithCause(c, "", err)
 		return
 	}
 
-	if err := agentService.DeleteAgent(uint(id)); err != nil {
-		utils.InternalServerError(c, "删除失败")
-		return
+	utils.Success(c, drift)
+}
+
+// 辅助函数：获取 AgentAccess 中间件加载并授权的Agent
+func authorizedAgent(c *gin.Context) *models.Agent {
+	return c.MustGet(middleware.ContextAgent).(*models.Agent)
+}
+
+// 辅助函数：校验当前用户可以使用指定的Agent，agentId为0时不校验，失败时已写入响应
+func checkAgentAccess(c *gin.Context, agentId uint) bool {
+	if err := services.CanUseAgentId(middleware.GetActor(c), agentId); err != nil {
+		middleware.AbortWithAccessError(c, err)
+		return false
 	}
+	return true
+}
 
-	utils.SuccessWithMessage(c, "删除成功", nil)
+// 辅助函数：设置Agent绑定的Coze Bot。平台创建的Bot由发布流程管理，不能更换；
+// 同一个Bot只能绑定到一个Agent，避免通过发布覆盖其他Agent的Bot
+func applyAgentBot(agent *models.Agent, botId string) error {
+	botId = strings.TrimSpace(botId)
+	if agent.CozeBotManaged {
+		if botId != "" && botId != agent.CozeBotID {
+			return errors.New("Agent的Bot由发布时创建，不能更换")
+		}
+		return nil
+	}
+
+	if botId != "" && botId != agent.CozeBotID {
+		bound, err := services.NewAgentService().IsCozeBotBound(botId, agent.ID)
+		if err != nil {
+			return err
+		}
+		if bound {
+			return errors.New("该Coze Bot已绑定到其他Agent")
+		}
+	}
+	agent.CozeBotID = botId
+	return nil
+}
+
+// 辅助函数：校验Agent选择的对话后端，未指定时使用默认后端
+func validateAgentProvider(req *CreateAgentRequest) error {
+	if req.Provider == "" {
+		req.Provider = providers.DefaultProvider
+		return nil
+	}
+	_, err := providers.Get(req.Provider)
+	return err
+}
+
+// 辅助函数：校验Agent配置中的变量声明
+func validateAgentConfig(req *CreateAgentRequest) error {
+	config, err := (&models.Agent{Config: req.Config}).ParseConfig()
+	if err != nil {
+		return err
+	}
+	return config.Validate()
 }
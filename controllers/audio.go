@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"coze-agent-platform/utils/coze"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 语音识别的音频文件大小上限
+const maxTranscriptionFileSize = 10 << 20
+
+// 语音识别支持的音频文件扩展名
+var transcriptionExtensions = map[string]bool{
+	".wav": true, ".mp3": true, ".ogg": true, ".m4a": true, ".aac": true, ".pcm": true,
+}
+
+type SpeechRequest struct {
+	Input   string  `json:"input" binding:"required"`
+	VoiceID string  `json:"voice_id"` // 为空时使用 COZE_VOICE_ID 环境变量
+	Format  string  `json:"format"`   // mp3、wav、pcm、ogg_opus、m4a、aac，默认mp3
+	Speed   float32 `json:"speed"`    // 语速，0.2~3，默认1
+}
+
+// CreateTranscription 语音转文字
+// @Summary 语音转文字
+// @Description 上传音频文件（wav、mp3、ogg、m4a、aac、pcm，不超过10MB），返回识别出的文本
+// @Tags 语音
+// @Accept multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file true "音频文件"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/audio/transcriptions [post]
+func CreateTranscription(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "获取文件失败: "+err.Error())
+		return
+	}
+
+	if fileHeader.Size > maxTranscriptionFileSize {
+		utils.BadRequest(c, "音频文件不能超过10MB")
+		return
+	}
+	if !transcriptionExtensions[strings.ToLower(filepath.Ext(fileHeader.Filename))] {
+		utils.BadRequest(c, "不支持的音频格式")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.BadRequest(c, "打开文件失败: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	cozeClient, err := services.GetUserCozeClient(c.GetUint("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "创建Coze客户端失败: "+err.Error())
+		return
+	}
+
+	text, err := cozeClient.Transcribe(fileHeader.Filename, file)
+	if err != nil {
+		utils.ErrorWithCause(c, "", err)
+		return
+	}
+
+	utils.Success(c, gin.H{"text": text})
+}
+
+// CreateSpeech 文字转语音
+// @Summary 文字转语音
+// @Description 将文本合成为语音，直接返回音频数据流
+// @Tags 语音
+// @Accept json
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param request body SpeechRequest true "合成参数"
+// @Success 200 {file} binary
+// @Failure 400 {object} utils.Response
+// @Router /api/audio/speech [post]
+func CreateSpeech(c *gin.Context) {
+	var req SpeechRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误："+err.Error())
+		return
+	}
+
+	if len(req.Input) > coze.MaxSpeechInputBytes {
+		utils.BadRequest(c, "合成文本不能超过"+strconv.Itoa(coze.MaxSpeechInputBytes)+"字节")
+		return
+	}
+	if req.Speed != 0 && (req.Speed < 0.2 || req.Speed > 3) {
+		utils.BadRequest(c, "语速需在0.2到3之间")
+		return
+	}
+
+	voiceId, format, contentType, err := resolveSpeechOptions(req.VoiceID, req.Format)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	cozeClient, err := services.GetUserCozeClient(c.GetUint("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "创建Coze客户端失败: "+err.Error())
+		return
+	}
+
+	audio, err := cozeClient.Speech(c.Request.Context(), req.Input, voiceId, format, req.Speed)
+	if err != nil {
+		utils.ErrorWithCause(c, "", err)
+		return
+	}
+	defer audio.Close()
+
+	c.DataFromReader(200, -1, contentType, audio, nil)
+}
+
+// ListVoices 获取音色列表
+// @Summary 获取音色列表
+// @Description 获取可用于语音合成的系统音色及克隆音色
+// @Tags 语音
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param filter_system_voice query bool false "是否过滤系统音色"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(20)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/audio/voices [get]
+func ListVoices(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	filterSystemVoice, _ := strconv.ParseBool(c.DefaultQuery("filter_system_voice", "false"))
+
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+
+	cozeClient, err := services.GetUserCozeClient(c.GetUint("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "创建Coze客户端失败: "+err.Error())
+		return
+	}
+
+	voices, hasMore, err := cozeClient.ListVoices(filterSystemVoice, page, size)
+	if err != nil {
+		utils.ErrorWithCause(c, "", err)
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":     voices,
+		"page":     page,
+		"size":     size,
+		"has_more": hasMore,
+	})
+}
+
+// 辅助函数：补全音色和音频格式的默认值，返回音频对应的Content-Type
+func resolveSpeechOptions(voiceId string, format string) (string, string, string, error) {
+	if voiceId == "" {
+		voiceId = coze.DefaultVoiceID()
+	}
+	if voiceId == "" {
+		return "", "", "", errors.New("缺少音色ID")
+	}
+
+	if format == "" {
+		format = "mp3"
+	}
+	contentType, ok := coze.SpeechContentType(format)
+	if !ok {
+		return "", "", "", errors.New("不支持的音频格式: " + format)
+	}
+
+	return voiceId, format, contentType, nil
+}
@@ -59,7 +59,7 @@ func Login(c *gin.Context) {
 	}
 
 	// 生成JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, config.Cfg.JWT.Secret, config.Cfg.JWT.Expire)
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, config.Cfg.JWT.Secret, config.Cfg.JWT.Expire)
 	if err != nil {
 		utils.InternalServerError(c, "Token生成失败")
 		return
@@ -116,7 +116,7 @@ func Register(c *gin.Context) {
 		Password: string(hashedPassword),
 		Nickname: req.Nickname,
 		Status:   1,
-		Role:     1,
+		Role:     models.UserRoleNormal,
 	}
 
 	if err := userService.CreateUser(user); err != nil {
@@ -125,7 +125,7 @@ func Register(c *gin.Context) {
 	}
 
 	// 生成JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, config.Cfg.JWT.Secret, config.Cfg.JWT.Expire)
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, config.Cfg.JWT.Secret, config.Cfg.JWT.Expire)
 	if err != nil {
 		utils.InternalServerError(c, "Token生成失败")
 		return
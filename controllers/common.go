@@ -1,8 +1,8 @@
 package controllers
 
 import (
+	"coze-agent-platform/services"
 	"coze-agent-platform/utils"
-	"coze-agent-platform/utils/coze"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,17 +23,17 @@ func UploadFile(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// 创建 Coze 客户端
-	cozeClient, err := coze.New()
+	// 获取默认对话后端
+	provider, err := services.GetUserProvider("", c.GetUint("user_id"))
 	if err != nil {
-		utils.BadRequest(c, "创建Coze客户端失败: "+err.Error())
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	// 上传文件到 Coze
-	fileID, err := cozeClient.Upload(file)
+	// 上传文件到对话后端
+	fileID, err := provider.UploadFile(file)
 	if err != nil {
-		utils.BadRequest(c, "上传文件到Coze失败: "+err.Error())
+		utils.ErrorWithCause(c, "上传文件失败: ", err)
 		return
 	}
 
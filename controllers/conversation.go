@@ -1,24 +1,61 @@
 package controllers
 
 import (
+	"context"
+	"coze-agent-platform/middleware"
 	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
 	"coze-agent-platform/services"
 	"coze-agent-platform/utils"
 	"coze-agent-platform/utils/coze"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CreateConversationRequest struct {
-	Title string `json:"title"`
+	Title      string `json:"title"`
+	AgentID    uint   `json:"agent_id"`
+	WorkflowID string `json:"workflow_id"` // 绑定对话流（Chatflow），为空时与Bot对话
 }
 
+// UpdateConversationRequest 只更新请求中出现的字段
+type UpdateConversationRequest struct {
+	Title    *string   `json:"title"`
+	Pinned   *bool     `json:"pinned"`
+	Archived *bool     `json:"archived"`
+	FolderID *uint     `json:"folder_id"` // 为0时移出文件夹
+	Tags     *[]string `json:"tags"`      // 替换全部标签，传空数组清空标签
+}
+
+// 对话标签限制
+const (
+	maxConversationTags      = 10
+	maxConversationTagLength = 20
+)
+
 type SendMessageRequest struct {
-	Content string `json:"content" binding:"required"`
+	Content    string               `json:"content"`     // 文本内容，与parts至少提供一个
+	Parts      []models.MessagePart `json:"parts"`       // 多模态内容片段：text、image、file
+	AgentID    uint                 `json:"agent_id"`    // 新建对话时使用的Agent
+	WorkflowID string               `json:"workflow_id"` // 新建对话时绑定的对话流
+	Speech     *ReplySpeechOptions  `json:"speech"`      // 仅流式接口：将最终回复合成语音
+
+	CustomVariables map[string]string `json:"custom_variables"` // Bot变量，需在Agent配置中声明
+	MetaData        map[string]string `json:"meta_data"`        // 附加元数据，用户信息字段由服务端填充
+}
+
+// ReplySpeechOptions 回复语音合成选项
+type ReplySpeechOptions struct {
+	VoiceID string `json:"voice_id"` // 为空时使用 COZE_VOICE_ID 环境变量
+	Format  string `json:"format"`   // 默认mp3
 }
 
 var (
@@ -28,11 +65,15 @@ var (
 
 // ListConversations 获取对话列表
 // @Summary 获取对话列表
-// @Description 获取当前用户的对话列表
+// @Description 获取当前用户的对话列表，置顶的对话排在前面。默认只返回未归档的对话
 // @Tags 对话
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
+// @Param archived query bool false "为true时只返回已归档的对话"
+// @Param tag query string false "按标签筛选"
+// @Param folder_id query int false "按文件夹筛选，为0时返回不在文件夹中的对话"
+// @Param agent_id query int false "按Agent筛选"
 // @Param page query int false "页码" default(1)
 // @Param size query int false "每页数量" default(10)
 // @Success 200 {object} utils.PageResponse
@@ -57,28 +98,20 @@ func ListConversations(c *gin.Context) {
 		size = 10
 	}
 
-	// 获取用户的对话列表
-	conversations, err := conversationService.GetConversationsByUserId(userID.(uint))
+	filter, err := parseConversationFilter(c)
 	if err != nil {
-		utils.InternalServerError(c, "获取对话列表失败: "+err.Error())
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	// 简单分页
-	total := len(conversations)
-	start := (page - 1) * size
-	end := start + size
-
-	if start >= total {
-		conversations = []*models.Conversation{}
-	} else {
-		if end > total {
-			end = total
-		}
-		conversations = conversations[start:end]
+	// 获取用户的对话列表
+	conversations, total, err := conversationService.GetConversationsByUserId(userID.(uint), filter, page, size)
+	if err != nil {
+		utils.InternalServerError(c, "获取对话列表失败: "+err.Error())
+		return
 	}
 
-	utils.PageSuccess(c, conversations, int64(total), page, size)
+	utils.PageSuccess(c, conversations, total, page, size)
 }
 
 // CreateConversation 创建对话
@@ -106,14 +139,24 @@ func CreateConversation(c *gin.Context) {
 		utils.BadRequest(c, "参数格式错误: "+err.Error())
 		return
 	}
+	if !checkAgentAccess(c, req.AgentID) {
+		return
+	}
 
 	// 创建数据库记录
 	conversation := &models.Conversation{
-		UserId: userID.(uint),
-		Title:  req.Title,
+		UserId:     userID.(uint),
+		AgentId:    req.AgentID,
+		Title:      req.Title,
+		WorkflowId: req.WorkflowID,
+		Tags:       []string{},
 	}
 
 	if err := conversationService.CreateConversation(conversation); err != nil {
+		if _, ok := utils.AsUpstreamError(err); ok {
+			utils.ErrorWithCause(c, "", err)
+			return
+		}
 		utils.InternalServerError(c, "保存对话失败: "+err.Error())
 		return
 	}
@@ -131,18 +174,100 @@ func CreateConversation(c *gin.Context) {
 // @Param id path int true "对话ID"
 // @Success 200 {object} utils.Response
 // @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
 // @Failure 404 {object} utils.Response
 // @Router /api/conversations/{id} [get]
 func GetConversation(c *gin.Context) {
-	conversationId, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "对话ID格式错误")
+	conversation := authorizedConversation(c)
+
+	if err := conversationService.LoadConversationTags(conversation); err != nil {
+		utils.InternalServerError(c, "获取对话标签失败: "+err.Error())
 		return
 	}
 
-	conversation, err := conversationService.GetConversationById(uint(conversationId))
-	if err != nil {
-		utils.NotFound(c, err.Error())
+	utils.Success(c, conversation)
+}
+
+// UpdateConversation 更新对话
+// @Summary 更新对话
+// @Description 重命名、置顶、归档对话，设置标签或移动到文件夹，只更新请求中出现的字段
+// @Tags 对话
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param request body UpdateConversationRequest true "更新内容"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id} [patch]
+func UpdateConversation(c *gin.Context) {
+	conversation := authorizedConversation(c)
+
+	var req UpdateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			utils.BadRequest(c, "标题不能为空")
+			return
+		}
+		if len([]rune(title)) > 100 {
+			utils.BadRequest(c, "标题不能超过100个字符")
+			return
+		}
+		conversation.Title = title
+	}
+	if req.Pinned != nil && *req.Pinned != conversation.Pinned {
+		conversation.Pinned = *req.Pinned
+		conversation.PinnedAt = nil
+		if conversation.Pinned {
+			now := time.Now()
+			conversation.PinnedAt = &now
+		}
+	}
+	if req.Archived != nil {
+		conversation.Archived = *req.Archived
+	}
+	if req.FolderID != nil {
+		if *req.FolderID != 0 {
+			// 文件夹须属于对话所有者
+			folder, err := conversationFolderService.GetFolderById(*req.FolderID)
+			if err != nil || folder.UserId != conversation.UserId {
+				utils.BadRequest(c, "文件夹不存在")
+				return
+			}
+		}
+		conversation.FolderId = *req.FolderID
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		var err error
+		if tags, err = normalizeLabels(*req.Tags, maxConversationTags, maxConversationTagLength, "标签"); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	if err := conversationService.UpdateConversation(conversation); err != nil {
+		utils.InternalServerError(c, "更新对话失败: "+err.Error())
+		return
+	}
+
+	if req.Tags != nil {
+		err := conversationService.SetConversationTags(conversation, tags)
+		if err != nil {
+			utils.InternalServerError(c, "更新对话标签失败: "+err.Error())
+			return
+		}
+	} else if err := conversationService.LoadConversationTags(conversation); err != nil {
+		utils.InternalServerError(c, "获取对话标签失败: "+err.Error())
 		return
 	}
 
@@ -159,16 +284,13 @@ func GetConversation(c *gin.Context) {
 // @Param id path int true "对话ID"
 // @Success 200 {object} utils.Response
 // @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
 // @Failure 404 {object} utils.Response
 // @Router /api/conversations/{id} [delete]
 func DeleteConversation(c *gin.Context) {
-	conversationId, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "对话ID格式错误")
-		return
-	}
+	conversation := authorizedConversation(c)
 
-	if err := conversationService.DeleteConversation(uint(conversationId)); err != nil {
+	if err := conversationService.DeleteConversation(conversation.ID); err != nil {
 		utils.InternalServerError(c, "删除对话失败: "+err.Error())
 		return
 	}
@@ -178,7 +300,8 @@ func DeleteConversation(c *gin.Context) {
 
 // GetMessages 获取消息列表
 // @Summary 获取消息列表
-// @Description 获取指定对话的消息列表
+// @Description 获取指定对话的消息列表，按时间正序排列。默认只返回当前分支上的消息，branch=all 时返回全部分支的消息。
+// @Description 传入 before 或 after 时使用游标分页：before 返回该消息之前的消息（为0时返回最新的消息），after 返回该消息之后的消息，响应中的 first_id/last_id 作为下一次请求的游标；否则按页码分页
 // @Tags 消息
 // @Accept json
 // @Produce json
@@ -186,16 +309,16 @@ func DeleteConversation(c *gin.Context) {
 // @Param id path int true "对话ID"
 // @Param page query int false "页码" default(1)
 // @Param size query int false "每页数量" default(20)
+// @Param before query int false "游标：返回该消息ID之前的消息"
+// @Param after query int false "游标：返回该消息ID之后的消息"
+// @Param branch query string false "传入 all 时返回全部分支的消息"
 // @Success 200 {object} utils.PageResponse
 // @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
 // @Failure 404 {object} utils.Response
 // @Router /api/conversations/{id}/messages [get]
 func GetMessages(c *gin.Context) {
-	conversationId, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "对话ID格式错误")
-		return
-	}
+	conversationId := authorizedConversation(c).ID
 
 	// 获取分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -208,14 +331,56 @@ func GetMessages(c *gin.Context) {
 		size = 20
 	}
 
+	branch := c.Query("branch")
+	if branch != "" && branch != "all" {
+		utils.BadRequest(c, "branch只支持all")
+		return
+	}
+	allBranches := branch == "all"
+
+	before, hasBefore := c.GetQuery("before")
+	after, hasAfter := c.GetQuery("after")
+	if hasBefore || hasAfter {
+		if hasBefore && hasAfter {
+			utils.BadRequest(c, "before和after不能同时使用")
+			return
+		}
+
+		var beforeId, afterId uint64
+		var err error
+		if hasBefore {
+			beforeId, err = strconv.ParseUint(before, 10, 32)
+		} else {
+			afterId, err = strconv.ParseUint(after, 10, 32)
+		}
+		if err != nil {
+			utils.BadRequest(c, "游标格式错误")
+			return
+		}
+
+		messages, hasMore, err := messageService.GetMessagesByCursor(conversationId, allBranches, uint(beforeId), uint(afterId), size)
+		if err != nil {
+			utils.InternalServerError(c, "获取消息列表失败: "+err.Error())
+			return
+		}
+
+		var firstId, lastId uint
+		if len(messages) > 0 {
+			firstId = messages[0].ID
+			lastId = messages[len(messages)-1].ID
+		}
+		utils.CursorSuccess(c, messages, hasMore, firstId, lastId)
+		return
+	}
+
 	// 获取消息列表
-	messages, err := messageService.GetMessagesByConversationId(uint(conversationId), size)
+	messages, total, err := messageService.GetMessagesPageByConversationId(conversationId, allBranches, page, size)
 	if err != nil {
 		utils.InternalServerError(c, "获取消息列表失败: "+err.Error())
 		return
 	}
 
-	utils.PageSuccess(c, messages, int64(len(messages)), page, size)
+	utils.PageSuccess(c, messages, total, page, size)
 }
 
 // SendMessage 发送消息
@@ -229,19 +394,11 @@ func GetMessages(c *gin.Context) {
 // @Param request body SendMessageRequest true "消息内容"
 // @Success 200 {object} utils.Response
 // @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
 // @Failure 404 {object} utils.Response
 // @Router /api/conversations/{id}/messages [post]
 func SendMessage(c *gin.Context) {
-	conversationIdStr := c.DefaultQuery("conversation_id", "0")
-	if conversationIdStr == "" {
-		conversationIdStr = "0"
-	}
-
-	conversationId, err := strconv.ParseUint(conversationIdStr, 10, 32)
-	if err != nil {
-		// 如果解析失败，默认为0
-		conversationId = 0
-	}
+	conversation := authorizedConversation(c)
 
 	var req SendMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -249,60 +406,73 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	// 获取对话信息
-	conversation, err := conversationService.GetConversationById(uint(conversationId))
+	userMessage, err := buildUserMessage(&req)
 	if err != nil {
-		utils.NotFound(c, err.Error())
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	// 获取最近20条历史消息
-	historyMessages, err := messageService.GetRecentMessages(uint(conversationId), 20)
+	// 获取当前分支上最近20条历史消息
+	historyMessages, err := messageService.GetRecentMessages(conversation.ID, 20)
 	if err != nil {
 		utils.InternalServerError(c, "获取历史消息失败: "+err.Error())
 		return
 	}
 
-	// 发送消息到Coze
-	cozeConv, err := coze.New()
+	// 发送消息到Agent选择的对话后端
+	provider, botId, err := services.GetConversationProvider(conversation)
 	if err != nil {
-		utils.BadRequest(c, "初始化Coze对话失败: "+err.Error())
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	cozeResp, err := cozeConv.SendMessage(conversation.CozeConversationID, req.Content)
+	customVariables, metaData, err := buildChatOptions(conversation, &req)
 	if err != nil {
-		utils.BadRequest(c, "发送消息失败: "+err.Error())
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	// 保存用户消息到数据库
-	userMessage := &models.Message{
-		CozeMessageId:  generateMessageId(),
-		ConversationId: uint(conversationId),
-		ModelId:        1,
-		Role:           "user",
-		Content:        req.Content,
-		Tokens:         0,
+	userMessage.ConversationId = conversation.ID
+	if len(historyMessages) > 0 {
+		userMessage.ParentId = historyMessages[len(historyMessages)-1].ID
+	}
+
+	chatResult, err := provider.Chat(&providers.ChatRequest{
+		BotID:           botId,
+		ConversationID:  conversation.CozeConversationID,
+		WorkflowID:      conversation.WorkflowId,
+		UserID:          conversation.UserId,
+		Messages:        append(historyMessages, userMessage),
+		CustomVariables: customVariables,
+		MetaData:        metaData,
+	})
+	if err != nil {
+		utils.ErrorWithCause(c, "发送消息失败: ", err)
+		return
 	}
 
-	if err := messageService.CreateMessage(userMessage); err != nil {
+	// 保存用户消息到数据库，追加到当前分支
+	userMessage.CozeConversationId = conversation.CozeConversationID
+	if err := messageService.AppendMessage(userMessage); err != nil {
 		utils.InternalServerError(c, "保存用户消息失败: "+err.Error())
 		return
 	}
 
 	// 保存AI回复到数据库
-	if cozeResp.Message.ID != "" {
+	if chatResult.Content != "" {
 		aiMessage := &models.Message{
-			CozeMessageId:  cozeResp.Message.ID,
-			ConversationId: uint(conversationId),
-			ModelId:        1,
-			Role:           "assistant",
-			Content:        getMessageContent(cozeResp.Message),
-			Tokens:         0,
+			CozeMessageId:      chatResult.MessageID,
+			ChatId:             chatResult.ChatID,
+			CozeConversationId: conversation.CozeConversationID,
+			ConversationId:     conversation.ID,
+			ParentId:           userMessage.ID,
+			ModelId:            1,
+			Role:               "assistant",
+			Content:            chatResult.Content,
+			Tokens:             chatResult.Usage.TokenCount,
 		}
 
-		if err := messageService.CreateMessage(aiMessage); err != nil {
+		if err := messageService.AppendMessage(aiMessage); err != nil {
 			utils.InternalServerError(c, "保存AI回复失败: "+err.Error())
 			return
 		}
@@ -310,7 +480,7 @@ func SendMessage(c *gin.Context) {
 
 	// 构建返回数据
 	responseData := map[string]interface{}{
-		"response":      cozeResp,
+		"response":      chatResult,
 		"history_count": len(historyMessages),
 		"user_message":  userMessage,
 	}
@@ -325,11 +495,11 @@ func SendMessage(c *gin.Context) {
 // @Accept json
 // @Produce text/event-stream
 // @Security ApiKeyAuth
-// @Param id path string true "对话ID"
-// @Param user_id query string true "用户ID"
+// @Param conversation_id query int false "对话ID，为空时新建对话"
 // @Param request body SendMessageRequest true "消息内容"
 // @Success 200 {string} string "SSE 流式响应"
 // @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
 // @Failure 404 {object} utils.Response
 // @Router /api/conversations/messages/stream [post]
 func SendMessageStream(c *gin.Context) {
@@ -350,39 +520,49 @@ func SendMessageStream(c *gin.Context) {
 		return
 	}
 
-	userIdStr := c.DefaultQuery("user_id", "0")
-	if userIdStr == "" {
-		userIdStr = "0"
+	userMessage, err := buildUserMessage(&req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
 	}
 
-	userID, err := strconv.ParseUint(userIdStr, 10, 32)
+	speech, err := buildReplySpeech(req.Speech)
 	if err != nil {
-		// 如果解析失败，默认为0
-		userID = 0
+		utils.BadRequest(c, err.Error())
+		return
 	}
 
+	actor := middleware.GetActor(c)
+
 	var conversation *models.Conversation
 	var historyMessageList []*models.Message
 
 	if conversationId == 0 {
-		conversation = &models.Conversation{}
-		conversation.UserId = uint(userID)
-		conversation.Title = strings.SplitN(req.Content, "\n", 2)[0]
-		err = conversationService.CreateConversation(conversation)
-		if err != nil {
-			utils.InternalServerError(c, "创建对话失败: "+err.Error())
+		if !checkAgentAccess(c, req.AgentID) {
 			return
 		}
+
+		conversation = &models.Conversation{}
+		conversation.UserId = actor.UserID
+		conversation.AgentId = req.AgentID
+		conversation.WorkflowId = req.WorkflowID
+		conversation.Title = strings.SplitN(userMessage.Content, "\n", 2)[0]
+		if conversation.Title == "" {
+			conversation.Title = "新对话"
+		}
 	} else {
 		// 获取对话信息
 		conversation, err = conversationService.GetConversationById(uint(conversationId))
+		if err == nil {
+			err = services.CanAccessConversation(actor, conversation)
+		}
 		if err != nil {
-			utils.NotFound(c, err.Error())
+			middleware.AbortWithAccessError(c, err)
 			return
 		}
 
 		fmt.Println(conversation.ID)
-		// 获取最近20条历史消息
+		// 获取当前分支上最近20条历史消息
 		historyMessageList, err = messageService.GetRecentMessages(conversation.ID, 20)
 		if err != nil {
 			utils.InternalServerError(c, "获取历史消息失败: "+err.Error())
@@ -392,38 +572,41 @@ func SendMessageStream(c *gin.Context) {
 
 	fmt.Println(historyMessageList)
 
-	// 设置 SSE 头部
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Cache-Control")
+	// 校验变量后再创建新对话，避免留下空对话
+	customVariables, metaData, err := buildChatOptions(conversation, &req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
 
-	// 检查客户端是否断开连接
-	clientGone := c.Request.Context().Done()
+	if conversation.ID == 0 {
+		err = conversationService.CreateConversation(conversation)
+		if err != nil {
+			if _, ok := utils.AsUpstreamError(err); ok {
+				utils.ErrorWithCause(c, "", err)
+				return
+			}
+			utils.InternalServerError(c, "创建对话失败: "+err.Error())
+			return
+		}
+	}
 
-	cozeConv, err := coze.New()
+	provider, botId, err := services.GetConversationProvider(conversation)
 	if err != nil {
-		// SSE 错误格式
-		c.SSEvent("error", map[string]interface{}{
-			"error":   true,
-			"message": err.Error(),
-		})
-		c.Writer.Flush()
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	// 先保存用户消息
-	userMessage := &models.Message{
-		CozeMessageId:  generateMessageId(),
-		ConversationId: conversation.ID,
-		ModelId:        1,
-		Role:           "user",
-		Content:        req.Content,
-		Tokens:         0,
+	setSSEHeaders(c)
+
+	// 先保存用户消息，追加到当前分支
+	userMessage.ConversationId = conversation.ID
+	userMessage.CozeConversationId = conversation.CozeConversationID
+	if len(historyMessageList) > 0 {
+		userMessage.ParentId = historyMessageList[len(historyMessageList)-1].ID
 	}
 
-	if err := messageService.CreateMessage(userMessage); err != nil {
+	if err := messageService.AppendMessage(userMessage); err != nil {
 		c.SSEvent("error", map[string]interface{}{
 			"error":   true,
 			"message": "保存用户消息失败: " + err.Error(),
@@ -432,242 +615,452 @@ func SendMessageStream(c *gin.Context) {
 		return
 	}
 
-	var aiMessageContent strings.Builder
-	var aiMessageId string
-	var aiMessageTokens int
 	historyMessageList = append(historyMessageList, userMessage)
 	fmt.Println(historyMessageList)
 
-	// 定义流式回调函数
-	onMessage := func(eventType string, data interface{}) {
-		select {
-		case <-clientGone:
-			return // 客户端已断开连接
-		default:
-			eventData := map[string]interface{}{
-				"type": eventType,
-				"data": data,
-			}
+	streamReply(c, conversation, provider, &providers.ChatRequest{
+		BotID:           botId,
+		ConversationID:  conversation.CozeConversationID,
+		WorkflowID:      conversation.WorkflowId,
+		UserID:          conversation.UserId,
+		Messages:        historyMessageList,
+		CustomVariables: customVariables,
+		MetaData:        metaData,
+	}, userMessage.ID, speech)
+}
 
-			// 处理消息增量更新
-			if eventType == "message_delta" {
-				if msgData, ok := data.(map[string]interface{}); ok {
-					if content, ok := msgData["content"].(string); ok {
-						aiMessageContent.WriteString(content)
-					}
-				}
-			}
+// CancelChat 取消进行中的对话
+// @Summary 取消进行中的对话
+// @Description 调用对话后端的取消接口停止生成，停止服务端的流式读取，将已生成的部分回复保存为已取消状态，并向客户端发送 cancelled 事件
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param chat_id path string true "chat_created 事件返回的chat_id"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id}/chats/{chat_id}/cancel [post]
+func CancelChat(c *gin.Context) {
+	conversation := authorizedConversation(c)
+	chatId := c.Param("chat_id")
+
+	active, ok := services.GetActiveChat(chatId)
+	if ok && active.ConversationId != conversation.ID {
+		utils.BadRequest(c, "对话不属于该会话")
+		return
+	}
 
-			// 处理对话完成
-			if eventType == "chat_completed" {
-				if msgData, ok := data.(map[string]interface{}); ok {
-					if chatId, ok := msgData["chat_id"].(string); ok {
-						aiMessageId = chatId
-					}
-
-					if usage, ok := msgData["usage"].(map[string]interface{}); ok {
-						for _, token := range usage {
-							aiMessageTokens += token.(int)
-						}
-					}
-				}
-
-				// 保存AI回复消息
-				if aiMessageContent.Len() > 0 {
-					aiMessage := &models.Message{
-						CozeMessageId:  aiMessageId,
-						ConversationId: conversation.ID,
-						ModelId:        1,
-						Role:           "assistant",
-						Content:        aiMessageContent.String(),
-						Tokens:         aiMessageTokens,
-					}
-
-					if err := messageService.CreateMessage(aiMessage); err != nil {
-						// 错误处理，但不中断流式响应
-						fmt.Printf("保存AI回复失败: %v\n", err)
-					}
-				}
-			}
+	provider, _, err := services.GetConversationProvider(conversation)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
 
-			c.SSEvent("message", eventData)
-			c.Writer.Flush()
+	// 先取消后端生成，避免继续消耗token
+	if err := provider.CancelChat(conversation.CozeConversationID, chatId); err != nil {
+		if !ok {
+			utils.ErrorWithCause(c, "", err)
+			return
 		}
+		// 本实例仍在读取流，继续停止本地流
+		fmt.Printf("取消对话失败: %v\n", err)
 	}
 
-	err = cozeConv.SendMessageStreamWithCallback(conversation.CozeConversationID, conversation.UserId, historyMessageList, onMessage)
-	if err != nil {
-		onMessage("error", map[string]string{"message": err.Error()})
+	// 流式响应不在本实例时，后端取消后原流会自行结束
+	if !ok {
+		utils.SuccessWithMessage(c, "取消成功", gin.H{"chat_id": chatId})
+		return
+	}
+
+	active.Cancel()
+	if !active.Wait(5 * time.Second) {
+		utils.SuccessWithMessage(c, "取消成功", gin.H{"chat_id": chatId})
 		return
 	}
 
-	// 发送结束事件
-	onMessage("end", map[string]string{"status": "completed"})
+	utils.SuccessWithMessage(c, "取消成功", gin.H{
+		"chat_id": chatId,
+		"message": active.Message,
+	})
 }
 
-// 辅助函数：生成消息ID
-func generateMessageId() string {
-	return fmt.Sprintf("msg_%d", utils.GenerateSnowflakeId())
+// 辅助函数：获取 ConversationAccess 中间件加载并授权的对话
+func authorizedConversation(c *gin.Context) *models.Conversation {
+	return c.MustGet(middleware.ContextConversation).(*models.Conversation)
 }
 
-// 辅助函数：获取消息内容
-func getMessageContent(msg interface{}) string {
-	// 根据实际的消息结构提取内容
-	if m, ok := msg.(map[string]interface{}); ok {
-		if content, ok := m["content"].(string); ok {
-			return content
+// 辅助函数：解析对话列表的筛选参数
+func parseConversationFilter(c *gin.Context) (models.ConversationFilter, error) {
+	filter := models.ConversationFilter{
+		Tag: strings.TrimSpace(c.Query("tag")),
+	}
+
+	if archived := c.Query("archived"); archived != "" {
+		value, err := strconv.ParseBool(archived)
+		if err != nil {
+			return filter, errors.New("archived格式错误")
+		}
+		filter.Archived = value
+	}
+	if folderId, ok := c.GetQuery("folder_id"); ok {
+		value, err := strconv.ParseUint(folderId, 10, 32)
+		if err != nil {
+			return filter, errors.New("文件夹ID格式错误")
+		}
+		id := uint(value)
+		filter.FolderId = &id
+	}
+	if agentId := c.Query("agent_id"); agentId != "" {
+		value, err := strconv.ParseUint(agentId, 10, 32)
+		if err != nil {
+			return filter, errors.New("AgentID格式错误")
 		}
+		filter.AgentId = uint(value)
 	}
+	return filter, nil
+}
 
-	// 尝试从结构体中提取内容
-	if msgStruct, ok := msg.(struct{ Content string }); ok {
-		return msgStruct.Content
+// 辅助函数：去除标签首尾空白并去重，校验数量和长度，name 用于错误信息
+func normalizeLabels(values []string, maxCount, maxLength int, name string) ([]string, error) {
+	result := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		if len([]rune(value)) > maxLength {
+			return nil, fmt.Errorf("%s不能超过%d个字符", name, maxLength)
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	if len(result) > maxCount {
+		return nil, fmt.Errorf("%s不能超过%d个", name, maxCount)
 	}
+	return result, nil
+}
 
-	// 如果是文本形式，直接返回
-	if content, ok := msg.(string); ok {
-		return content
+// 辅助函数：设置 SSE 头部
+func setSSEHeaders(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Headers", "Cache-Control")
+}
+
+// chatStream 对话流式响应，将事件写入SSE，对话完成或取消时保存AI回复
+type chatStream struct {
+	c                  *gin.Context
+	conversationId     uint
+	cozeConversationId string // 回复所在的Coze对话，以后端返回的为准
+	parentId           uint   // 回复保存为该消息的子消息
+	active             *services.ActiveChat
+
+	chatId    string
+	messageId string
+	content   strings.Builder
+
+	// 设置后在对话完成时将最终回复合成语音
+	speech *replySpeech
+	reply  *models.Message
+
+	saved bool // 是否保存了回复，包括取消时的部分回复
+}
+
+// replySpeech 回复语音合成的音色及音频格式
+type replySpeech struct {
+	voiceId     string
+	format      string
+	contentType string
+	userId      uint // 使用该用户所属工作空间的凭证合成
+}
+
+// 辅助函数：创建对话流式响应，回复保存为 parentId 的子消息，返回的ctx在对话被取消时结束
+func newChatStream(c *gin.Context, conversation *models.Conversation, parentId uint) (*chatStream, context.Context) {
+	// 客户端断开连接不影响服务端继续读取并保存回复
+	ctx, cancel := context.WithCancel(context.Background())
+	return &chatStream{
+		c:                  c,
+		conversationId:     conversation.ID,
+		cozeConversationId: conversation.CozeConversationID,
+		parentId:           parentId,
+		active:             services.NewActiveChat(conversation.ID, cancel),
+	}, ctx
+}
+
+// 辅助函数：以请求中的消息为上下文流式生成回复，可通过取消接口中止，返回是否保存了回复。调用前需已设置SSE头部
+func streamReply(c *gin.Context, conversation *models.Conversation, provider providers.ChatProvider, chatReq *providers.ChatRequest, parentId uint, speech *replySpeech) bool {
+	stream, ctx := newChatStream(c, conversation, parentId)
+	if speech != nil {
+		speech.userId = conversation.UserId
 	}
+	stream.speech = speech
 
-	return "AI回复"
+	err := provider.ChatStream(ctx, chatReq, stream.OnEvent)
+	stream.Finish(err)
+	return stream.saved
 }
 
-// 辅助函数：安全地将对象转换为 JSON
-func mustMarshalJSON(v interface{}) string {
-	data, err := json.Marshal(v)
+// 辅助函数：解析回复语音合成选项，未设置时返回nil
+func buildReplySpeech(options *ReplySpeechOptions) (*replySpeech, error) {
+	if options == nil {
+		return nil, nil
+	}
+	voiceId, format, contentType, err := resolveSpeechOptions(options.VoiceID, options.Format)
 	if err != nil {
-		return `{"error": "序列化失败"}`
+		return nil, err
 	}
-	return string(data)
+	return &replySpeech{voiceId: voiceId, format: format, contentType: contentType}, nil
 }
 
-func SendMessageWorkFlow(c *gin.Context) {
-	// conversationIdStr := c.DefaultQuery("conversation_id", "0")
-	// if conversationIdStr == "" {
-	// 	conversationIdStr = "0"
-	// }
+// OnEvent 处理对话后端的流式事件
+func (s *chatStream) OnEvent(event providers.Event) {
+	switch data := event.Data.(type) {
+	case providers.ChatCreated:
+		s.track(data.ChatID)
+		if data.ConversationID != "" {
+			s.cozeConversationId = data.ConversationID
+		}
+	case providers.MessageDelta:
+		// 处理消息增量更新
+		s.content.WriteString(data.Content)
+		if data.MessageID != "" {
+			s.messageId = data.MessageID
+		}
+	case providers.ChatCompleted:
+		// 保存AI回复消息
+		if message := s.saveMessage(data.ChatID, data.Usage.TokenCount, models.MessageStatusCompleted); message != nil {
+			s.reply = message
+		}
+	}
 
-	// conversationId, err := strconv.ParseUint(conversationIdStr, 10, 32)
-	// if err != nil {
-	// 	// 如果解析失败，默认为0
-	// 	conversationId = 0
-	// }
+	s.write(event)
+}
 
-	var req SendMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.BadRequest(c, "参数格式错误: "+err.Error())
-		return
+// track 登记进行中的对话，供取消接口使用
+func (s *chatStream) track(chatId string) {
+	s.chatId = chatId
+	services.RegisterActiveChat(chatId, s.active)
+}
+
+// Finish 流式处理结束后调用，对话被取消时保存部分回复并发送cancelled事件
+func (s *chatStream) Finish(err error) {
+	defer s.active.Finish()
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		cancelled := providers.ChatCancelled{ChatID: s.chatId}
+		if message := s.saveMessage(s.chatId, 0, models.MessageStatusCancelled); message != nil {
+			s.active.Message = message
+			cancelled.MessageID = message.ID
+		}
+		s.write(providers.Event{Type: providers.EventCancelled, Data: cancelled})
+	case err != nil:
+		s.write(providers.Event{Type: providers.EventError, Data: errorStatus(err)})
+	default:
+		if s.speech != nil && s.reply != nil {
+			s.synthesize()
+		}
+		// 发送结束事件
+		s.write(providers.Event{Type: providers.EventEnd, Data: providers.StatusMessage{Status: "completed"}})
 	}
+}
 
-	// userIdStr := c.DefaultQuery("user_id", "0")
-	// if userIdStr == "" {
-	// 	userIdStr = "0"
-	// }
+func (s *chatStream) saveMessage(chatId string, tokens int, status string) *models.Message {
+	if s.content.Len() == 0 {
+		return nil
+	}
 
-	// userID, err := strconv.ParseUint(userIdStr, 10, 32)
-	// if err != nil {
-	// 	// 如果解析失败，默认为0
-	// 	userID = 0
-	// }
+	// 后端未提供消息ID时以chat_id代替，由消息同步任务修正
+	messageId := s.messageId
+	if messageId == "" {
+		messageId = chatId
+	}
 
-	cozeConv, err := coze.New()
-	if err != nil {
-		utils.BadRequest(c, "初始化Coze对话失败: "+err.Error())
-		return
+	aiMessage := &models.Message{
+		CozeMessageId:      messageId,
+		ChatId:             chatId,
+		CozeConversationId: s.cozeConversationId,
+		ConversationId:     s.conversationId,
+		ParentId:           s.parentId,
+		ModelId:            1,
+		Role:               "assistant",
+		Content:            s.content.String(),
+		Tokens:             tokens,
+		Status:             status,
 	}
+	s.content.Reset()
+	s.messageId = ""
 
-	resp, err := cozeConv.RunWorkflow(req.Content)
+	if err := messageService.AppendMessage(aiMessage); err != nil {
+		// 错误处理，但不中断流式响应
+		fmt.Printf("保存AI回复失败: %v\n", err)
+		return nil
+	}
+	// 同一流中的后续回复接在本条之后
+	s.parentId = aiMessage.ID
+	s.saved = true
+	return aiMessage
+}
+
+// synthesize 将最终回复合成语音，长回复按句切分后逐段发送speech事件，合成失败不影响对话结果
+func (s *chatStream) synthesize() {
+	cozeClient, err := services.GetUserCozeClient(s.speech.userId)
 	if err != nil {
-		utils.BadRequest(c, "工作流运行失败: "+err.Error())
+		s.write(providers.Event{Type: providers.EventError, Data: providers.StatusMessage{Message: "语音合成失败: " + err.Error()}})
 		return
 	}
 
-	utils.Success(c,resp)
+	segments := coze.SplitSpeechInput(s.reply.Content)
+	for i, segment := range segments {
+		if s.c.Request.Context().Err() != nil {
+			return // 客户端已断开连接
+		}
+
+		audio, err := s.synthesizeSegment(cozeClient, segment)
+		if err != nil {
+			s.write(providers.Event{Type: providers.EventError, Data: providers.StatusMessage{Message: err.Error()}})
+			return
+		}
+
+		s.write(providers.Event{Type: providers.EventSpeech, Data: providers.Speech{
+			MessageID:   s.reply.ID,
+			Index:       i,
+			Count:       len(segments),
+			Format:      s.speech.format,
+			ContentType: s.speech.contentType,
+			Audio:       base64.StdEncoding.EncodeToString(audio),
+		}})
+	}
 }
 
-func SendMessageWorkFlowStream(c *gin.Context) {
-	var req SendMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.BadRequest(c, "参数格式错误: "+err.Error())
-		return
+func (s *chatStream) synthesizeSegment(cozeClient *coze.Client, segment string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(s.c.Request.Context(), time.Minute)
+	defer cancel()
+
+	audio, err := cozeClient.Speech(ctx, segment, s.speech.voiceId, s.speech.format, 0)
+	if err != nil {
+		return nil, err
 	}
+	defer audio.Close()
 
-	cozeConv, err := coze.New()
+	data, err := io.ReadAll(audio)
 	if err != nil {
-		utils.BadRequest(c, "初始化Coze对话失败: "+err.Error())
-		return
+		return nil, fmt.Errorf("读取合成语音失败: %v", err)
 	}
+	return data, nil
+}
 
-	clientGone := c.Request.Context().Done()
+// 辅助函数：构建错误事件数据，上游接口错误以错误分类作为状态
+func errorStatus(err error) providers.StatusMessage {
+	status := providers.StatusMessage{Message: err.Error()}
+	if upstreamErr, ok := utils.AsUpstreamError(err); ok {
+		status.Status = string(upstreamErr.Kind)
+	}
+	return status
+}
 
-	var aiMessageContent strings.Builder
-	var aiMessageId string
-	var aiMessageTokens int
+func (s *chatStream) write(event providers.Event) {
+	select {
+	case <-s.c.Request.Context().Done():
+		return // 客户端已断开连接
+	default:
+		s.c.SSEvent("message", event)
+		s.c.Writer.Flush()
+	}
+}
 
+// 辅助函数：根据请求构建用户消息，文本内容作为首个文本片段与多模态片段合并
+func buildUserMessage(req *SendMessageRequest) (*models.Message, error) {
+	parts := make(models.MessageParts, 0, len(req.Parts)+1)
+	if req.Content != "" && len(req.Parts) > 0 {
+		parts = append(parts, models.MessagePart{Type: models.MessagePartText, Text: req.Content})
+	}
 
-	// 定义流式回调函数
-	onMessage := func(eventType string, data interface{}) {
-		select {
-		case <-clientGone:
-			return // 客户端已断开连接
-		default:
-			eventData := map[string]interface{}{
-				"type": eventType,
-				"data": data,
+	for i, part := range req.Parts {
+		switch part.Type {
+		case models.MessagePartText:
+			if part.Text == "" {
+				return nil, fmt.Errorf("第%d个内容片段缺少text", i+1)
 			}
-
-			// 处理消息增量更新
-			if eventType == "message_delta" {
-				if msgData, ok := data.(map[string]interface{}); ok {
-					if content, ok := msgData["content"].(string); ok {
-						aiMessageContent.WriteString(content)
-					}
-				}
+		case models.MessagePartImage, models.MessagePartFile:
+			if (part.FileID == "") == (part.FileURL == "") {
+				return nil, fmt.Errorf("第%d个内容片段需指定file_id或file_url其中之一", i+1)
 			}
+		default:
+			return nil, fmt.Errorf("第%d个内容片段类型不支持: %s", i+1, part.Type)
+		}
+		parts = append(parts, part)
+	}
 
-			// 处理对话完成
-			if eventType == "chat_completed" {
-				if msgData, ok := data.(map[string]interface{}); ok {
-					if chatId, ok := msgData["chat_id"].(string); ok {
-						aiMessageId = chatId
-					}
-
-					if usage, ok := msgData["usage"].(map[string]interface{}); ok {
-						for _, token := range usage {
-							aiMessageTokens += token.(int)
-						}
-					}
-				}
-
-				// 保存AI回复消息
-				if aiMessageContent.Len() > 0 {
-					aiMessage := &models.Message{
-						CozeMessageId:  aiMessageId,
-						ConversationId: 10,
-						ModelId:        1,
-						Role:           "assistant",
-						Content:        aiMessageContent.String(),
-						Tokens:         aiMessageTokens,
-					}
-
-					if err := messageService.CreateMessage(aiMessage); err != nil {
-						// 错误处理，但不中断流式响应
-						fmt.Printf("保存AI回复失败: %v\n", err)
-					}
-				}
-			}
+	message := &models.Message{
+		CozeMessageId: generateMessageId(),
+		ModelId:       1,
+		Role:          "user",
+		Content:       req.Content,
+		Tokens:        0,
+	}
+	if len(parts) > 0 {
+		message.Parts = parts
+		message.Content = parts.Text()
+	}
 
-			c.SSEvent("message", eventData)
-			c.Writer.Flush()
-		}
+	if message.Content == "" && len(message.Parts) == 0 {
+		return nil, errors.New("消息内容不能为空")
 	}
+	return message, nil
+}
 
-	err = cozeConv.RunWorkflowStream(req.Content, onMessage)
+// 辅助函数：校验请求中的自定义变量，并合并元数据与对话用户信息
+func buildChatOptions(conversation *models.Conversation, req *SendMessageRequest) (map[string]string, map[string]string, error) {
+	customVariables, err := services.BuildChatVariables(conversation, req.CustomVariables)
 	if err != nil {
-		onMessage("workflow_error", map[string]string{"message": err.Error()})
-		return
+		return nil, nil, err
 	}
 
-	// 发送结束事件
-	onMessage("end", map[string]string{"status": "completed"})	
+	metaData, err := services.BuildChatMetaData(conversation.UserId, req.MetaData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return customVariables, metaData, nil
+}
+
+// 辅助函数：生成消息ID
+func generateMessageId() string {
+	return fmt.Sprintf("msg_%d", utils.GenerateSnowflakeId())
+}
+
+// 辅助函数：获取消息内容
+func getMessageContent(msg interface{}) string {
+	// 根据实际的消息结构提取内容
+	if m, ok := msg.(map[string]interface{}); ok {
+		if content, ok := m["content"].(string); ok {
+			return content
+		}
+	}
+
+	// 尝试从结构体中提取内容
+	if msgStruct, ok := msg.(struct{ Content string }); ok {
+		return msgStruct.Content
+	}
+
+	// 如果是文本形式，直接返回
+	if content, ok := msg.(string); ok {
+		return content
+	}
+
+	return "AI回复"
+}
+
+// 辅助函数：安全地将对象转换为 JSON
+func mustMarshalJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return `{"error": "序列化失败"}`
+	}
+	return string(data)
 }
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"archive/zip"
+	"coze-agent-platform/middleware"
+	"coze-agent-platform/models"
+	"coze-agent-platform/transcript"
+	"coze-agent-platform/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导入请求体大小上限
+const maxImportBodyBytes = 10 << 20
+
+// ExportConversation 导出对话
+// @Summary 导出对话
+// @Description 导出对话当前分支上的消息，支持 Markdown、JSON 和 HTML 格式。JSON格式可用于导入
+// @Tags 对话
+// @Produce octet-stream
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param format query string false "导出格式：md、json、html" default(md)
+// @Success 200 {file} binary
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id}/export [get]
+func ExportConversation(c *gin.Context) {
+	conversation := authorizedConversation(c)
+
+	format, err := parseExportFormat(c)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := conversationService.LoadConversationTags(conversation); err != nil {
+		utils.InternalServerError(c, "获取对话标签失败: "+err.Error())
+		return
+	}
+	document, err := loadConversationDocument(conversation)
+	if err != nil {
+		utils.InternalServerError(c, "获取对话消息失败: "+err.Error())
+		return
+	}
+
+	setAttachmentHeader(c, transcript.FileName(document, format), fmt.Sprintf("conversation-%d.%s", conversation.ID, format))
+	c.Header("Content-Type", transcript.ContentType(format))
+	c.Status(http.StatusOK)
+	if err := transcript.Render(c.Writer, format, document); err != nil {
+		log.Printf("导出对话失败 conversation_id=%d: %v", conversation.ID, err)
+	}
+}
+
+// ExportConversations 批量导出对话
+// @Summary 批量导出对话
+// @Description 将当前用户的全部对话（含已归档）按指定格式导出，每个对话一个文件，打包为zip
+// @Tags 对话
+// @Produce application/zip
+// @Security ApiKeyAuth
+// @Param format query string false "导出格式：md、json、html" default(md)
+// @Success 200 {file} binary
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/conversations/export [get]
+func ExportConversations(c *gin.Context) {
+	actor := middleware.GetActor(c)
+
+	format, err := parseExportFormat(c)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	conversations, err := conversationService.GetAllConversationsByUserId(actor.UserID)
+	if err != nil {
+		utils.InternalServerError(c, "获取对话列表失败: "+err.Error())
+		return
+	}
+
+	fileName := fmt.Sprintf("conversations-%s.zip", time.Now().Format("20060102150405"))
+	setAttachmentHeader(c, fileName, fileName)
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	// 响应已开始写入，出错时只能中断并记录日志
+	archive := zip.NewWriter(c.Writer)
+	for _, conversation := range conversations {
+		if err := writeConversationArchiveFile(archive, conversation, format); err != nil {
+			log.Printf("批量导出对话失败 user_id=%d conversation_id=%d: %v", actor.UserID, conversation.ID, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("批量导出对话失败 user_id=%d: %v", actor.UserID, err)
+	}
+}
+
+// ImportConversation 导入对话
+// @Summary 导入对话
+// @Description 请求体为本服务导出的JSON，或OpenAI格式的消息数组（也可以是包含 messages 字段的对象）。
+// @Description 消息按顺序保存为新对话的当前分支，使用Coze后端时最近的问答消息会写入新建的Coze对话作为上下文。
+// @Description 只导入文本内容，工具调用相关的消息会被忽略
+// @Tags 对话
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param agent_id query int false "新对话使用的Agent"
+// @Param title query string false "新对话的标题，为空时使用导入内容中的标题或第一条用户消息"
+// @Param request body transcript.Document true "导入内容"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/conversations/import [post]
+func ImportConversation(c *gin.Context) {
+	actor := middleware.GetActor(c)
+
+	var agentId uint
+	if value := c.Query("agent_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "Agent ID格式错误")
+			return
+		}
+		agentId = uint(id)
+	}
+	if !checkAgentAccess(c, agentId) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportBodyBytes+1))
+	if err != nil {
+		utils.BadRequest(c, "读取请求体失败: "+err.Error())
+		return
+	}
+	if len(body) > maxImportBodyBytes {
+		utils.BadRequest(c, "导入内容过大")
+		return
+	}
+
+	document, err := transcript.ParseImport(body)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	tags, err := normalizeLabels(document.Conversation.Tags, maxConversationTags, maxConversationTagLength, "标签")
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	title := strings.TrimSpace(c.Query("title"))
+	if title == "" {
+		title = strings.TrimSpace(document.Title())
+	}
+	if runes := []rune(title); len(runes) > 100 {
+		title = string(runes[:100])
+	}
+
+	conversation := &models.Conversation{
+		UserId:  actor.UserID,
+		AgentId: agentId,
+		Title:   title,
+		Tags:    []string{},
+	}
+	if err := conversationService.ImportConversation(conversation, document.ToMessages()); err != nil {
+		if _, ok := utils.AsUpstreamError(err); ok {
+			utils.ErrorWithCause(c, "", err)
+			return
+		}
+		utils.InternalServerError(c, "导入对话失败: "+err.Error())
+		return
+	}
+
+	if len(tags) > 0 {
+		if err := conversationService.SetConversationTags(conversation, tags); err != nil {
+			utils.InternalServerError(c, "保存对话标签失败: "+err.Error())
+			return
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"conversation":  conversation,
+		"message_count": len(document.Messages),
+	})
+}
+
+// 辅助函数：解析导出格式，未指定时使用Markdown
+func parseExportFormat(c *gin.Context) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", transcript.DefaultFormat)))
+	if !transcript.ValidFormat(format) {
+		return "", errors.New("导出格式只支持 md、json、html")
+	}
+	return format, nil
+}
+
+// 辅助函数：读取对话当前分支上的全部消息，生成导出内容
+func loadConversationDocument(conversation *models.Conversation) (*transcript.Document, error) {
+	leafId, err := messageService.GetActiveLeafId(conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []*models.Message{}
+	if leafId != 0 {
+		messages, err = messageService.GetMessagePath(leafId, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return transcript.NewDocument(conversation, messages), nil
+}
+
+// 辅助函数：将一个对话的导出内容写入zip
+func writeConversationArchiveFile(archive *zip.Writer, conversation *models.Conversation, format string) error {
+	document, err := loadConversationDocument(conversation)
+	if err != nil {
+		return err
+	}
+
+	writer, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     transcript.FileName(document, format),
+		Method:   zip.Deflate,
+		Modified: conversation.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return transcript.Render(writer, format, document)
+}
+
+// 辅助函数：设置下载文件名，fallback 为不支持 filename* 的客户端使用的ASCII文件名
+func setAttachmentHeader(c *gin.Context, fileName, fallback string) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback, url.PathEscape(fileName)))
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"coze-agent-platform/middleware"
+	"coze-agent-platform/models"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConversationFolderRequest struct {
+	Name string `json:"name" binding:"required"`
+	Sort int    `json:"sort"` // 越小越靠前
+}
+
+var conversationFolderService = services.NewConversationFolderService()
+
+// ListConversationFolders 获取对话文件夹列表
+// @Summary 获取对话文件夹列表
+// @Description 获取当前用户的对话文件夹，按排序值和创建顺序排列
+// @Tags 对话
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/conversation-folders [get]
+func ListConversationFolders(c *gin.Context) {
+	folders, err := conversationFolderService.ListFoldersByUserId(middleware.GetActor(c).UserID)
+	if err != nil {
+		utils.InternalServerError(c, "获取文件夹列表失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, folders)
+}
+
+// CreateConversationFolder 创建对话文件夹
+// @Summary 创建对话文件夹
+// @Description 创建用于整理对话的文件夹
+// @Tags 对话
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body ConversationFolderRequest true "文件夹信息"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/conversation-folders [post]
+func CreateConversationFolder(c *gin.Context) {
+	var req ConversationFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	folder := &models.ConversationFolder{UserId: middleware.GetActor(c).UserID}
+	if !applyConversationFolderRequest(c, folder, &req) {
+		return
+	}
+
+	if err := conversationFolderService.CreateFolder(folder); err != nil {
+		utils.InternalServerError(c, "创建文件夹失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, folder)
+}
+
+// UpdateConversationFolder 更新对话文件夹
+// @Summary 更新对话文件夹
+// @Description 重命名文件夹或调整排序
+// @Tags 对话
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件夹ID"
+// @Param request body ConversationFolderRequest true "文件夹信息"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversation-folders/{id} [put]
+func UpdateConversationFolder(c *gin.Context) {
+	folder, ok := getOwnedConversationFolder(c)
+	if !ok {
+		return
+	}
+
+	var req ConversationFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+	if !applyConversationFolderRequest(c, folder, &req) {
+		return
+	}
+
+	if err := conversationFolderService.UpdateFolder(folder); err != nil {
+		utils.InternalServerError(c, "更新文件夹失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, folder)
+}
+
+// DeleteConversationFolder 删除对话文件夹
+// @Summary 删除对话文件夹
+// @Description 删除文件夹，其中的对话移出文件夹但不会被删除
+// @Tags 对话
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文件夹ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversation-folders/{id} [delete]
+func DeleteConversationFolder(c *gin.Context) {
+	folder, ok := getOwnedConversationFolder(c)
+	if !ok {
+		return
+	}
+
+	if err := conversationFolderService.DeleteFolder(folder.ID); err != nil {
+		utils.InternalServerError(c, "删除文件夹失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// 辅助函数：获取路由参数id对应的文件夹并校验访问权限，失败时已写入响应
+func getOwnedConversationFolder(c *gin.Context) (*models.ConversationFolder, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "文件夹ID格式错误")
+		return nil, false
+	}
+
+	folder, err := conversationFolderService.GetFolderById(uint(id))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return nil, false
+	}
+
+	if err := services.CanAccessConversationFolder(middleware.GetActor(c), folder); err != nil {
+		utils.Forbidden(c, err.Error())
+		return nil, false
+	}
+
+	return folder, true
+}
+
+// 辅助函数：校验并写入文件夹名称和排序，失败时已写入响应
+func applyConversationFolderRequest(c *gin.Context, folder *models.ConversationFolder, req *ConversationFolderRequest) bool {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		utils.BadRequest(c, "文件夹名称不能为空")
+		return false
+	}
+	if len([]rune(name)) > 50 {
+		utils.BadRequest(c, "文件夹名称不能超过50个字符")
+		return false
+	}
+
+	folder.Name = name
+	folder.Sort = req.Sort
+	return true
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"coze-agent-platform/services"
 	"coze-agent-platform/utils"
 	"coze-agent-platform/utils/coze"
 
@@ -9,18 +10,27 @@ import (
 
 // GetCozeToken 获取Coze访问令牌
 // @Summary 获取Coze访问令牌
-// @Description 获取Coze API的访问令牌
+// @Description 获取当前用户所属工作空间的Coze API访问令牌，未加入工作空间时使用全局配置
 // @Tags Coze
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Success 200 {object} utils.Response
 // @Failure 400 {object} utils.Response
+// @Failure 502 {object} utils.Response
+// @Failure 503 {object} utils.Response
 // @Router /api/coze/token [get]
 func GetCozeToken(c *gin.Context) {
-	token, err := coze.GetToken()
+	workspaceId, err := services.GetUserWorkspaceId(c.GetUint("user_id"))
 	if err != nil {
 		utils.BadRequest(c, err.Error())
 		return
 	}
+
+	token, err := coze.GetToken(workspaceId)
+	if err != nil {
+		utils.ErrorWithCause(c, "", err)
+		return
+	}
 	utils.Success(c, token)
 }
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"coze-agent-platform/middleware"
+	"coze-agent-platform/models"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MessageFeedbackRequest struct {
+	Rating  int      `json:"rating" binding:"required,oneof=1 -1"` // 1-好评，-1-差评
+	Reasons []string `json:"reasons"`                              // 原因标签，如 inaccurate、irrelevant、incomplete
+	Comment string   `json:"comment"`
+}
+
+// 评价限制
+const (
+	maxFeedbackReasons      = 5
+	maxFeedbackReasonLength = 20
+	maxFeedbackComment      = 1000
+)
+
+var feedbackService = services.NewFeedbackService()
+
+// SubmitMessageFeedback 评价回复
+// @Summary 评价回复
+// @Description 对一条回复好评或差评，可附带原因标签和文字说明。同一用户重复评价时覆盖原评价。Coze对话的评价会同步到Coze，同步结果见 forward_status
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "消息ID"
+// @Param request body MessageFeedbackRequest true "评价"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/messages/{id}/feedback [post]
+func SubmitMessageFeedback(c *gin.Context) {
+	message, conversation, ok := getFeedbackMessage(c)
+	if !ok {
+		return
+	}
+
+	var req MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	reasons, err := normalizeLabels(req.Reasons, maxFeedbackReasons, maxFeedbackReasonLength, "评价原因")
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	comment := strings.TrimSpace(req.Comment)
+	if len([]rune(comment)) > maxFeedbackComment {
+		utils.BadRequest(c, "评价说明不能超过1000个字符")
+		return
+	}
+
+	feedback := &models.MessageFeedback{
+		MessageId:      message.ID,
+		UserId:         middleware.GetActor(c).UserID,
+		ConversationId: conversation.ID,
+		AgentId:        conversation.AgentId,
+		Rating:         req.Rating,
+		Comment:        comment,
+		Reasons:        reasons,
+	}
+	if err := feedbackService.SubmitFeedback(feedback, message, conversation); err != nil {
+		utils.InternalServerError(c, "保存评价失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, feedback)
+}
+
+// GetMessageFeedback 获取回复的评价
+// @Summary 获取回复的评价
+// @Description 获取当前用户对一条回复的评价，未评价时返回null
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "消息ID"
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/messages/{id}/feedback [get]
+func GetMessageFeedback(c *gin.Context) {
+	message, _, ok := getFeedbackMessage(c)
+	if !ok {
+		return
+	}
+
+	feedback, err := feedbackService.GetFeedback(message.ID, middleware.GetActor(c).UserID)
+	if err != nil {
+		utils.InternalServerError(c, "获取评价失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, feedback)
+}
+
+// DeleteMessageFeedback 取消评价
+// @Summary 取消评价
+// @Description 删除当前用户对一条回复的评价，已同步到Coze的评价同时从Coze删除
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "消息ID"
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/messages/{id}/feedback [delete]
+func DeleteMessageFeedback(c *gin.Context) {
+	message, conversation, ok := getFeedbackMessage(c)
+	if !ok {
+		return
+	}
+
+	feedback, err := feedbackService.GetFeedback(message.ID, middleware.GetActor(c).UserID)
+	if err != nil {
+		utils.InternalServerError(c, "获取评价失败: "+err.Error())
+		return
+	}
+	if feedback == nil {
+		utils.NotFound(c, "评价不存在")
+		return
+	}
+
+	if err := feedbackService.DeleteFeedback(feedback, message, conversation); err != nil {
+		utils.InternalServerError(c, "删除评价失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// GetFeedbackReport 获取评价报表
+// @Summary 获取评价报表
+// @Description 按Agent和时间范围统计回复评价：满意度、各Agent的满意度、最常见的差评原因和差评最多的对话（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param agent_id query int false "AgentID，为空时统计全部"
+// @Param start query string false "开始时间，格式 2006-01-02 或 RFC3339"
+// @Param end query string false "结束时间，格式 2006-01-02（包含当天）或 RFC3339"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/admin/feedback/report [get]
+func GetFeedbackReport(c *gin.Context) {
+	var filter models.FeedbackReportFilter
+	if agentId := c.Query("agent_id"); agentId != "" {
+		value, err := strconv.ParseUint(agentId, 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "AgentID格式错误")
+			return
+		}
+		filter.AgentId = uint(value)
+	}
+
+	var err error
+	if filter.StartTime, err = parseQueryTime(c.Query("start"), false); err != nil {
+		utils.BadRequest(c, "开始时间格式错误")
+		return
+	}
+	if filter.EndTime, err = parseQueryTime(c.Query("end"), true); err != nil {
+		utils.BadRequest(c, "结束时间格式错误")
+		return
+	}
+
+	report, err := feedbackService.GetFeedbackReport(filter)
+	if err != nil {
+		utils.InternalServerError(c, "获取评价报表失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, report)
+}
+
+// 辅助函数：获取路由参数id对应的回复及所属对话并校验访问权限，失败时已写入响应
+func getFeedbackMessage(c *gin.Context) (*models.Message, *models.Conversation, bool) {
+	messageId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "消息ID格式错误")
+		return nil, nil, false
+	}
+
+	message, err := messageService.GetMessageById(uint(messageId))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return nil, nil, false
+	}
+
+	conversation, err := conversationService.GetConversationById(message.ConversationId)
+	if err == nil {
+		err = services.CanAccessConversation(middleware.GetActor(c), conversation)
+	}
+	if err != nil {
+		middleware.AbortWithAccessError(c, err)
+		return nil, nil, false
+	}
+
+	if message.Role != "assistant" {
+		utils.BadRequest(c, "只能评价回复")
+		return nil, nil, false
+	}
+	return message, conversation, true
+}
@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"coze-agent-platform/middleware"
+	"coze-agent-platform/models"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"coze-agent-platform/utils/coze"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	cozeapi "github.com/coze-dev/coze-go"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateDatasetRequest struct {
+	SpaceID     string `json:"space_id"` // 为空时使用 COZE_SPACE_ID 环境变量
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	FormatType  int    `json:"format_type"` // 0:文本 1:表格 2:图片
+}
+
+// 上传知识库文档的大小上限，文件内容以base64提交给Coze
+const maxKnowledgeFileSize = 20 << 20
+
+type CreateDocumentRequest struct {
+	URL     string `json:"url" form:"url"`
+	FileID  string `json:"file_id" form:"file_id"` // 通用文件上传接口返回的文件ID
+	Name    string `json:"name" form:"name"`
+	AgentID uint   `json:"agent_id" form:"agent_id"`
+}
+
+var knowledgeDocumentService = services.NewKnowledgeDocumentService()
+
+// ListDatasets 获取知识库列表
+// @Summary 获取知识库列表
+// @Description 获取Coze空间下的知识库列表，使用所属工作空间的Coze凭证，未加入工作空间时仅管理员可用
+// @Tags 知识库
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param space_id query string false "Coze空间ID"
+// @Param name query string false "知识库名称"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} utils.PageResponse
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/knowledge/datasets [get]
+func ListDatasets(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 10
+	}
+
+	spaceId := c.DefaultQuery("space_id", coze.DefaultSpaceID())
+	if spaceId == "" {
+		utils.BadRequest(c, "缺少空间ID")
+		return
+	}
+
+	cozeClient, ok := getKnowledgeCozeClient(c)
+	if !ok {
+		return
+	}
+
+	datasets, total, err := cozeClient.ListDatasets(spaceId, c.Query("name"), page, size)
+	if err != nil {
+		utils.ErrorWithCause(c, "", err)
+		return
+	}
+
+	utils.PageSuccess(c, datasets, int64(total), page, size)
+}
+
+// CreateDataset 创建知识库
+// @Summary 创建知识库
+// @Description 在Coze空间下创建知识库，使用所属工作空间的Coze凭证，未加入工作空间时仅管理员可用
+// @Tags 知识库
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body CreateDatasetRequest true "知识库信息"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/knowledge/datasets [post]
+func CreateDataset(c *gin.Context) {
+	var req CreateDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误："+err.Error())
+		return
+	}
+
+	if req.SpaceID == "" {
+		req.SpaceID = coze.DefaultSpaceID()
+	}
+	if req.SpaceID == "" {
+		utils.BadRequest(c, "缺少空间ID")
+		return
+	}
+
+	cozeClient, ok := getKnowledgeCozeClient(c)
+	if !ok {
+		return
+	}
+
+	datasetId, err := cozeClient.CreateDataset(req.SpaceID, req.Name, req.Description, cozeapi.DocumentFormatType(req.FormatType))
+	if err != nil {
+		utils.ErrorWithCause(c, "", err)
+		return
+	}
+
+	// 记录创建者，上传和管理文档时据此校验权限
+	dataset := &models.KnowledgeDataset{
+		DatasetId: datasetId,
+		SpaceId:   req.SpaceID,
+		UserId:    c.GetUint("user_id"),
+		Name:      req.Name,
+	}
+	if err := knowledgeDocumentService.CreateKnowledgeDataset(dataset); err != nil {
+		utils.InternalServerError(c, "保存知识库失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "创建成功", gin.H{"dataset_id": datasetId})
+}
+
+// CreateDocument 上传知识库文档
+// @Summary 上传知识库文档
+// @Description 通过 multipart 表单上传文件(file，不超过20MB)，或通过 JSON 提交通用文件上传接口返回的文件ID(file_id)或网页地址(url)，将文档上传到知识库。
+// @Description 只能上传到自己通过平台创建的知识库，使用所属工作空间的Coze凭证，未加入工作空间时仅管理员可用
+// @Tags 知识库
+// @Accept json,mpfd
+// @Produce json
+// @Security ApiKeyAuth
+// @Param dataset_id path string true "知识库ID"
+// @Param file formData file false "文档文件"
+// @Param request body CreateDocumentRequest false "文件ID或网页文档信息"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/knowledge/datasets/{dataset_id}/documents [post]
+func CreateDocument(c *gin.Context) {
+	userId := c.GetUint("user_id")
+	datasetId := c.Param("dataset_id")
+
+	var req CreateDocumentRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.BadRequest(c, "参数错误："+err.Error())
+		return
+	}
+	if !checkAgentAccess(c, req.AgentID) {
+		return
+	}
+	if !checkKnowledgeDatasetAccess(c, datasetId) {
+		return
+	}
+
+	cozeClient, ok := getKnowledgeCozeClient(c)
+	if !ok {
+		return
+	}
+
+	document := &models.KnowledgeDocument{
+		DatasetId: datasetId,
+		UserId:    userId,
+		AgentId:   req.AgentID,
+		Name:      req.Name,
+	}
+
+	var (
+		cozeDocuments []*cozeapi.Document
+		err           error
+	)
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		// 与通用文件上传接口一致，从表单获取上传的文件
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			utils.BadRequest(c, "获取文件失败: "+err.Error())
+			return
+		}
+		if fileHeader.Size > maxKnowledgeFileSize {
+			utils.BadRequest(c, "文件不能超过20MB")
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			utils.BadRequest(c, "打开文件失败: "+err.Error())
+			return
+		}
+		defer file.Close()
+
+		content, err := io.ReadAll(io.LimitReader(file, maxKnowledgeFileSize+1))
+		if err != nil {
+			utils.BadRequest(c, "读取文件失败: "+err.Error())
+			return
+		}
+		if len(content) > maxKnowledgeFileSize {
+			utils.BadRequest(c, "文件不能超过20MB")
+			return
+		}
+
+		if document.Name == "" {
+			document.Name = fileHeader.Filename
+		}
+		document.SourceType = "file"
+		document.Size = fileHeader.Size
+
+		fileType := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+		cozeDocuments, err = cozeClient.CreateDocumentFromFile(datasetId, document.Name, content, fileType)
+		if err != nil {
+			utils.ErrorWithCause(c, "", err)
+			return
+		}
+	} else if req.FileID != "" {
+		if document.Name == "" {
+			document.Name = req.FileID
+		}
+		document.SourceType = "file"
+
+		cozeDocuments, err = cozeClient.CreateDocumentFromFileID(datasetId, document.Name, req.FileID)
+		if err != nil {
+			utils.ErrorWithCause(c, "", err)
+			return
+		}
+	} else {
+		if req.URL == "" {
+			utils.BadRequest(c, "缺少文件、文件ID或网页地址")
+			return
+		}
+
+		if document.Name == "" {
+			document.Name = req.URL
+		}
+		document.SourceType = "url"
+		document.SourceUrl = req.URL
+
+		cozeDocuments, err = cozeClient.CreateDocumentFromURL(datasetId, document.Name, req.URL)
+		if err != nil {
+			utils.ErrorWithCause(c, "", err)
+			return
+		}
+	}
+
+	if len(cozeDocuments) == 0 {
+		utils.BadRequest(c, "上传知识库文档失败: 未返回文档信息")
+		return
+	}
+
+	document.DocumentId = cozeDocuments[0].DocumentID
+	document.Status = int(cozeDocuments[0].Status)
+	if err := knowledgeDocumentService.CreateKnowledgeDocument(document); err != nil {
+		utils.InternalServerError(c, "保存知识库文档失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "上传成功", document)
+}
+
+// ListDocuments 获取知识库文档列表
+// @Summary 获取知识库文档列表
+// @Description 获取当前用户上传的知识库文档
+// @Tags 知识库
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param dataset_id query string false "知识库ID"
+// @Param agent_id query int false "Agent ID"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} utils.PageResponse
+// @Failure 401 {object} utils.Response
+// @Router /api/knowledge/documents [get]
+func ListDocuments(c *gin.Context) {
+	userId := c.GetUint("user_id")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	agentId, _ := strconv.ParseUint(c.DefaultQuery("agent_id", "0"), 10, 32)
+
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 10
+	}
+
+	documents, total, err := knowledgeDocumentService.GetKnowledgeDocumentsByUserId(userId, c.Query("dataset_id"), uint(agentId), page, size)
+	if err != nil {
+		utils.InternalServerError(c, "获取知识库文档失败: "+err.Error())
+		return
+	}
+
+	utils.PageSuccess(c, documents, total, page, size)
+}
+
+// GetDocument 获取知识库文档详情
+// @Summary 获取知识库文档详情
+// @Description 获取知识库文档详情，处理中的文档会同步最新处理进度
+// @Tags 知识库
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文档ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/knowledge/documents/{id} [get]
+func GetDocument(c *gin.Context) {
+	document, ok := getOwnedKnowledgeDocument(c)
+	if !ok {
+		return
+	}
+
+	if err := knowledgeDocumentService.RefreshKnowledgeDocumentProgress(document); err != nil {
+		utils.ErrorWithCause(c, "查询文档处理进度失败: ", err)
+		return
+	}
+
+	utils.Success(c, document)
+}
+
+// DeleteDocument 删除知识库文档
+// @Summary 删除知识库文档
+// @Description 从Coze知识库删除文档并删除本地记录
+// @Tags 知识库
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "文档ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/knowledge/documents/{id} [delete]
+func DeleteDocument(c *gin.Context) {
+	document, ok := getOwnedKnowledgeDocument(c)
+	if !ok {
+		return
+	}
+
+	// 文档由上传者所属工作空间的凭证创建
+	cozeClient, err := services.GetUserCozeClient(document.UserId)
+	if err != nil {
+		utils.BadRequest(c, "创建Coze客户端失败: "+err.Error())
+		return
+	}
+
+	if err := cozeClient.DeleteDocuments([]string{document.DocumentId}); err != nil {
+		utils.ErrorWithCause(c, "", err)
+		return
+	}
+
+	if err := knowledgeDocumentService.DeleteKnowledgeDocument(document.ID); err != nil {
+		utils.InternalServerError(c, "删除失败")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// 辅助函数：获取当前用户操作知识库使用的Coze客户端，失败时直接写入响应
+func getKnowledgeCozeClient(c *gin.Context) (*coze.Client, bool) {
+	actor := middleware.GetActor(c)
+	workspaceId, err := services.GetUserWorkspaceId(actor.UserID)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return nil, false
+	}
+	if err := services.CanUseKnowledgeSpace(actor, workspaceId); err != nil {
+		utils.Forbidden(c, "未加入工作空间，无权管理知识库")
+		return nil, false
+	}
+
+	cozeClient, err := coze.ForWorkspace(workspaceId)
+	if err != nil {
+		utils.BadRequest(c, "创建Coze客户端失败: "+err.Error())
+		return nil, false
+	}
+	return cozeClient, true
+}
+
+// 辅助函数：获取当前用户的知识库文档，失败时直接写入响应
+func getOwnedKnowledgeDocument(c *gin.Context) (*models.KnowledgeDocument, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "ID格式错误")
+		return nil, false
+	}
+
+	document, err := knowledgeDocumentService.GetKnowledgeDocumentById(uint(id))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return nil, false
+	}
+
+	// 检查权限
+	if err := services.CanAccessKnowledgeDocument(middleware.GetActor(c), document); err != nil {
+		utils.Forbidden(c, err.Error())
+		return nil, false
+	}
+	if !checkKnowledgeDatasetAccess(c, document.DatasetId) {
+		return nil, false
+	}
+
+	return document, true
+}
+
+// 辅助函数：检查当前用户能否管理知识库中的文档，失败时直接写入响应
+func checkKnowledgeDatasetAccess(c *gin.Context, datasetId string) bool {
+	dataset, err := knowledgeDocumentService.GetKnowledgeDatasetByDatasetId(datasetId)
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return false
+	}
+
+	if err := services.CanAccessKnowledgeDataset(middleware.GetActor(c), dataset); err != nil {
+		utils.Forbidden(c, err.Error())
+		return false
+	}
+	return true
+}
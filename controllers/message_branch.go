@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegenerateMessageRequest 重新生成回复的请求，请求体可为空
+type RegenerateMessageRequest struct {
+	Speech *ReplySpeechOptions `json:"speech"` // 将最终回复合成语音
+
+	CustomVariables map[string]string `json:"custom_variables"` // Bot变量，需在Agent配置中声明
+	MetaData        map[string]string `json:"meta_data"`        // 附加元数据，用户信息字段由服务端填充
+}
+
+type SwitchBranchRequest struct {
+	MessageID uint `json:"message_id" binding:"required"` // 切换到包含该消息的分支
+}
+
+// 当前分支最多返回的消息数
+const (
+	defaultBranchMessages = 200
+	maxBranchMessages     = 1000
+)
+
+// GetMessageBranch 获取当前分支
+// @Summary 获取当前分支
+// @Description 获取对话当前分支上的消息，按时间正序排列。sibling_ids 为同一父消息下的全部分支消息ID（含自身），可用于切换分支
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param limit query int false "最多返回最近的消息数" default(200)
+// @Success 200 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id}/branch [get]
+func GetMessageBranch(c *gin.Context) {
+	conversation := authorizedConversation(c)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultBranchMessages)))
+	if limit <= 0 || limit > maxBranchMessages {
+		limit = defaultBranchMessages
+	}
+
+	writeMessageBranch(c, conversation.ID, limit)
+}
+
+// SwitchMessageBranch 切换分支
+// @Summary 切换分支
+// @Description 切换到包含指定消息的分支，沿最新的子消息找到分支末尾，之后发送的消息接在该分支之后。
+// @Description 使用Coze时新建Coze对话并写入该分支上的历史消息。返回切换后的当前分支
+// @Tags 消息
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param request body SwitchBranchRequest true "分支中的消息"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id}/branch [put]
+func SwitchMessageBranch(c *gin.Context) {
+	conversation := authorizedConversation(c)
+
+	var req SwitchBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	previousId, err := messageService.GetActiveLeafId(conversation.ID)
+	if err != nil {
+		utils.InternalServerError(c, "获取当前分支失败: "+err.Error())
+		return
+	}
+
+	leafId, err := messageService.SwitchBranch(conversation.ID, req.MessageID)
+	if err != nil {
+		utils.BadRequest(c, "切换分支失败: "+err.Error())
+		return
+	}
+
+	// 远端对话中是原分支的历史，之后的消息需在写入新分支历史的远端对话中继续
+	if leafId != previousId {
+		historyMessages, err := messageService.GetRecentMessages(conversation.ID, 20)
+		if err == nil {
+			_, err = conversationService.StartBranchConversation(conversation, historyMessages)
+		}
+		if err != nil {
+			if restoreErr := messageService.SetActiveMessage(conversation.ID, previousId); restoreErr != nil {
+				log.Printf("恢复当前分支失败 conversation_id=%d: %v", conversation.ID, restoreErr)
+			}
+			if _, ok := utils.AsUpstreamError(err); ok {
+				utils.ErrorWithCause(c, "", err)
+				return
+			}
+			utils.InternalServerError(c, "切换分支失败: "+err.Error())
+			return
+		}
+	}
+
+	writeMessageBranch(c, conversation.ID, defaultBranchMessages)
+}
+
+// EditMessage 编辑消息(流式)
+// @Summary 编辑消息(流式)
+// @Description 以新内容创建原用户消息的兄弟分支并生成回复，原消息及其回复保留在原分支中。使用 SSE 协议返回流式响应，事件与流式发送消息相同
+// @Tags 消息
+// @Accept json
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param message_id path int true "要编辑的用户消息ID"
+// @Param request body SendMessageRequest true "新的消息内容"
+// @Success 200 {string} string "SSE 流式响应"
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id}/messages/{message_id}/edit [post]
+func EditMessage(c *gin.Context) {
+	conversation := authorizedConversation(c)
+
+	original, ok := getConversationMessage(c, conversation)
+	if !ok {
+		return
+	}
+	if original.Role != "user" {
+		utils.BadRequest(c, "只能编辑用户消息")
+		return
+	}
+
+	var req SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	userMessage, err := buildUserMessage(&req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	speech, err := buildReplySpeech(req.Speech)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	customVariables, metaData, err := buildChatOptions(conversation, &req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	// 历史为原消息之前的分支
+	historyMessages := []*models.Message{}
+	if original.ParentId != 0 {
+		historyMessages, err = messageService.GetMessagePath(original.ParentId, 20)
+		if err != nil {
+			utils.InternalServerError(c, "获取历史消息失败: "+err.Error())
+			return
+		}
+	}
+
+	provider, botId, err := services.GetConversationProvider(conversation)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	chatMessages, ok := prepareBranchMessages(c, conversation, historyMessages, userMessage)
+	if !ok {
+		return
+	}
+
+	setSSEHeaders(c)
+
+	// 新消息与原消息共用父消息，成为当前分支
+	userMessage.ConversationId = conversation.ID
+	userMessage.CozeConversationId = conversation.CozeConversationID
+	userMessage.ParentId = original.ParentId
+
+	if err := messageService.AppendMessage(userMessage); err != nil {
+		c.SSEvent("error", map[string]interface{}{
+			"error":   true,
+			"message": "保存用户消息失败: " + err.Error(),
+		})
+		c.Writer.Flush()
+		return
+	}
+
+	streamReply(c, conversation, provider, &providers.ChatRequest{
+		BotID:           botId,
+		ConversationID:  conversation.CozeConversationID,
+		WorkflowID:      conversation.WorkflowId,
+		UserID:          conversation.UserId,
+		Messages:        chatMessages,
+		CustomVariables: customVariables,
+		MetaData:        metaData,
+	}, userMessage.ID, speech)
+}
+
+// RegenerateMessage 重新生成回复(流式)
+// @Summary 重新生成回复(流式)
+// @Description 为指定的用户消息或回复对应的用户消息生成新的回复，新回复作为原回复的兄弟分支保存，原回复保留。使用 SSE 协议返回流式响应，事件与流式发送消息相同
+// @Tags 消息
+// @Accept json
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param message_id path int true "要重新生成的回复ID，或要重新回答的用户消息ID"
+// @Param request body RegenerateMessageRequest false "生成选项"
+// @Success 200 {string} string "SSE 流式响应"
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id}/messages/{message_id}/regenerate [post]
+func RegenerateMessage(c *gin.Context) {
+	conversation := authorizedConversation(c)
+
+	message, ok := getConversationMessage(c, conversation)
+	if !ok {
+		return
+	}
+
+	// 新回复的父消息为提问
+	questionId := message.ID
+	if message.Role != "user" {
+		questionId = message.ParentId
+	}
+	if questionId == 0 {
+		utils.BadRequest(c, "该消息没有对应的提问")
+		return
+	}
+
+	var req RegenerateMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	speech, err := buildReplySpeech(req.Speech)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	customVariables, metaData, err := buildChatOptions(conversation, &SendMessageRequest{
+		CustomVariables: req.CustomVariables,
+		MetaData:        req.MetaData,
+	})
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	// 历史包含提问本身
+	historyMessages, err := messageService.GetMessagePath(questionId, 21)
+	if err != nil {
+		utils.InternalServerError(c, "获取历史消息失败: "+err.Error())
+		return
+	}
+	if len(historyMessages) == 0 || historyMessages[len(historyMessages)-1].Role != "user" {
+		utils.BadRequest(c, "该消息没有对应的提问")
+		return
+	}
+
+	provider, botId, err := services.GetConversationProvider(conversation)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	previousCozeConversationId := conversation.CozeConversationID
+	chatMessages, ok := prepareBranchMessages(c, conversation, historyMessages[:len(historyMessages)-1], historyMessages[len(historyMessages)-1])
+	if !ok {
+		return
+	}
+
+	// 新回复保存后才成为当前分支，生成失败或没有内容时原回复仍在当前分支上
+	setSSEHeaders(c)
+
+	saved := streamReply(c, conversation, provider, &providers.ChatRequest{
+		BotID:           botId,
+		ConversationID:  conversation.CozeConversationID,
+		WorkflowID:      conversation.WorkflowId,
+		UserID:          conversation.UserId,
+		Messages:        chatMessages,
+		CustomVariables: customVariables,
+		MetaData:        metaData,
+	}, questionId, speech)
+
+	// 没有保存新回复时当前分支仍为原回复，恢复原来的远端对话
+	if !saved && conversation.CozeConversationID != previousCozeConversationId {
+		if err := conversationService.SetCozeConversationId(conversation, previousCozeConversationId); err != nil {
+			log.Printf("恢复Coze对话失败 conversation_id=%d: %v", conversation.ID, err)
+		}
+	}
+}
+
+// 辅助函数：获取路由参数message_id对应的消息并校验属于该对话，失败时已写入响应
+func getConversationMessage(c *gin.Context, conversation *models.Conversation) (*models.Message, bool) {
+	messageId, err := strconv.ParseUint(c.Param("message_id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "消息ID格式错误")
+		return nil, false
+	}
+
+	message, err := messageService.GetMessageById(uint(messageId))
+	if err != nil || message.ConversationId != conversation.ID {
+		utils.NotFound(c, "消息不存在")
+		return nil, false
+	}
+	return message, true
+}
+
+// 辅助函数：生成新分支的对话请求消息，失败时已写入响应。
+// 远端对话中保存的是原分支的历史，对话后端支持时为新分支创建远端对话并写入history，只发送提问；
+// 否则随请求发送完整的分支消息
+func prepareBranchMessages(c *gin.Context, conversation *models.Conversation, history []*models.Message, question *models.Message) ([]*models.Message, bool) {
+	seeded, err := conversationService.StartBranchConversation(conversation, history)
+	if err != nil {
+		if _, ok := utils.AsUpstreamError(err); ok {
+			utils.ErrorWithCause(c, "", err)
+			return nil, false
+		}
+		utils.InternalServerError(c, "创建分支对话失败: "+err.Error())
+		return nil, false
+	}
+	if seeded {
+		return []*models.Message{question}, true
+	}
+	return append(history, question), true
+}
+
+// 辅助函数：返回对话的当前分支
+func writeMessageBranch(c *gin.Context, conversationId uint, limit int) {
+	messages, err := messageService.GetActiveBranch(conversationId, limit)
+	if err != nil {
+		utils.InternalServerError(c, "获取当前分支失败: "+err.Error())
+		return
+	}
+
+	var activeMessageId uint
+	if len(messages) > 0 {
+		activeMessageId = messages[len(messages)-1].ID
+	}
+	utils.Success(c, gin.H{
+		"active_message_id": activeMessageId,
+		"messages":          messages,
+	})
+}
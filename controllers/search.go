@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"coze-agent-platform/middleware"
+	"coze-agent-platform/search"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 搜索关键词最大长度（字符数）
+const maxSearchKeywordLength = 100
+
+// Search 搜索对话和消息
+// @Summary 搜索对话和消息
+// @Description 在当前用户的消息内容和对话标题中搜索，多个关键词用空格分隔且须同时出现。结果按相关度排序，highlight 为HTML转义后的片段，关键词用 <em> 标记
+// @Tags 搜索
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param q query string true "关键词"
+// @Param type query string false "结果类型：message、conversation，为空时都搜索"
+// @Param agent_id query int false "按Agent筛选"
+// @Param role query string false "只搜索该角色的消息：user、assistant"
+// @Param start query string false "开始时间，格式 2006-01-02 或 RFC3339"
+// @Param end query string false "结束时间，格式 2006-01-02（包含当天）或 RFC3339"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} utils.PageResponse
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/search [get]
+func Search(c *gin.Context) {
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		utils.BadRequest(c, "请输入搜索关键词")
+		return
+	}
+	if len([]rune(keyword)) > maxSearchKeywordLength {
+		utils.BadRequest(c, "搜索关键词过长")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 10
+	}
+
+	query := &search.Query{
+		UserID:   middleware.GetActor(c).UserID,
+		Keyword:  keyword,
+		Type:     c.Query("type"),
+		Role:     c.Query("role"),
+		Page:     page,
+		PageSize: size,
+	}
+	if query.Type != "" && query.Type != search.HitTypeMessage && query.Type != search.HitTypeConversation {
+		utils.BadRequest(c, "type只能为message或conversation")
+		return
+	}
+	if agentId := c.Query("agent_id"); agentId != "" {
+		value, err := strconv.ParseUint(agentId, 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "AgentID格式错误")
+			return
+		}
+		query.AgentID = uint(value)
+	}
+
+	var err error
+	if query.StartTime, err = parseQueryTime(c.Query("start"), false); err != nil {
+		utils.BadRequest(c, "开始时间格式错误")
+		return
+	}
+	if query.EndTime, err = parseQueryTime(c.Query("end"), true); err != nil {
+		utils.BadRequest(c, "结束时间格式错误")
+		return
+	}
+
+	result, err := search.Current().Search(query)
+	if err != nil {
+		utils.InternalServerError(c, "搜索失败: "+err.Error())
+		return
+	}
+
+	utils.PageSuccess(c, result.Hits, result.Total, page, size)
+}
+
+// ReindexSearch 重建搜索索引
+// @Summary 重建搜索索引
+// @Description 在后台将全部对话和消息写入搜索索引，用于切换到Elasticsearch后导入已有数据（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/admin/search/reindex [post]
+func ReindexSearch(c *gin.Context) {
+	if !services.StartSearchReindex() {
+		utils.BadRequest(c, "索引重建任务正在运行")
+		return
+	}
+
+	utils.SuccessWithMessage(c, "已开始重建索引", gin.H{
+		"backend": search.Current().Name(),
+	})
+}
+
+// 辅助函数：解析查询参数中的时间范围，只有日期的结束时间包含当天
+func parseQueryTime(value string, isEnd bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if isEnd {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"coze-agent-platform/utils/coze"
+
+	cozeapi "github.com/coze-dev/coze-go"
+	"github.com/gin-gonic/gin"
+)
+
+type SubmitToolOutputsRequest struct {
+	ToolOutputs []*cozeapi.ToolOutput `json:"tool_outputs" binding:"required,min=1"`
+}
+
+// ListTools 获取工具列表
+// @Summary 获取工具列表
+// @Description 获取服务端注册的工具，client_side为true的工具需要由浏览器执行并回传结果
+// @Tags 工具
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} utils.Response
+// @Router /api/tools [get]
+func ListTools(c *gin.Context) {
+	utils.Success(c, coze.ListTools())
+}
+
+// SubmitToolOutputs 提交工具执行结果(流式)
+// @Summary 提交工具执行结果(流式)
+// @Description 提交浏览器端执行的工具结果，继续对话并使用 SSE 协议返回流式响应
+// @Tags 工具
+// @Accept json
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param id path int true "对话ID"
+// @Param chat_id path string true "Coze Chat ID"
+// @Param request body SubmitToolOutputsRequest true "工具执行结果"
+// @Success 200 {string} string "SSE 流式响应"
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/conversations/{id}/chats/{chat_id}/tool_outputs [post]
+func SubmitToolOutputs(c *gin.Context) {
+	conversation := authorizedConversation(c)
+	chatId := c.Param("chat_id")
+
+	var req SubmitToolOutputsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	cozeConv, err := services.GetUserCozeClient(conversation.UserId)
+	if err != nil {
+		utils.BadRequest(c, "初始化Coze对话失败: "+err.Error())
+		return
+	}
+
+	// 回复接在当前分支末尾的提问之后
+	parentId, err := messageService.GetActiveLeafId(conversation.ID)
+	if err != nil {
+		utils.InternalServerError(c, "获取当前分支失败: "+err.Error())
+		return
+	}
+
+	setSSEHeaders(c)
+
+	// 继续原对话，chat_id不变
+	stream, ctx := newChatStream(c, conversation, parentId)
+	stream.track(chatId)
+
+	err = cozeConv.SubmitToolOutputsStream(ctx, conversation.CozeConversationID, chatId, req.ToolOutputs, stream.OnEvent)
+	stream.Finish(err)
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"coze-agent-platform/utils/coze"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhook请求体大小上限
+const maxWebhookBodyBytes = 1 << 20
+
+var webhookService = services.NewWebhookService()
+
+// ReceiveCozeWebhook 接收Coze回调
+// @Summary 接收Coze回调
+// @Description 校验 X-Coze-Signature 签名（HMAC-SHA256(secret, timestamp + "." + body)）和 X-Coze-Timestamp 时间戳，保存事件到收件箱后分发处理。重复的事件ID直接返回成功，处理失败的事件可由管理员重新处理
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param X-Coze-Signature header string true "请求签名"
+// @Param X-Coze-Timestamp header string true "Unix时间戳（秒）"
+// @Param request body services.WebhookPayload true "回调事件"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/webhooks/coze [post]
+func ReceiveCozeWebhook(c *gin.Context) {
+	secret := coze.WebhookSecret()
+	if secret == "" {
+		utils.Error(c, http.StatusServiceUnavailable, "未配置Webhook密钥")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodyBytes+1))
+	if err != nil {
+		utils.BadRequest(c, "读取请求体失败: "+err.Error())
+		return
+	}
+	if len(body) > maxWebhookBodyBytes {
+		utils.BadRequest(c, "请求体过大")
+		return
+	}
+
+	err = coze.VerifyWebhookSignature(secret, c.GetHeader(coze.WebhookTimestampHeader), c.GetHeader(coze.WebhookSignatureHeader), body)
+	if err != nil {
+		utils.Unauthorized(c, err.Error())
+		return
+	}
+
+	var payload services.WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+	if payload.EventID == "" || payload.EventType == "" {
+		utils.BadRequest(c, "缺少event_id或event_type")
+		return
+	}
+
+	event := &models.WebhookEvent{
+		Source:    "coze",
+		EventId:   payload.EventID,
+		EventType: payload.EventType,
+		Payload:   string(body),
+	}
+	created, err := webhookService.ReceiveEvent(event)
+	if err != nil {
+		utils.InternalServerError(c, "保存事件失败: "+err.Error())
+		return
+	}
+	if !created {
+		utils.SuccessWithMessage(c, "重复事件已忽略", nil)
+		return
+	}
+
+	// 事件已保存，处理失败时由管理员重新处理，不要求Coze重试
+	if err := webhookService.ProcessEvent(event); err != nil {
+		log.Printf("处理Webhook事件失败 event_id=%s: %v", event.EventId, err)
+	}
+
+	utils.Success(c, gin.H{
+		"id":     event.ID,
+		"status": event.Status,
+	})
+}
+
+// ListWebhookEvents 获取Webhook事件列表
+// @Summary 获取Webhook事件列表
+// @Description 获取收件箱中的Webhook事件，可按处理状态和事件类型筛选（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param status query string false "处理状态：pending、processed、failed、ignored"
+// @Param event_type query string false "事件类型"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} utils.PageResponse
+// @Failure 403 {object} utils.Response
+// @Router /api/admin/webhooks/events [get]
+func ListWebhookEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 10
+	}
+
+	events, total, err := webhookService.ListWebhookEvents(c.Query("status"), c.Query("event_type"), page, size)
+	if err != nil {
+		utils.InternalServerError(c, "获取Webhook事件失败: "+err.Error())
+		return
+	}
+
+	utils.PageSuccess(c, events, total, page, size)
+}
+
+// GetWebhookEvent 获取Webhook事件详情
+// @Summary 获取Webhook事件详情
+// @Description 获取Webhook事件的原始请求体及处理结果（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "事件ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/webhooks/events/{id} [get]
+func GetWebhookEvent(c *gin.Context) {
+	eventId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "事件ID格式错误")
+		return
+	}
+
+	event, err := webhookService.GetWebhookEventById(uint(eventId))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, event)
+}
+
+// ReprocessWebhookEvent 重新处理Webhook事件
+// @Summary 重新处理Webhook事件
+// @Description 使用保存的请求体重新分发事件，处理器均为幂等实现（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "事件ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/webhooks/events/{id}/reprocess [post]
+func ReprocessWebhookEvent(c *gin.Context) {
+	eventId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "事件ID格式错误")
+		return
+	}
+
+	event, err := webhookService.GetWebhookEventById(uint(eventId))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	if err := webhookService.ProcessEvent(event); err != nil {
+		utils.BadRequest(c, "处理事件失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "处理完成", event)
+}
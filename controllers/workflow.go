@@ -0,0 +1,445 @@
+package controllers
+
+import (
+	"coze-agent-platform/config"
+	"coze-agent-platform/middleware"
+	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RunWorkflowRequest struct {
+	WorkflowID string                 `json:"workflow_id"` // 为空时使用Agent绑定的工作流或全局工作流
+	AgentID    uint                   `json:"agent_id"`
+	Parameters map[string]interface{} `json:"parameters"`
+	IsAsync    bool                   `json:"is_async"` // 异步运行，立即返回运行记录
+}
+
+var workflowRunService = services.NewWorkflowRunService()
+
+// SendMessageWorkFlow 运行工作流
+// @Summary 运行工作流
+// @Description 使用指定参数运行工作流，参数按本地注册的JSON Schema校验，可信参数由服务端注入；异步运行时返回运行记录
+// @Tags 工作流
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body RunWorkflowRequest true "工作流参数"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/conversations/workflow [post]
+func SendMessageWorkFlow(c *gin.Context) {
+	var req RunWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	if !checkAgentAccess(c, req.AgentID) {
+		return
+	}
+
+	workflowId, parameters, err := prepareWorkflowRun(c, &req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	provider, err := getWorkflowProvider(req.AgentID, c.GetUint("user_id"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	requestParameters, _ := json.Marshal(req.Parameters)
+	run := &models.WorkflowRun{
+		WorkflowId: workflowId,
+		UserId:     c.GetUint("user_id"),
+		Parameters: string(requestParameters),
+		IsAsync:    req.IsAsync,
+		Status:     models.WorkflowRunStatusRunning,
+	}
+
+	resp, err := provider.RunWorkflow(&providers.WorkflowRequest{
+		WorkflowID: workflowId,
+		Parameters: parameters,
+		IsAsync:    req.IsAsync,
+	})
+	if err != nil {
+		run.Status = models.WorkflowRunStatusFail
+		run.ErrorMessage = err.Error()
+		if err := workflowRunService.CreateWorkflowRun(run); err != nil {
+			fmt.Printf("保存工作流运行记录失败: %v\n", err)
+		}
+		utils.ErrorWithCause(c, "工作流运行失败: ", err)
+		return
+	}
+
+	run.ExecuteId = resp.ExecuteID
+	run.LogId = resp.LogID
+	run.DebugUrl = resp.DebugURL
+	// 后端未返回execute_id时视为已同步完成
+	if !req.IsAsync || resp.ExecuteID == "" {
+		run.Status = models.WorkflowRunStatusSuccess
+		run.Output = resp.Output
+		run.TokenCount = resp.TokenCount
+	}
+	if err := workflowRunService.CreateWorkflowRun(run); err != nil {
+		utils.InternalServerError(c, "保存工作流运行记录失败: "+err.Error())
+		return
+	}
+
+	if req.IsAsync && resp.ExecuteID != "" {
+		utils.Success(c, run)
+		return
+	}
+	utils.Success(c, resp)
+}
+
+// GetWorkflowRun 获取工作流运行记录
+// @Summary 获取工作流运行记录
+// @Description 根据ID获取工作流运行记录，异步运行的状态由后台任务定期同步
+// @Tags 工作流
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "运行记录ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/workflows/runs/{id} [get]
+func GetWorkflowRun(c *gin.Context) {
+	runId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "运行记录ID格式错误")
+		return
+	}
+
+	run, err := workflowRunService.GetWorkflowRunById(uint(runId))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	// 检查权限
+	if err := services.CanAccessWorkflowRun(middleware.GetActor(c), run); err != nil {
+		utils.Forbidden(c, err.Error())
+		return
+	}
+
+	utils.Success(c, run)
+}
+
+// ListWorkflowRuns 获取工作流运行记录列表
+// @Summary 获取工作流运行记录列表
+// @Description 获取当前用户的工作流运行记录
+// @Tags 工作流
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param status query string false "运行状态 running/success/fail"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} utils.PageResponse
+// @Failure 401 {object} utils.Response
+// @Router /api/workflows/runs [get]
+func ListWorkflowRuns(c *gin.Context) {
+	userId := c.GetUint("user_id")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 10
+	}
+
+	runs, total, err := workflowRunService.GetWorkflowRunsByUserId(userId, c.Query("status"), page, size)
+	if err != nil {
+		utils.InternalServerError(c, "获取工作流运行记录失败: "+err.Error())
+		return
+	}
+
+	utils.PageSuccess(c, runs, total, page, size)
+}
+
+// SendMessageWorkFlowStream 运行工作流(流式)
+// @Summary 运行工作流(流式)
+// @Description 使用指定参数运行工作流，使用 SSE 协议返回流式响应
+// @Tags 工作流
+// @Accept json
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param request body RunWorkflowRequest true "工作流参数"
+// @Success 200 {string} string "SSE 流式响应"
+// @Failure 400 {object} utils.Response
+// @Router /api/conversations/workflow/stream [post]
+func SendMessageWorkFlowStream(c *gin.Context) {
+	var req RunWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	if !checkAgentAccess(c, req.AgentID) {
+		return
+	}
+
+	workflowId, parameters, err := prepareWorkflowRun(c, &req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	provider, err := getWorkflowProvider(req.AgentID, c.GetUint("user_id"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	requestParameters, _ := json.Marshal(req.Parameters)
+	run := &models.WorkflowRun{
+		WorkflowId: workflowId,
+		UserId:     c.GetUint("user_id"),
+		Parameters: string(requestParameters),
+		Status:     models.WorkflowRunStatusRunning,
+	}
+	if err := workflowRunService.CreateWorkflowRun(run); err != nil {
+		utils.InternalServerError(c, "保存工作流运行记录失败: "+err.Error())
+		return
+	}
+
+	setSSEHeaders(c)
+
+	// 定义流式回调函数
+	onEvent := newWorkflowStreamCallback(c, run)
+
+	err = provider.RunWorkflowStream(&providers.WorkflowRequest{
+		WorkflowID: workflowId,
+		Parameters: parameters,
+	}, onEvent)
+	if err != nil {
+		onEvent(providers.Event{Type: providers.EventWorkflowError, Data: providers.WorkflowMessage{Status: "error", Content: err.Error()}})
+		return
+	}
+
+	// 发送结束事件
+	onEvent(providers.Event{Type: providers.EventEnd, Data: providers.StatusMessage{Status: workflowEndStatus(run)}})
+}
+
+type ResumeWorkflowRunRequest struct {
+	ResumeData    string `json:"resume_data" binding:"required"` // 用户对中断节点的回复
+	InterruptType *int   `json:"interrupt_type"`                 // 为空时使用中断事件中的类型
+}
+
+// ResumeWorkflowRun 恢复中断的工作流(流式)
+// @Summary 恢复中断的工作流(流式)
+// @Description 提交用户对问答/中断节点的回复，恢复工作流运行并使用 SSE 协议返回流式响应
+// @Tags 工作流
+// @Accept json
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param id path int true "运行记录ID"
+// @Param request body ResumeWorkflowRunRequest true "恢复数据"
+// @Success 200 {string} string "SSE 流式响应"
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/workflows/runs/{id}/resume [post]
+func ResumeWorkflowRun(c *gin.Context) {
+	runId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "运行记录ID格式错误")
+		return
+	}
+
+	var req ResumeWorkflowRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	run, err := workflowRunService.GetWorkflowRunById(uint(runId))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	// 检查权限
+	if err := services.CanAccessWorkflowRun(middleware.GetActor(c), run); err != nil {
+		utils.Forbidden(c, err.Error())
+		return
+	}
+
+	if run.Status != models.WorkflowRunStatusInterrupted || run.InterruptEventId == "" {
+		utils.BadRequest(c, "工作流未处于中断状态")
+		return
+	}
+
+	cozeConv, err := services.GetUserCozeClient(run.UserId)
+	if err != nil {
+		utils.BadRequest(c, "初始化Coze对话失败: "+err.Error())
+		return
+	}
+
+	eventId := run.InterruptEventId
+	interruptType := run.InterruptType
+	if req.InterruptType != nil {
+		interruptType = *req.InterruptType
+	}
+
+	// 清除中断信息，恢复后可能再次中断
+	run.Status = models.WorkflowRunStatusRunning
+	run.InterruptEventId = ""
+	run.InterruptType = 0
+	run.InterruptNode = ""
+	run.InterruptPrompt = ""
+	if err := workflowRunService.UpdateWorkflowRun(run); err != nil {
+		utils.InternalServerError(c, "更新工作流运行记录失败: "+err.Error())
+		return
+	}
+
+	setSSEHeaders(c)
+
+	onEvent := newWorkflowStreamCallback(c, run)
+
+	err = cozeConv.ResumeWorkflowStream(run.WorkflowId, eventId, req.ResumeData, interruptType, onEvent)
+	if err != nil {
+		onEvent(providers.Event{Type: providers.EventWorkflowError, Data: providers.WorkflowMessage{Status: "error", Content: err.Error()}})
+		return
+	}
+
+	// 发送结束事件
+	onEvent(providers.Event{Type: providers.EventEnd, Data: providers.StatusMessage{Status: workflowEndStatus(run)}})
+}
+
+// 辅助函数：创建工作流流式回调，将事件写入SSE并同步更新运行记录
+func newWorkflowStreamCallback(c *gin.Context, run *models.WorkflowRun) providers.EventHandler {
+	// 检查客户端是否断开连接
+	clientGone := c.Request.Context().Done()
+
+	var output strings.Builder
+	output.WriteString(run.Output)
+
+	saveRun := func() {
+		if err := workflowRunService.UpdateWorkflowRun(run); err != nil {
+			// 错误处理，但不中断流式响应
+			fmt.Printf("保存工作流运行记录失败: %v\n", err)
+		}
+	}
+
+	return func(event providers.Event) {
+		switch event.Type {
+		case providers.EventMessageDelta:
+			if msgData, ok := event.Data.(providers.WorkflowMessage); ok {
+				output.WriteString(msgData.Content)
+				run.LogId = msgData.LogID
+			}
+		case providers.EventWorkflowInterrupt:
+			if interrupt, ok := event.Data.(providers.WorkflowInterrupt); ok {
+				run.Status = models.WorkflowRunStatusInterrupted
+				run.InterruptEventId = interrupt.EventID
+				run.InterruptType = interrupt.InterruptType
+				run.InterruptNode = interrupt.NodeTitle
+				run.InterruptPrompt = interrupt.Prompt
+				run.Output = output.String()
+				saveRun()
+
+				// 附带运行记录ID，客户端据此调用恢复接口
+				interrupt.RunID = run.ID
+				event.Data = interrupt
+			}
+		case providers.EventWorkflowCompleted, providers.EventWorkflowEnd:
+			if run.Status == models.WorkflowRunStatusRunning {
+				run.Status = models.WorkflowRunStatusSuccess
+				run.Output = output.String()
+				saveRun()
+			}
+		case providers.EventWorkflowError:
+			run.Status = models.WorkflowRunStatusFail
+			run.Output = output.String()
+			if msgData, ok := event.Data.(providers.WorkflowMessage); ok {
+				run.ErrorMessage = msgData.Content
+			}
+			saveRun()
+		}
+
+		select {
+		case <-clientGone:
+			return // 客户端已断开连接
+		default:
+			c.SSEvent("message", event)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// 辅助函数：流式运行结束时的状态，工作流中断时为interrupted
+func workflowEndStatus(run *models.WorkflowRun) string {
+	if run.Status == models.WorkflowRunStatusInterrupted {
+		return "interrupted"
+	}
+	return "completed"
+}
+
+// 辅助函数：确定要运行的工作流并构建参数
+func prepareWorkflowRun(c *gin.Context, req *RunWorkflowRequest) (string, map[string]interface{}, error) {
+	workflowId, err := resolveWorkflowId(req.WorkflowID, req.AgentID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	parameters, err := services.BuildWorkflowParameters(workflowId, req.Parameters, workflowCaller(c))
+	if err != nil {
+		return "", nil, err
+	}
+	return workflowId, parameters, nil
+}
+
+// 辅助函数：按请求、Agent绑定、全局配置的顺序确定工作流ID
+func resolveWorkflowId(workflowId string, agentId uint) (string, error) {
+	if workflowId != "" {
+		return workflowId, nil
+	}
+
+	if agentId != 0 {
+		agent, err := services.NewAgentService().GetAgentByID(agentId)
+		if err != nil {
+			return "", err
+		}
+		if agent.CozeWorkflowID != "" {
+			return agent.CozeWorkflowID, nil
+		}
+	}
+
+	return config.GetCozeConfig().WorkFlowID, nil
+}
+
+// 辅助函数：从请求中提取工作流调用方的可信信息
+func workflowCaller(c *gin.Context) services.WorkflowCaller {
+	return services.WorkflowCaller{
+		ClientIP: c.ClientIP(),
+		UserID:   c.GetUint("user_id"),
+	}
+}
+
+// 辅助函数：获取运行工作流使用的对话后端，未指定Agent时使用默认后端
+func getWorkflowProvider(agentId uint, userId uint) (providers.ChatProvider, error) {
+	if agentId == 0 {
+		return services.GetAgentProvider(nil, userId)
+	}
+
+	agent, err := services.NewAgentService().GetAgentByID(agentId)
+	if err != nil {
+		return nil, err
+	}
+	return services.GetAgentProvider(agent, userId)
+}
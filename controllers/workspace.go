@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WorkspaceRequest struct {
+	Name            string `json:"name" binding:"required"`
+	Description     string `json:"description"`
+	Status          *int   `json:"status"`
+	CozeAPIURL      string `json:"coze_api_url"` // 为空时使用全局配置
+	CozeClientID    string `json:"coze_client_id" binding:"required"`
+	CozePublicKeyID string `json:"coze_public_key_id" binding:"required"`
+	CozePrivateKey  string `json:"coze_private_key"` // 创建时必填，更新时为空表示不修改
+	CozeBotID       string `json:"coze_bot_id"`
+}
+
+type SetUserWorkspaceRequest struct {
+	WorkspaceID uint `json:"workspace_id"` // 为0时使用全局配置
+}
+
+var workspaceService = services.NewWorkspaceService()
+
+// ListWorkspaces 获取工作空间列表
+// @Summary 获取工作空间列表
+// @Description 获取工作空间列表，不返回私钥（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} utils.PageResponse
+// @Failure 403 {object} utils.Response
+// @Router /api/admin/workspaces [get]
+func ListWorkspaces(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 || size > 100 {
+		size = 10
+	}
+
+	workspaces, total, err := workspaceService.ListWorkspaces(page, size)
+	if err != nil {
+		utils.InternalServerError(c, "获取工作空间列表失败: "+err.Error())
+		return
+	}
+
+	utils.PageSuccess(c, workspaces, total, page, size)
+}
+
+// CreateWorkspace 创建工作空间
+// @Summary 创建工作空间
+// @Description 创建使用独立Coze OAuth应用凭证的工作空间，私钥加密存储（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body WorkspaceRequest true "工作空间信息"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/admin/workspaces [post]
+func CreateWorkspace(c *gin.Context) {
+	var req WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	workspace := &models.Workspace{Status: 1}
+	applyWorkspaceRequest(workspace, &req)
+
+	if err := workspaceService.CreateWorkspace(workspace, req.CozePrivateKey); err != nil {
+		utils.BadRequest(c, "创建工作空间失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, workspace)
+}
+
+// GetWorkspace 获取工作空间详情
+// @Summary 获取工作空间详情
+// @Description 根据ID获取工作空间，不返回私钥（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "工作空间ID"
+// @Success 200 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/workspaces/{id} [get]
+func GetWorkspace(c *gin.Context) {
+	workspaceId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "工作空间ID格式错误")
+		return
+	}
+
+	workspace, err := workspaceService.GetWorkspaceById(uint(workspaceId))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	utils.Success(c, workspace)
+}
+
+// UpdateWorkspace 更新工作空间
+// @Summary 更新工作空间
+// @Description 更新工作空间信息及Coze凭证，凭证变更后清除缓存的访问令牌（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "工作空间ID"
+// @Param request body WorkspaceRequest true "工作空间信息"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/workspaces/{id} [put]
+func UpdateWorkspace(c *gin.Context) {
+	workspaceId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "工作空间ID格式错误")
+		return
+	}
+
+	var req WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	workspace, err := workspaceService.GetWorkspaceById(uint(workspaceId))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+	applyWorkspaceRequest(workspace, &req)
+
+	if err := workspaceService.UpdateWorkspace(workspace, req.CozePrivateKey); err != nil {
+		utils.BadRequest(c, "更新工作空间失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, workspace)
+}
+
+// DeleteWorkspace 删除工作空间
+// @Summary 删除工作空间
+// @Description 删除没有用户的工作空间（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "工作空间ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/admin/workspaces/{id} [delete]
+func DeleteWorkspace(c *gin.Context) {
+	workspaceId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "工作空间ID格式错误")
+		return
+	}
+
+	if err := workspaceService.DeleteWorkspace(uint(workspaceId)); err != nil {
+		utils.BadRequest(c, "删除工作空间失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// SetUserWorkspace 设置用户所属工作空间
+// @Summary 设置用户所属工作空间
+// @Description 用户的Coze调用使用所属工作空间的凭证，workspace_id 为0时使用全局配置（仅管理员）
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param request body SetUserWorkspaceRequest true "工作空间"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/admin/users/{id}/workspace [put]
+func SetUserWorkspace(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "用户ID格式错误")
+		return
+	}
+
+	var req SetUserWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数格式错误: "+err.Error())
+		return
+	}
+
+	if err := workspaceService.SetUserWorkspace(uint(userId), req.WorkspaceID); err != nil {
+		utils.BadRequest(c, "设置工作空间失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "设置成功", nil)
+}
+
+// 辅助函数：将请求中的工作空间信息写入模型，私钥由服务层加密保存
+func applyWorkspaceRequest(workspace *models.Workspace, req *WorkspaceRequest) {
+	workspace.Name = req.Name
+	workspace.Description = req.Description
+	if req.Status != nil {
+		workspace.Status = *req.Status
+	}
+	workspace.CozeAPIURL = req.CozeAPIURL
+	workspace.CozeClientID = req.CozeClientID
+	workspace.CozePublicKeyID = req.CozePublicKeyID
+	workspace.CozeBotID = req.CozeBotID
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 用户角色
+const RoleAdmin = models.UserRoleAdmin
+
+// AdminAuth 仅允许管理员访问，需在JWTAuth之后使用，角色由JWTAuth从数据库读取
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !GetActor(c).IsAdmin() {
+			utils.Forbidden(c, "需要管理员权限")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"coze-agent-platform/config"
+	"coze-agent-platform/services"
 	"coze-agent-platform/utils"
 	"net/http"
 	"strings"
@@ -36,9 +37,28 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		// 将用户信息存储到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
+		if err := setActor(c, claims); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "User not found",
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
+
+// setActor 将用户信息存储到上下文中。角色以数据库为准：令牌签发后不会更新，
+// 取消管理员后旧令牌不能继续访问其他用户的资源
+func setActor(c *gin.Context, claims *utils.Claims) error {
+	user, err := services.NewUserService().GetUserByID(claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("username", claims.Username)
+	c.Set("role", user.Role)
+	return nil
+}
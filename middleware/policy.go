@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"coze-agent-platform/services"
+	"coze-agent-platform/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 上下文中保存已授权资源的键
+const (
+	ContextConversation = "conversation"
+	ContextAgent        = "agent"
+)
+
+// GetActor 返回JWT中的当前用户，需在JWTAuth之后使用
+func GetActor(c *gin.Context) services.Actor {
+	return services.Actor{
+		UserID: c.GetUint("user_id"),
+		Role:   c.GetInt("role"),
+	}
+}
+
+// AbortWithAccessError 根据授权错误写入403或404响应并中止请求
+func AbortWithAccessError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrForbidden) {
+		utils.Forbidden(c, err.Error())
+	} else {
+		utils.NotFound(c, err.Error())
+	}
+	c.Abort()
+}
+
+// ConversationAccess 加载路由参数id对应的对话并校验当前用户的访问权限，通过后保存到上下文
+func ConversationAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conversationId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "对话ID格式错误")
+			c.Abort()
+			return
+		}
+
+		conversation, err := services.NewConversationService().GetConversationById(uint(conversationId))
+		if err == nil {
+			err = services.CanAccessConversation(GetActor(c), conversation)
+		}
+		if err != nil {
+			AbortWithAccessError(c, err)
+			return
+		}
+
+		c.Set(ContextConversation, conversation)
+		c.Next()
+	}
+}
+
+// AgentAccess 加载路由参数id对应的Agent并校验当前用户的访问权限，通过后保存到上下文
+func AgentAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agentId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "ID格式错误")
+			c.Abort()
+			return
+		}
+
+		agent, err := services.NewAgentService().GetAgentByID(uint(agentId))
+		if err == nil {
+			err = services.CanAccessAgent(GetActor(c), agent)
+		}
+		if err != nil {
+			AbortWithAccessError(c, err)
+			return
+		}
+
+		c.Set(ContextAgent, agent)
+		c.Next()
+	}
+}
@@ -0,0 +1,198 @@
+package middleware_test
+
+import (
+	"coze-agent-platform/config"
+	"coze-agent-platform/models"
+	"coze-agent-platform/routers"
+	"coze-agent-platform/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 测试数据：用户A拥有对话、消息和Agent，用户B是其他普通用户，
+// demoted 曾是管理员，令牌中仍是管理员角色但数据库中已是普通用户
+const (
+	userA uint = iota + 1
+	userB
+	adminUser
+	demotedAdmin
+)
+
+const (
+	conversationA uint = 1
+	replyA        uint = 2 // 对话A中的助手回复
+	agentA        uint = 1
+	missingId     uint = 999
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	config.InitConfig()
+	config.Cfg.JWT.Secret = "test_secret"
+	m.Run()
+}
+
+// 辅助函数：使用内存SQLite作为测试数据库，全文索引只在MySQL中创建
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := models.AutoMigrate(db); err != nil {
+		t.Fatalf("创建表失败: %v", err)
+	}
+
+	previous := models.DB
+	models.DB = db
+	t.Cleanup(func() {
+		models.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	seed := []interface{}{
+		&models.User{ID: userA, Username: "user_a", Email: "a@example.com", Role: models.UserRoleNormal},
+		&models.User{ID: userB, Username: "user_b", Email: "b@example.com", Role: models.UserRoleNormal},
+		&models.User{ID: adminUser, Username: "admin", Email: "admin@example.com", Role: models.UserRoleAdmin},
+		&models.User{ID: demotedAdmin, Username: "demoted", Email: "demoted@example.com", Role: models.UserRoleNormal},
+		&models.Agent{ID: agentA, Name: "agent_a", UserID: userA, Status: 1},
+		&models.Conversation{ID: conversationA, UserId: userA, Title: "A的对话", ActiveMessageId: replyA},
+		&models.Message{ID: 1, ConversationId: conversationA, Role: "user", Content: "你好"},
+		&models.Message{ID: replyA, ConversationId: conversationA, ParentId: 1, Role: "assistant", Content: "你好，有什么可以帮你？"},
+	}
+	for _, record := range seed {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("写入测试数据失败: %v", err)
+		}
+	}
+}
+
+// 辅助函数：使用与线上相同的路由和中间件
+func newTestRouter() *gin.Engine {
+	r := gin.New()
+	routers.SetupRoutes(r)
+	return r
+}
+
+// 辅助函数：以userId签发令牌发起请求，tokenRole 为令牌中的角色
+func request(t *testing.T, r *gin.Engine, path string, userId uint, tokenRole int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := utils.GenerateToken(userId, "test", tokenRole, config.Cfg.JWT.Secret, 3600)
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestResourceAccess(t *testing.T) {
+	setupTestDB(t)
+	r := newTestRouter()
+
+	paths := []string{
+		fmt.Sprintf("/api/conversations/%d", conversationA),
+		fmt.Sprintf("/api/conversations/%d/messages", conversationA),
+		fmt.Sprintf("/api/conversations/%d/branch", conversationA),
+		fmt.Sprintf("/api/conversations/%d/export?format=json", conversationA),
+		fmt.Sprintf("/api/messages/%d/feedback", replyA),
+		fmt.Sprintf("/api/agents/%d", agentA),
+	}
+	actors := []struct {
+		name      string
+		userId    uint
+		tokenRole int
+		want      int
+	}{
+		{"所有者", userA, models.UserRoleNormal, http.StatusOK},
+		{"其他用户", userB, models.UserRoleNormal, http.StatusForbidden},
+		{"其他用户伪造令牌角色", userB, models.UserRoleAdmin, http.StatusForbidden},
+		{"管理员", adminUser, models.UserRoleAdmin, http.StatusOK},
+		{"已取消的管理员", demotedAdmin, models.UserRoleAdmin, http.StatusForbidden},
+		{"用户不存在", missingId, models.UserRoleAdmin, http.StatusUnauthorized},
+	}
+
+	for _, path := range paths {
+		for _, actor := range actors {
+			t.Run(actor.name+" "+path, func(t *testing.T) {
+				w := request(t, r, path, actor.userId, actor.tokenRole)
+				if w.Code != actor.want {
+					t.Errorf("GET %s = %d, want %d, body: %s", path, w.Code, actor.want, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestResourceNotFound(t *testing.T) {
+	setupTestDB(t)
+	r := newTestRouter()
+
+	paths := []string{
+		fmt.Sprintf("/api/conversations/%d", missingId),
+		fmt.Sprintf("/api/conversations/%d/messages", missingId),
+		fmt.Sprintf("/api/conversations/%d/export", missingId),
+		fmt.Sprintf("/api/messages/%d/feedback", missingId),
+		fmt.Sprintf("/api/agents/%d", missingId),
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			if w := request(t, r, path, userB, models.UserRoleNormal); w.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d, want %d", path, w.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestInvalidResourceId(t *testing.T) {
+	setupTestDB(t)
+	r := newTestRouter()
+
+	for _, path := range []string{"/api/conversations/abc", "/api/agents/abc"} {
+		t.Run(path, func(t *testing.T) {
+			if w := request(t, r, path, userA, models.UserRoleNormal); w.Code != http.StatusBadRequest {
+				t.Errorf("GET %s = %d, want %d", path, w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	setupTestDB(t)
+	r := newTestRouter()
+
+	tests := []struct {
+		name      string
+		userId    uint
+		tokenRole int
+		want      int
+	}{
+		{"管理员", adminUser, models.UserRoleAdmin, http.StatusOK},
+		{"普通用户", userA, models.UserRoleNormal, http.StatusForbidden},
+		{"普通用户伪造令牌角色", userA, models.UserRoleAdmin, http.StatusForbidden},
+		{"已取消的管理员", demotedAdmin, models.UserRoleAdmin, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := request(t, r, "/api/admin/webhooks/events", tt.userId, tt.tokenRole); w.Code != tt.want {
+				t.Errorf("GET /api/admin/webhooks/events = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
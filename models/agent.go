@@ -20,10 +20,33 @@ type Agent struct {
 	Status      int    `gorm:"default:1" json:"status"` // 1:启用 0:禁用
 	UserID      uint   `gorm:"not null" json:"user_id"`
 
+	// Coze绑定，为空时使用全局配置
+	CozeBotID      string `gorm:"size:100" json:"coze_bot_id"`
+	CozeWorkflowID string `gorm:"size:100" json:"coze_workflow_id"`
+
+	// 对话后端：coze、openai、fake，为空时使用coze
+	Provider string `gorm:"size:20;default:coze" json:"provider"`
+
+	// Bot由平台在发布时创建，只有平台创建的Bot可以通过发布更新，且不能更换
+	CozeBotManaged bool `gorm:"default:false" json:"coze_bot_managed"`
+
+	// 发布到Coze Bot的状态，发布时CozeBotID为空则在Coze中创建Bot
+	PublishStatus  string     `gorm:"size:20;default:''" json:"publish_status"`
+	PublishVersion string     `gorm:"size:50" json:"publish_version"`
+	PublishError   string     `gorm:"type:text" json:"publish_error,omitempty"`
+	PublishedAt    *time.Time `json:"published_at"`
+
 	// 关联关系
 	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
+// Agent发布状态，为空表示未发布
+const (
+	AgentPublishPublished = "published" // 已发布，与Coze一致
+	AgentPublishModified  = "modified"  // 发布后本地有修改
+	AgentPublishFailed    = "failed"
+)
+
 func (Agent) TableName() string {
 	return "agents"
 }
@@ -32,7 +55,10 @@ type AgentService interface {
 	CreateAgent(agent *Agent) error
 	GetAgentByID(id uint) (*Agent, error)
 	GetAgentsByUserID(userId uint) ([]*Agent, error)
+	ListAgentsByUserID(userId uint, page, pageSize int) ([]*Agent, int64, error)
 	UpdateAgent(agent *Agent) error
+	// IsCozeBotBound Coze Bot是否已绑定到 excludeAgentId 之外的Agent
+	IsCozeBotBound(botId string, excludeAgentId uint) (bool, error)
 	DeleteAgent(id uint) error
 	ListAgents(page, pageSize int) ([]*Agent, int64, error)
 }
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// AgentVariable Agent声明的Bot变量，对话时通过custom_variables传给Coze
+type AgentVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"` // 调用方未传入时使用的默认值
+	Required    bool   `json:"required,omitempty"`
+}
+
+// AgentModelConfig 发布到Coze Bot的模型设置
+type AgentModelConfig struct {
+	ModelID          string  `json:"model_id"`
+	Temperature      float64 `json:"temperature,omitempty"`
+	TopP             float64 `json:"top_p,omitempty"`
+	TopK             int     `json:"top_k,omitempty"`
+	MaxTokens        int     `json:"max_tokens,omitempty"`
+	ContextRound     int     `json:"context_round,omitempty"`
+	ResponseFormat   string  `json:"response_format,omitempty"` // text、markdown、json
+	PresencePenalty  float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64 `json:"frequency_penalty,omitempty"`
+}
+
+// AgentKnowledgeConfig 发布到Coze Bot的知识库绑定
+type AgentKnowledgeConfig struct {
+	DatasetIDs     []string `json:"dataset_ids"`
+	AutoCall       bool     `json:"auto_call"`
+	SearchStrategy int      `json:"search_strategy"` // 0:语义 1:混合 20:全文
+}
+
+// AgentConfig Agent.Config 中保存的配置
+type AgentConfig struct {
+	Variables []AgentVariable `json:"variables,omitempty"`
+
+	// 发布到Coze Bot时使用，SpaceID为空时使用 COZE_SPACE_ID 环境变量
+	SpaceID            string                `json:"space_id,omitempty"`
+	Prologue           string                `json:"prologue,omitempty"`
+	SuggestedQuestions []string              `json:"suggested_questions,omitempty"`
+	Model              *AgentModelConfig     `json:"model,omitempty"`
+	Knowledge          *AgentKnowledgeConfig `json:"knowledge,omitempty"`
+}
+
+// ParseConfig 解析Agent配置，Config为空时返回空配置
+func (a *Agent) ParseConfig() (*AgentConfig, error) {
+	config := &AgentConfig{}
+	if a.Config == "" {
+		return config, nil
+	}
+	if err := json.Unmarshal([]byte(a.Config), config); err != nil {
+		return nil, fmt.Errorf("Agent配置格式错误: %v", err)
+	}
+	return config, nil
+}
+
+// Validate 校验配置，变量名不能为空或重复
+func (c *AgentConfig) Validate() error {
+	names := make(map[string]bool, len(c.Variables))
+	for _, variable := range c.Variables {
+		if variable.Name == "" {
+			return errors.New("变量名不能为空")
+		}
+		if len(variable.Name) > 64 {
+			return fmt.Errorf("变量名过长: %s", variable.Name)
+		}
+		if names[variable.Name] {
+			return fmt.Errorf("变量重复声明: %s", variable.Name)
+		}
+		names[variable.Name] = true
+	}
+
+	if c.Model != nil && c.Model.ModelID == "" {
+		return errors.New("模型设置缺少model_id")
+	}
+	if c.Knowledge != nil && len(c.Knowledge.DatasetIDs) == 0 {
+		return errors.New("知识库绑定缺少dataset_ids")
+	}
+	return nil
+}
+
+// Variable 根据名称获取声明的变量
+func (c *AgentConfig) Variable(name string) (*AgentVariable, bool) {
+	for i := range c.Variables {
+		if c.Variables[i].Name == name {
+			return &c.Variables[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,24 @@
+package models
+
+// AgentDriftItem 本地与Coze不一致的字段
+type AgentDriftItem struct {
+	Field  string `json:"field"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// AgentDrift Agent本地定义与Coze Bot的差异报告
+type AgentDrift struct {
+	AgentID        uint             `json:"agent_id"`
+	BotID          string           `json:"bot_id"`
+	PublishStatus  string           `json:"publish_status"`
+	PublishVersion string           `json:"publish_version"` // 本地记录的发布版本
+	RemoteVersion  string           `json:"remote_version"`
+	InSync         bool             `json:"in_sync"`
+	Items          []AgentDriftItem `json:"items"`
+}
+
+type AgentPublishService interface {
+	PublishAgent(agent *Agent) error
+	GetAgentDrift(agent *Agent) (*AgentDrift, error)
+}
@@ -12,9 +12,19 @@ type Conversation struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	CozeConversationID string `gorm:"column:coze_conversation_id;size:100;not null" json:"coze_conversation_id"`
-	UserId             uint   `gorm:"column:user_id;not null" json:"user_id"`
-	Title              string `gorm:"column:title;size:100" json:"title"`
+	CozeConversationID string     `gorm:"column:coze_conversation_id;size:100;not null" json:"coze_conversation_id"`
+	UserId             uint       `gorm:"column:user_id;not null;index:idx_user_archived_pinned,priority:1" json:"user_id"`
+	AgentId            uint       `gorm:"column:agent_id;index" json:"agent_id"`
+	Title              string     `gorm:"column:title;size:100" json:"title"`
+	WorkflowId         string     `gorm:"column:workflow_id;size:100" json:"workflow_id"` // 绑定的对话流ID，为空时与Bot对话
+	Archived           bool       `gorm:"column:archived;default:false;index:idx_user_archived_pinned,priority:2" json:"archived"`
+	Pinned             bool       `gorm:"column:pinned;default:false;index:idx_user_archived_pinned,priority:3" json:"pinned"`
+	PinnedAt           *time.Time `gorm:"column:pinned_at" json:"pinned_at"`
+	FolderId           uint       `gorm:"column:folder_id;default:0;index" json:"folder_id"`           // 所属文件夹，为0时不在文件夹中
+	ActiveMessageId    uint       `gorm:"column:active_message_id;default:0" json:"active_message_id"` // 当前分支的最后一条消息
+
+	// 标签保存在 conversation_tag 表，由服务层填充
+	Tags []string `gorm:"-" json:"tags"`
 
 	// 关联关系
 	User     User      `gorm:"foreignKey:UserId" json:"user,omitempty"`
@@ -25,12 +35,44 @@ func (Conversation) TableName() string {
 	return "conversation"
 }
 
+// ConversationTag 对话标签，同一对话的标签不重复
+type ConversationTag struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationId uint      `gorm:"column:conversation_id;not null;uniqueIndex:uk_conversation_tag,priority:1" json:"conversation_id"`
+	UserId         uint      `gorm:"column:user_id;not null;index:idx_user_tag,priority:1" json:"user_id"`
+	Tag            string    `gorm:"column:tag;size:20;not null;uniqueIndex:uk_conversation_tag,priority:2;index:idx_user_tag,priority:2" json:"tag"`
+}
+
+func (ConversationTag) TableName() string {
+	return "conversation_tag"
+}
+
+// ConversationFilter 对话列表筛选条件
+type ConversationFilter struct {
+	Archived bool   // 为true时只返回已归档的对话，否则只返回未归档的对话
+	Tag      string // 按标签筛选
+	FolderId *uint  // 按文件夹筛选，为0时返回不在文件夹中的对话，为nil时不筛选
+	AgentId  uint   // 按Agent筛选
+}
+
 type ConversationService interface {
 	CreateConversation(conversation *Conversation) error
 	GetConversationById(id uint) (*Conversation, error)
 	GetConversationByCozeId(cozeConversationId string) (*Conversation, error)
-	GetConversationsByUserId(userId uint) ([]*Conversation, error)
+	GetConversationsByUserId(userId uint, filter ConversationFilter, page, pageSize int) ([]*Conversation, int64, error)
+	// GetAllConversationsByUserId 返回用户的全部对话（含已归档），按创建时间正序排列，用于批量导出
+	GetAllConversationsByUserId(userId uint) ([]*Conversation, error)
+	// ImportConversation 创建对话并按顺序保存消息，每条消息作为上一条的回复，对话后端支持时将历史消息写入远端对话
+	ImportConversation(conversation *Conversation, messages []*Message) error
+	// StartBranchConversation 编辑、重新生成消息或切换分支时调用。远端对话保存了原分支的历史，对话后端支持写入历史时
+	// 为新分支创建远端对话并写入history，返回true，之后只需发送新的提问；否则返回false，需随请求发送完整的分支消息
+	StartBranchConversation(conversation *Conversation, history []*Message) (bool, error)
+	// SetCozeConversationId 将对话之后的消息指向指定的远端对话
+	SetCozeConversationId(conversation *Conversation, cozeConversationId string) error
 	UpdateConversation(conversation *Conversation) error
+	SetConversationTags(conversation *Conversation, tags []string) error
+	LoadConversationTags(conversations ...*Conversation) error
 	DeleteConversation(id uint) error
 	ListConversations(page, pageSize int) ([]*Conversation, int64, error)
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConversationFolder 用户自定义的对话文件夹
+type ConversationFolder struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserId uint   `gorm:"column:user_id;not null;index" json:"user_id"`
+	Name   string `gorm:"column:name;size:50;not null" json:"name"`
+	Sort   int    `gorm:"column:sort;default:0" json:"sort"` // 越小越靠前
+}
+
+func (ConversationFolder) TableName() string {
+	return "conversation_folder"
+}
+
+type ConversationFolderService interface {
+	CreateFolder(folder *ConversationFolder) error
+	GetFolderById(id uint) (*ConversationFolder, error)
+	ListFoldersByUserId(userId uint) ([]*ConversationFolder, error)
+	UpdateFolder(folder *ConversationFolder) error
+	DeleteFolder(id uint) error
+}
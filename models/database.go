@@ -35,17 +35,58 @@ func InitDB() {
 	}
 
 	// 自动迁移
-	err = DB.AutoMigrate(
+	err = AutoMigrate(DB)
+
+	if err != nil {
+		log.Printf("数据库迁移失败: %v", err)
+		return
+	}
+
+	log.Println("数据库初始化完成")
+}
+
+// 全文索引使用ngram分词，索引名与 sql/schema.sql 一致
+var fullTextIndexes = []struct {
+	table  string
+	name   string
+	column string
+}{
+	{"conversation", "ft_title", "title"},
+	{"message", "ft_content", "content"},
+}
+
+// AutoMigrate 创建或更新数据表。全文索引是MySQL专有的，仅在MySQL中创建
+func AutoMigrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&User{},
 		&Agent{},
 		&Conversation{},
+		&ConversationTag{},
+		&ConversationFolder{},
 		&Message{},
+		&MessageFeedback{},
+		&MessageFeedbackReason{},
+		&WorkflowRun{},
+		&KnowledgeDocument{},
+		&KnowledgeDataset{},
+		&WebhookEvent{},
+		&Workspace{},
 	)
-
 	if err != nil {
-		log.Printf("数据库迁移失败: %v", err)
-		return
+		return err
 	}
 
-	log.Println("数据库初始化完成")
+	if db.Dialector.Name() != "mysql" {
+		return nil
+	}
+	for _, index := range fullTextIndexes {
+		if db.Migrator().HasIndex(index.table, index.name) {
+			continue
+		}
+		sql := fmt.Sprintf("CREATE FULLTEXT INDEX %s ON %s (%s) WITH PARSER ngram", index.name, index.table, index.column)
+		if err := db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
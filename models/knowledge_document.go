@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 知识库文档处理状态，与Coze文档状态保持一致
+const (
+	KnowledgeDocumentStatusProcessing = 0
+	KnowledgeDocumentStatusCompleted  = 1
+	KnowledgeDocumentStatusFailed     = 9
+)
+
+type KnowledgeDocument struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	DatasetId      string `gorm:"column:dataset_id;size:100;not null;index" json:"dataset_id"`
+	DocumentId     string `gorm:"column:document_id;size:100;not null;uniqueIndex" json:"document_id"`
+	UserId         uint   `gorm:"column:user_id;not null;index" json:"user_id"`
+	AgentId        uint   `gorm:"column:agent_id;index" json:"agent_id"`
+	Name           string `gorm:"column:name;size:255;not null" json:"name"`
+	SourceType     string `gorm:"column:source_type;size:10;not null" json:"source_type"` // file、url
+	SourceUrl      string `gorm:"column:source_url;size:1000" json:"source_url"`
+	Size           int64  `gorm:"column:size;default:0" json:"size"`
+	Status         int    `gorm:"column:status;default:0" json:"status"` // 0:处理中 1:已完成 9:失败
+	Progress       int    `gorm:"column:progress;default:0" json:"progress"`
+	StatusDescript string `gorm:"column:status_descript;size:255" json:"status_descript"`
+}
+
+func (KnowledgeDocument) TableName() string {
+	return "knowledge_document"
+}
+
+// KnowledgeDataset 通过平台创建的知识库，只有创建者和管理员可以上传、查看和删除其中的文档
+type KnowledgeDataset struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	DatasetId string `gorm:"column:dataset_id;size:100;not null;uniqueIndex" json:"dataset_id"`
+	SpaceId   string `gorm:"column:space_id;size:100;not null" json:"space_id"`
+	UserId    uint   `gorm:"column:user_id;not null;index" json:"user_id"`
+	Name      string `gorm:"column:name;size:255;not null" json:"name"`
+}
+
+func (KnowledgeDataset) TableName() string {
+	return "knowledge_dataset"
+}
+
+type KnowledgeDocumentService interface {
+	CreateKnowledgeDocument(document *KnowledgeDocument) error
+	GetKnowledgeDocumentById(id uint) (*KnowledgeDocument, error)
+	GetKnowledgeDocumentsByUserId(userId uint, datasetId string, agentId uint, page, pageSize int) ([]*KnowledgeDocument, int64, error)
+	UpdateKnowledgeDocument(document *KnowledgeDocument) error
+	DeleteKnowledgeDocument(id uint) error
+	RefreshKnowledgeDocumentProgress(document *KnowledgeDocument) error
+	CreateKnowledgeDataset(dataset *KnowledgeDataset) error
+	GetKnowledgeDatasetByDatasetId(datasetId string) (*KnowledgeDataset, error)
+}
@@ -6,19 +6,44 @@ import (
 	"gorm.io/gorm"
 )
 
+// 消息状态
+const (
+	MessageStatusCompleted = "completed"
+	MessageStatusCancelled = "cancelled" // 生成被取消，内容为部分回复
+)
+
+// 与Coze对话历史的同步状态
+const (
+	MessageSyncPending   = ""           // 尚未同步
+	MessageSyncSynced    = "synced"     // 与Coze消息一致
+	MessageSyncDiverged  = "diverged"   // 内容与Coze消息不一致
+	MessageSyncLocalOnly = "local_only" // Coze对话中不存在
+)
+
 type Message struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	CozeMessageId  string `gorm:"column:coze_message_id;size:100;not null" json:"coze_message_id"`
-	ConversationId uint   `gorm:"column:conversation_id;not null" json:"conversation_id"`
-	ModelId        uint   `gorm:"column:model_id;not null" json:"model_id"`
-	Metadata       string `gorm:"column:metadata;size:255" json:"metadata"`
-	Role           string `gorm:"column:role;size:10;not null" json:"role"` // user、assistant、system
-	Content        string `gorm:"column:content;type:text;not null" json:"content"`
-	Tokens         int    `gorm:"column:tokens;default:0" json:"tokens"`
+	CozeMessageId  string       `gorm:"column:coze_message_id;size:100;not null" json:"coze_message_id"`
+	ChatId         string       `gorm:"column:chat_id;size:100;index" json:"chat_id"`
+	ConversationId uint         `gorm:"column:conversation_id;not null" json:"conversation_id"`
+	ParentId       uint         `gorm:"column:parent_id;default:0;index" json:"parent_id"` // 上一条消息，为0时是对话的第一条消息；编辑和重新生成会产生同一父消息下的分支
+	ModelId        uint         `gorm:"column:model_id;not null" json:"model_id"`
+	Metadata       string       `gorm:"column:metadata;size:255" json:"metadata"`
+	Role           string       `gorm:"column:role;size:10;not null" json:"role"`         // user、assistant、system
+	Content        string       `gorm:"column:content;type:text;not null" json:"content"` // 纯文本内容，多模态消息为其中的文本片段
+	Parts          MessageParts `gorm:"column:parts;type:json" json:"parts,omitempty"`    // 多模态消息的内容片段
+	Tokens         int          `gorm:"column:tokens;default:0" json:"tokens"`
+	Status         string       `gorm:"column:status;size:20;default:completed" json:"status"` // completed、cancelled
+	SyncStatus     string       `gorm:"column:sync_status;size:20;index" json:"sync_status"`   // 空、synced、diverged、local_only
+
+	// 消息所在的Coze对话，编辑、重新生成和切换分支会新建Coze对话，为空时属于对话当前的Coze对话
+	CozeConversationId string `gorm:"column:coze_conversation_id;size:100" json:"coze_conversation_id"`
+
+	// 同一父消息下的全部分支消息ID（含自身），仅在获取当前分支时填充
+	SiblingIds []uint `gorm:"-" json:"sibling_ids,omitempty"`
 
 	// 关联关系
 	Conversation Conversation `gorm:"foreignKey:ConversationId" json:"conversation,omitempty"`
@@ -28,11 +53,36 @@ func (Message) TableName() string {
 	return "message"
 }
 
+// ResolveCozeConversationId 返回消息所在的Coze对话ID
+func (m *Message) ResolveCozeConversationId(conversation *Conversation) string {
+	if m.CozeConversationId != "" {
+		return m.CozeConversationId
+	}
+	return conversation.CozeConversationID
+}
+
 type MessageService interface {
 	CreateMessage(message *Message) error
 	GetMessageById(id uint) (*Message, error)
 	GetMessagesByConversationId(conversationId uint, limit int) ([]*Message, error)
+	// GetMessagesPageByConversationId 按页码分页，allBranches 为false时只返回当前分支上的消息
+	GetMessagesPageByConversationId(conversationId uint, allBranches bool, page, pageSize int) ([]*Message, int64, error)
+	// GetMessagesByCursor 按消息ID游标分页，结果按时间正序排列，返回是否还有更多消息。allBranches 为false时只返回当前分支上的消息
+	GetMessagesByCursor(conversationId uint, allBranches bool, beforeId, afterId uint, limit int) ([]*Message, bool, error)
+	// GetRecentMessages 返回当前分支上最近的 limit 条消息，按时间正序排列
 	GetRecentMessages(conversationId uint, limit int) ([]*Message, error)
+	// GetMessagePath 返回从第一条消息到 messageId 的路径上最近的 limit 条消息，limit 为0时不限制
+	GetMessagePath(messageId uint, limit int) ([]*Message, error)
+	// GetActiveBranch 返回当前分支上的消息并填充 SiblingIds
+	GetActiveBranch(conversationId uint, limit int) ([]*Message, error)
+	// GetActiveLeafId 返回当前分支的最后一条消息ID，对话没有消息时返回0
+	GetActiveLeafId(conversationId uint) (uint, error)
+	// AppendMessage 保存消息并将对话的当前分支指向该消息，调用方需设置 ParentId
+	AppendMessage(message *Message) error
+	// SetActiveMessage 将对话的当前分支指向指定消息
+	SetActiveMessage(conversationId uint, messageId uint) error
+	// SwitchBranch 切换到包含指定消息的分支，沿最新的子消息找到分支末尾，返回新的当前消息ID
+	SwitchBranch(conversationId uint, messageId uint) (uint, error)
 	UpdateMessage(message *Message) error
 	DeleteMessage(id uint) error
 	ListMessages(page, pageSize int) ([]*Message, int64, error)
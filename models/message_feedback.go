@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+)
+
+// 评价结果
+const (
+	FeedbackRatingPositive = 1
+	FeedbackRatingNegative = -1
+)
+
+// 评价同步到对话后端的状态
+const (
+	FeedbackForwardSynced      = "synced"      // 已同步到Coze
+	FeedbackForwardFailed      = "failed"      // 同步失败
+	FeedbackForwardUnsupported = "unsupported" // 对话后端不支持或消息没有Coze消息ID
+)
+
+// MessageFeedback 用户对一条回复的评价，每个用户对同一条消息只保留一条评价
+type MessageFeedback struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index:idx_agent_created,priority:2" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	MessageId      uint   `gorm:"column:message_id;not null;uniqueIndex:uk_message_user,priority:1" json:"message_id"`
+	UserId         uint   `gorm:"column:user_id;not null;uniqueIndex:uk_message_user,priority:2" json:"user_id"`
+	ConversationId uint   `gorm:"column:conversation_id;not null;index" json:"conversation_id"`
+	AgentId        uint   `gorm:"column:agent_id;default:0;index:idx_agent_created,priority:1" json:"agent_id"`
+	Rating         int    `gorm:"column:rating;not null" json:"rating"` // 1-好评，-1-差评
+	Comment        string `gorm:"column:comment;size:1000" json:"comment"`
+	ForwardStatus  string `gorm:"column:forward_status;size:20" json:"forward_status"` // synced、failed、unsupported
+	ForwardError   string `gorm:"column:forward_error;size:500" json:"forward_error,omitempty"`
+
+	// 原因标签保存在 message_feedback_reason 表，由服务层填充
+	Reasons []string `gorm:"-" json:"reasons"`
+}
+
+func (MessageFeedback) TableName() string {
+	return "message_feedback"
+}
+
+// MessageFeedbackReason 评价的原因标签
+type MessageFeedbackReason struct {
+	ID         uint   `gorm:"primarykey" json:"id"`
+	FeedbackId uint   `gorm:"column:feedback_id;not null;index" json:"feedback_id"`
+	Reason     string `gorm:"column:reason;size:20;not null;index" json:"reason"`
+}
+
+func (MessageFeedbackReason) TableName() string {
+	return "message_feedback_reason"
+}
+
+// FeedbackReportFilter 评价报表的筛选条件
+type FeedbackReportFilter struct {
+	AgentId   uint       // 为0时统计全部Agent
+	StartTime *time.Time // 评价时间不早于
+	EndTime   *time.Time // 评价时间早于
+}
+
+// FeedbackStats 评价数量及满意度
+type FeedbackStats struct {
+	Total            int64   `json:"total"`
+	Positive         int64   `json:"positive"`
+	Negative         int64   `json:"negative"`
+	SatisfactionRate float64 `json:"satisfaction_rate"` // 好评数 / 总数
+}
+
+// AgentFeedbackStats 单个Agent的评价统计，AgentId 为0表示未使用Agent的对话
+type AgentFeedbackStats struct {
+	AgentId uint `json:"agent_id"`
+	FeedbackStats
+}
+
+// FeedbackReasonCount 差评原因及出现次数
+type FeedbackReasonCount struct {
+	Reason string `json:"reason"`
+	Count  int64  `json:"count"`
+}
+
+// ConversationFeedbackStats 单个对话的评价统计
+type ConversationFeedbackStats struct {
+	ConversationId uint   `json:"conversation_id"`
+	Title          string `json:"title"`
+	UserId         uint   `json:"user_id"`
+	AgentId        uint   `json:"agent_id"`
+	Positive       int64  `json:"positive"`
+	Negative       int64  `json:"negative"`
+}
+
+// FeedbackReport 评价报表
+type FeedbackReport struct {
+	FeedbackStats
+	Agents             []*AgentFeedbackStats        `json:"agents"`
+	TopNegativeReasons []*FeedbackReasonCount       `json:"top_negative_reasons"`
+	WorstConversations []*ConversationFeedbackStats `json:"worst_conversations"`
+}
+
+type FeedbackService interface {
+	// SubmitFeedback 保存评价，同一用户对同一消息的评价会被覆盖，并尽量同步到对话后端
+	SubmitFeedback(feedback *MessageFeedback, message *Message, conversation *Conversation) error
+	GetFeedback(messageId uint, userId uint) (*MessageFeedback, error)
+	DeleteFeedback(feedback *MessageFeedback, message *Message, conversation *Conversation) error
+	GetFeedbackReport(filter FeedbackReportFilter) (*FeedbackReport, error)
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// 消息内容片段类型
+const (
+	MessagePartText  = "text"
+	MessagePartImage = "image"
+	MessagePartFile  = "file"
+)
+
+// MessagePart 多模态消息的内容片段，图片和文件通过file_id或file_url二选一指定
+type MessagePart struct {
+	Type    string `json:"type"` // text、image、file
+	Text    string `json:"text,omitempty"`
+	FileID  string `json:"file_id,omitempty"` // /api/common/upload/file 返回的file_id
+	FileURL string `json:"file_url,omitempty"`
+}
+
+// MessageParts 以JSON保存在消息表中
+type MessageParts []MessagePart
+
+func (p MessageParts) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (p *MessageParts) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("消息内容片段格式错误")
+	}
+	if len(data) == 0 {
+		*p = nil
+		return nil
+	}
+	return json.Unmarshal(data, p)
+}
+
+// Text 拼接所有文本片段，作为消息的纯文本内容
+func (p MessageParts) Text() string {
+	texts := make([]string, 0, len(p))
+	for _, part := range p {
+		if part.Type == MessagePartText && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// HasAttachment 是否包含图片或文件
+func (p MessageParts) HasAttachment() bool {
+	for _, part := range p {
+		if part.Type != MessagePartText {
+			return true
+		}
+	}
+	return false
+}
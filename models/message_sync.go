@@ -0,0 +1,18 @@
+package models
+
+// MessageSyncResult 单个对话的消息同步结果
+type MessageSyncResult struct {
+	ConversationId uint `json:"conversation_id"`
+	RemoteCount    int  `json:"remote_count"` // Coze对话中的问答消息数
+	LocalCount     int  `json:"local_count"`
+	Backfilled     int  `json:"backfilled"` // 补录的本地缺失消息数
+	Duplicated     int  `json:"duplicated"` // 与已有消息重复、未补录的Coze消息数
+	Updated        int  `json:"updated"`    // 修正为真实Coze消息ID的消息数
+	Diverged       int  `json:"diverged"`
+	LocalOnly      int  `json:"local_only"`
+}
+
+type MessageSyncService interface {
+	SyncConversationMessages(conversationId uint) (*MessageSyncResult, error)
+	GetPendingSyncConversationIds(limit int) ([]uint, error)
+}
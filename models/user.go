@@ -6,6 +6,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色
+const (
+	UserRoleNormal = 1
+	UserRoleAdmin  = 2
+)
+
 type User struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -19,6 +25,8 @@ type User struct {
 	Avatar   string `json:"avatar"`
 	Status   int    `gorm:"default:1" json:"status"` // 1:正常 0:禁用
 	Role     int    `gorm:"default:1" json:"role"`   // 1:普通用户 2:管理员
+
+	WorkspaceId uint `gorm:"column:workspace_id;default:0;index" json:"workspace_id"` // 所属工作空间，0表示使用全局Coze配置
 }
 
 func (User) TableName() string {
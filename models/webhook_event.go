@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook事件处理状态
+const (
+	WebhookEventPending   = "pending"
+	WebhookEventProcessed = "processed"
+	WebhookEventFailed    = "failed"
+	WebhookEventIgnored   = "ignored" // 没有对应的事件处理器
+)
+
+// WebhookEvent 收到的Webhook事件，处理失败的事件可重新处理
+type WebhookEvent struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Source      string     `gorm:"column:source;size:20;not null;uniqueIndex:idx_source_event_id" json:"source"` // coze
+	EventId     string     `gorm:"column:event_id;size:100;not null;uniqueIndex:idx_source_event_id" json:"event_id"`
+	EventType   string     `gorm:"column:event_type;size:100;not null;index" json:"event_type"`
+	Payload     string     `gorm:"column:payload;type:longtext" json:"payload"`
+	Status      string     `gorm:"column:status;size:20;not null;index" json:"status"` // pending、processed、failed、ignored
+	Attempts    int        `gorm:"column:attempts;default:0" json:"attempts"`
+	Error       string     `gorm:"column:error;type:text" json:"error"`
+	ProcessedAt *time.Time `gorm:"column:processed_at" json:"processed_at"`
+}
+
+func (WebhookEvent) TableName() string {
+	return "webhook_event"
+}
+
+type WebhookService interface {
+	// ReceiveEvent 保存事件到收件箱，同一来源的事件ID已存在时返回false
+	ReceiveEvent(event *WebhookEvent) (bool, error)
+	ProcessEvent(event *WebhookEvent) error
+	GetWebhookEventById(id uint) (*WebhookEvent, error)
+	ListWebhookEvents(status, eventType string, page, pageSize int) ([]*WebhookEvent, int64, error)
+}
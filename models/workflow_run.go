@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 工作流运行状态
+const (
+	WorkflowRunStatusRunning = "running"
+	WorkflowRunStatusSuccess = "success"
+	WorkflowRunStatusFail    = "fail"
+
+	WorkflowRunStatusInterrupted = "interrupted" // 等待用户输入后恢复
+)
+
+type WorkflowRun struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	WorkflowId   string `gorm:"column:workflow_id;size:100;not null;index" json:"workflow_id"`
+	UserId       uint   `gorm:"column:user_id;not null;index" json:"user_id"`
+	ExecuteId    string `gorm:"column:execute_id;size:100;index" json:"execute_id"`
+	Parameters   string `gorm:"column:parameters;type:text" json:"parameters"` // 调用方传入的参数，不含服务端注入的可信参数
+	IsAsync      bool   `gorm:"column:is_async;default:false" json:"is_async"`
+	Status       string `gorm:"column:status;size:20;not null;index" json:"status"` // running、success、fail、interrupted
+	Output       string `gorm:"column:output;type:longtext" json:"output"`
+	TokenCount   int    `gorm:"column:token_count;default:0" json:"token_count"`
+	LogId        string `gorm:"column:log_id;size:100" json:"log_id"`
+	DebugUrl     string `gorm:"column:debug_url;size:500" json:"debug_url"`
+	ErrorMessage string `gorm:"column:error_message;type:text" json:"error_message"`
+
+	// 中断信息，恢复运行时使用
+	InterruptEventId string `gorm:"column:interrupt_event_id;size:100" json:"interrupt_event_id"`
+	InterruptType    int    `gorm:"column:interrupt_type;default:0" json:"interrupt_type"`
+	InterruptNode    string `gorm:"column:interrupt_node;size:100" json:"interrupt_node"`
+	InterruptPrompt  string `gorm:"column:interrupt_prompt;type:text" json:"interrupt_prompt"`
+
+	// 异步运行连续查询运行结果失败的次数，达到上限后标记为失败
+	SyncAttempts int `gorm:"column:sync_attempts;default:0" json:"-"`
+}
+
+func (WorkflowRun) TableName() string {
+	return "workflow_run"
+}
+
+type WorkflowRunService interface {
+	CreateWorkflowRun(run *WorkflowRun) error
+	GetWorkflowRunById(id uint) (*WorkflowRun, error)
+	GetWorkflowRunsByUserId(userId uint, status string, page, pageSize int) ([]*WorkflowRun, int64, error)
+	GetRunningAsyncWorkflowRuns(limit int) ([]*WorkflowRun, error)
+	UpdateWorkflowRun(run *WorkflowRun) error
+	SyncWorkflowRunStatus(run *WorkflowRun) error
+	FailStaleStreamWorkflowRuns(before time.Time) (int64, error)
+}
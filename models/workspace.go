@@ -0,0 +1,44 @@
+package models
+
+import (
+	"coze-agent-platform/config"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace 工作空间（租户），使用独立的Coze OAuth应用凭证
+type Workspace struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"column:name;size:100;not null" json:"name"`
+	Description string `gorm:"column:description;size:500" json:"description"`
+	Status      int    `gorm:"column:status;default:1" json:"status"` // 1:正常 0:禁用
+
+	CozeAPIURL      string `gorm:"column:coze_api_url;size:255" json:"coze_api_url"` // 为空时使用全局配置
+	CozeClientID    string `gorm:"column:coze_client_id;size:100;not null" json:"coze_client_id"`
+	CozePublicKeyID string `gorm:"column:coze_public_key_id;size:100;not null" json:"coze_public_key_id"`
+	CozePrivateKey  string `gorm:"column:coze_private_key;type:text;not null" json:"-"` // AES-GCM加密存储
+	CozeBotID       string `gorm:"column:coze_bot_id;size:100" json:"coze_bot_id"`      // 工作空间默认Bot
+}
+
+func (Workspace) TableName() string {
+	return "workspace"
+}
+
+type WorkspaceService interface {
+	// CreateWorkspace 创建工作空间，privateKey 为明文私钥，保存前加密
+	CreateWorkspace(workspace *Workspace, privateKey string) error
+	GetWorkspaceById(id uint) (*Workspace, error)
+	ListWorkspaces(page, pageSize int) ([]*Workspace, int64, error)
+	// UpdateWorkspace 更新工作空间，privateKey 为空时保留原私钥
+	UpdateWorkspace(workspace *Workspace, privateKey string) error
+	DeleteWorkspace(id uint) error
+	// SetUserWorkspace 设置用户所属工作空间，workspaceId 为0时使用全局配置
+	SetUserWorkspace(userId uint, workspaceId uint) error
+	// GetWorkspaceCozeConfig 返回工作空间解密后的Coze配置
+	GetWorkspaceCozeConfig(id uint) (*config.CozeConfig, error)
+}
@@ -0,0 +1,116 @@
+package providers
+
+// EventType 流式事件类型
+type EventType string
+
+const (
+	// 对话事件
+	EventChatCreated     EventType = "chat_created"
+	EventMessageDelta    EventType = "message_delta"
+	EventChatCompleted   EventType = "chat_completed"
+	EventChatFailed      EventType = "chat_failed"
+	EventToolOutput      EventType = "tool_output"
+	EventRequiresAction  EventType = "requires_action"
+	EventConversationEnd EventType = "conversation_end"
+	EventOther           EventType = "other_event"
+
+	// 工作流事件，增量输出同样使用 EventMessageDelta
+	EventWorkflowInterrupt EventType = "workflow_interrupt"
+	EventWorkflowError     EventType = "workflow_error"
+	EventWorkflowCompleted EventType = "workflow_complated"
+	EventWorkflowEnd       EventType = "workflow_end"
+
+	// 由服务端在流结束或出错时发送
+	EventError     EventType = "error"
+	EventEnd       EventType = "end"
+	EventCancelled EventType = "cancelled"
+	EventSpeech    EventType = "speech"
+)
+
+// Event 流式事件，Data为下方定义的事件数据或后端特有的数据
+type Event struct {
+	Type EventType   `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// EventHandler 流式事件回调
+type EventHandler func(event Event)
+
+// Usage token用量
+type Usage struct {
+	TokenCount  int `json:"token_count"`
+	OutputCount int `json:"output_count"`
+	InputCount  int `json:"input_count"`
+}
+
+// ChatCreated 对话已创建，ChatID可用于取消对话或提交工具结果
+type ChatCreated struct {
+	ChatID         string `json:"chat_id"`
+	ConversationID string `json:"conversation_id"`
+}
+
+// MessageDelta 对话消息增量
+type MessageDelta struct {
+	MessageID string `json:"message_id,omitempty"` // 后端消息ID，部分后端不提供
+	Content   string `json:"content"`
+	Role      string `json:"role"`
+	Type      string `json:"type"`
+}
+
+// ChatCompleted 对话完成
+type ChatCompleted struct {
+	ChatID string `json:"chat_id"`
+	Usage  Usage  `json:"usage"`
+}
+
+// ChatFailed 对话失败
+type ChatFailed struct {
+	ErrorCode int    `json:"error_code"`
+	ErrorMsg  string `json:"error_msg"`
+}
+
+// ConversationEnd 对话流结束，Status为completed或requires_action
+type ConversationEnd struct {
+	Status string `json:"status"`
+	LogID  string `json:"log_id"`
+}
+
+// WorkflowMessage 工作流增量输出、完成、结束或出错
+type WorkflowMessage struct {
+	Status  string `json:"status"`
+	LogID   string `json:"log_id"`
+	Content string `json:"content"`
+}
+
+// WorkflowInterrupt 工作流中断，等待用户输入后恢复
+type WorkflowInterrupt struct {
+	Status        string `json:"status"`
+	LogID         string `json:"log_id"`
+	NodeTitle     string `json:"node_title"`
+	Prompt        string `json:"prompt"`
+	EventID       string `json:"event_id"`
+	InterruptType int    `json:"interrupt_type"`
+	RunID         uint   `json:"run_id,omitempty"` // 本地运行记录ID
+}
+
+// ChatCancelled 对话被取消，MessageID为保存的部分回复的本地消息ID
+type ChatCancelled struct {
+	ChatID    string `json:"chat_id"`
+	MessageID uint   `json:"message_id,omitempty"`
+}
+
+// StatusMessage 服务端发送的状态或错误信息
+type StatusMessage struct {
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Speech 最终回复合成的语音片段，长回复按句切分后依次发送，Audio为base64编码的音频数据
+type Speech struct {
+	MessageID   uint   `json:"message_id"`
+	Index       int    `json:"index"`
+	Count       int    `json:"count"`
+	Format      string `json:"format"`
+	ContentType string `json:"content_type"`
+	Audio       string `json:"audio"`
+}
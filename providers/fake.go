@@ -0,0 +1,157 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// FakeToolPrefix 最后一条用户消息以该前缀开头时，FakeProvider 请求调用前缀后指定名称的工具
+const FakeToolPrefix = "tool:"
+
+// FakeProvider 内存中的确定性对话后端，用于测试和本地开发。
+// 回复内容为 "echo: " 加最后一条用户消息，token数按字符计算。
+// 用户消息为 "tool:<名称>" 时不回复，流式对话发送 requires_action 事件。
+type FakeProvider struct {
+	seq int64
+}
+
+var _ ChatProvider = (*FakeProvider)(nil)
+
+func NewFakeProvider() ChatProvider {
+	return &FakeProvider{}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) nextID(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, atomic.AddInt64(&p.seq, 1))
+}
+
+func (p *FakeProvider) CreateConversation(botID string, metaData map[string]string) (string, error) {
+	return p.nextID("fake_conv"), nil
+}
+
+func (p *FakeProvider) Chat(req *ChatRequest) (*ChatResult, error) {
+	content := p.reply(req)
+	return &ChatResult{
+		ChatID:         p.nextID("fake_chat"),
+		ConversationID: req.ConversationID,
+		MessageID:      p.nextID("fake_msg"),
+		Content:        content,
+		Usage:          p.usage(req, content),
+	}, nil
+}
+
+func (p *FakeProvider) ChatStream(ctx context.Context, req *ChatRequest, onEvent EventHandler) error {
+	chatID := p.nextID("fake_chat")
+	onEvent(Event{Type: EventChatCreated, Data: ChatCreated{ChatID: chatID, ConversationID: req.ConversationID}})
+
+	if name, ok := p.toolCall(req); ok {
+		onEvent(Event{Type: EventRequiresAction, Data: map[string]interface{}{
+			"chat_id":         chatID,
+			"conversation_id": req.ConversationID,
+			"tool_calls": []map[string]interface{}{{
+				"id":   p.nextID("fake_call"),
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      name,
+					"arguments": "{}",
+				},
+			}},
+		}})
+		onEvent(Event{Type: EventConversationEnd, Data: ConversationEnd{Status: "requires_action"}})
+		return nil
+	}
+
+	content := p.reply(req)
+	for _, word := range strings.SplitAfter(content, " ") {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		onEvent(Event{Type: EventMessageDelta, Data: MessageDelta{
+			Content: word,
+			Role:    "assistant",
+			Type:    "answer",
+		}})
+	}
+
+	onEvent(Event{Type: EventChatCompleted, Data: ChatCompleted{
+		ChatID: chatID,
+		Usage:  p.usage(req, content),
+	}})
+	onEvent(Event{Type: EventConversationEnd, Data: ConversationEnd{Status: "completed"}})
+	return nil
+}
+
+func (p *FakeProvider) CancelChat(conversationID string, chatID string) error {
+	return nil
+}
+
+// RunWorkflow 以JSON形式原样返回工作流参数，始终同步完成
+func (p *FakeProvider) RunWorkflow(req *WorkflowRequest) (*WorkflowResult, error) {
+	output, err := json.Marshal(req.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkflowResult{
+		Output:     string(output),
+		TokenCount: len([]rune(string(output))),
+	}, nil
+}
+
+func (p *FakeProvider) RunWorkflowStream(req *WorkflowRequest, onEvent EventHandler) error {
+	result, err := p.RunWorkflow(req)
+	if err != nil {
+		return err
+	}
+
+	onEvent(Event{Type: EventMessageDelta, Data: WorkflowMessage{Status: "delta", Content: result.Output}})
+	onEvent(Event{Type: EventWorkflowCompleted, Data: WorkflowMessage{Status: "completed"}})
+	return nil
+}
+
+func (p *FakeProvider) UploadFile(file io.Reader) (string, error) {
+	if _, err := io.Copy(io.Discard, file); err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	return p.nextID("fake_file"), nil
+}
+
+func (p *FakeProvider) reply(req *ChatRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return "echo: " + req.Messages[i].Content
+		}
+	}
+	return "echo:"
+}
+
+// toolCall 最后一条用户消息以 FakeToolPrefix 开头时返回要调用的工具名称
+func (p *FakeProvider) toolCall(req *ChatRequest) (string, bool) {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			name := strings.TrimPrefix(req.Messages[i].Content, FakeToolPrefix)
+			return name, name != req.Messages[i].Content && name != ""
+		}
+	}
+	return "", false
+}
+
+func (p *FakeProvider) usage(req *ChatRequest, content string) Usage {
+	var input int
+	for _, message := range req.Messages {
+		input += len([]rune(message.Content))
+	}
+	output := len([]rune(content))
+	return Usage{
+		TokenCount:  input + output,
+		OutputCount: output,
+		InputCount:  input,
+	}
+}
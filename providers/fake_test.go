@@ -0,0 +1,143 @@
+package providers
+
+import (
+	"context"
+	"coze-agent-platform/models"
+	"errors"
+	"testing"
+)
+
+func newFakeRequest(content string) *ChatRequest {
+	return &ChatRequest{
+		ConversationID: "fake_conv_1",
+		Messages: []*models.Message{
+			{Role: "user", Content: "你好"},
+			{Role: "assistant", Content: "echo: 你好"},
+			{Role: "user", Content: content},
+		},
+	}
+}
+
+// 辅助函数：记录流式事件
+func collectEvents(events *[]Event) EventHandler {
+	return func(event Event) {
+		*events = append(*events, event)
+	}
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestFakeChatStreamCompleted(t *testing.T) {
+	provider := NewFakeProvider()
+
+	var events []Event
+	if err := provider.ChatStream(context.Background(), newFakeRequest("hello world"), collectEvents(&events)); err != nil {
+		t.Fatalf("ChatStream() = %v", err)
+	}
+
+	want := []EventType{EventChatCreated, EventMessageDelta, EventMessageDelta, EventMessageDelta, EventChatCompleted, EventConversationEnd}
+	got := eventTypes(events)
+	if len(got) != len(want) {
+		t.Fatalf("事件 = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("事件 = %v, want %v", got, want)
+		}
+	}
+
+	created := events[0].Data.(ChatCreated)
+	if created.ConversationID != "fake_conv_1" {
+		t.Errorf("ConversationID = %q, want %q", created.ConversationID, "fake_conv_1")
+	}
+
+	var content string
+	for _, event := range events[1:4] {
+		content += event.Data.(MessageDelta).Content
+	}
+	if content != "echo: hello world" {
+		t.Errorf("回复 = %q, want %q", content, "echo: hello world")
+	}
+
+	completed := events[4].Data.(ChatCompleted)
+	if completed.ChatID != created.ChatID {
+		t.Errorf("ChatCompleted.ChatID = %q, want %q", completed.ChatID, created.ChatID)
+	}
+	// 输入为全部消息的字符数，输出为回复的字符数
+	wantUsage := Usage{TokenCount: 2 + 8 + 11 + 17, OutputCount: 17, InputCount: 2 + 8 + 11}
+	if completed.Usage != wantUsage {
+		t.Errorf("Usage = %+v, want %+v", completed.Usage, wantUsage)
+	}
+
+	if end := events[5].Data.(ConversationEnd); end.Status != "completed" {
+		t.Errorf("ConversationEnd.Status = %q, want completed", end.Status)
+	}
+}
+
+func TestFakeChatStreamRequiresAction(t *testing.T) {
+	provider := NewFakeProvider()
+
+	var events []Event
+	if err := provider.ChatStream(context.Background(), newFakeRequest(FakeToolPrefix+"get_weather"), collectEvents(&events)); err != nil {
+		t.Fatalf("ChatStream() = %v", err)
+	}
+
+	want := []EventType{EventChatCreated, EventRequiresAction, EventConversationEnd}
+	got := eventTypes(events)
+	if len(got) != len(want) {
+		t.Fatalf("事件 = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("事件 = %v, want %v", got, want)
+		}
+	}
+
+	created := events[0].Data.(ChatCreated)
+	action := events[1].Data.(map[string]interface{})
+	if action["chat_id"] != created.ChatID {
+		t.Errorf("chat_id = %v, want %q", action["chat_id"], created.ChatID)
+	}
+	toolCalls := action["tool_calls"].([]map[string]interface{})
+	if len(toolCalls) != 1 {
+		t.Fatalf("tool_calls 数量 = %d, want 1", len(toolCalls))
+	}
+	function := toolCalls[0]["function"].(map[string]interface{})
+	if function["name"] != "get_weather" {
+		t.Errorf("工具名称 = %v, want get_weather", function["name"])
+	}
+
+	if end := events[2].Data.(ConversationEnd); end.Status != "requires_action" {
+		t.Errorf("ConversationEnd.Status = %q, want requires_action", end.Status)
+	}
+}
+
+func TestFakeChatStreamCancel(t *testing.T) {
+	provider := NewFakeProvider()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 收到第一段回复后取消
+	var events []Event
+	err := provider.ChatStream(ctx, newFakeRequest("hello world"), func(event Event) {
+		events = append(events, event)
+		if event.Type == EventMessageDelta {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ChatStream() = %v, want %v", err, context.Canceled)
+	}
+
+	want := []EventType{EventChatCreated, EventMessageDelta}
+	got := eventTypes(events)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("事件 = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,326 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"coze-agent-platform/models"
+	"coze-agent-platform/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+)
+
+// OpenAIProvider 兼容OpenAI Chat Completions接口的对话后端，
+// 通过环境变量 OPENAI_BASE_URL、OPENAI_API_KEY、OPENAI_MODEL 配置。
+// 对话历史由本地消息表维护，不支持工作流和文件上传。
+type OpenAIProvider struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	HTTPClient *http.Client
+}
+
+var _ ChatProvider = (*OpenAIProvider)(nil)
+
+func NewOpenAIProvider() ChatProvider {
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	model := os.Getenv("OPENAI_MODEL")
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+
+	return &OpenAIProvider{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     os.Getenv("OPENAI_API_KEY"),
+		Model:      model,
+		HTTPClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIRequestMessage Content为字符串，或包含图片时为内容片段数组
+type openAIRequestMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+type openAIChatRequest struct {
+	Model         string                 `json:"model"`
+	Messages      []openAIRequestMessage `json:"messages"`
+	Stream        bool                   `json:"stream,omitempty"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIChatResponse struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string      `json:"message"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+func (p *OpenAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+// CreateConversation 对话历史保存在本地，仅生成一个对话ID
+func (p *OpenAIProvider) CreateConversation(botID string, metaData map[string]string) (string, error) {
+	return fmt.Sprintf("openai_%d", utils.GenerateSnowflakeId()), nil
+}
+
+func (p *OpenAIProvider) Chat(req *ChatRequest) (*ChatResult, error) {
+	if req.WorkflowID != "" {
+		return nil, ErrNotSupported
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+
+	httpResp, err := p.post(ctx, p.buildRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp openAIChatResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("解析对话结果失败: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("对话结果为空")
+	}
+
+	return &ChatResult{
+		ChatID:         resp.ID,
+		ConversationID: req.ConversationID,
+		MessageID:      resp.ID,
+		Content:        resp.Choices[0].Message.Content,
+		Usage:          toOpenAIUsage(resp.Usage),
+	}, nil
+}
+
+func (p *OpenAIProvider) ChatStream(ctx context.Context, req *ChatRequest, onEvent EventHandler) error {
+	if req.WorkflowID != "" {
+		return ErrNotSupported
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*2)
+	defer cancel()
+
+	httpResp, err := p.post(ctx, p.buildRequest(req, true))
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	var chatID string
+	var usage Usage
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("解析流式数据失败: %v", err)
+		}
+		if chunk.Error != nil {
+			onEvent(Event{Type: EventChatFailed, Data: ChatFailed{ErrorMsg: chunk.Error.Message}})
+			return nil
+		}
+
+		if chatID == "" && chunk.ID != "" {
+			chatID = chunk.ID
+			onEvent(Event{Type: EventChatCreated, Data: ChatCreated{ChatID: chatID, ConversationID: req.ConversationID}})
+		}
+		if chunk.Usage != nil {
+			usage = toOpenAIUsage(chunk.Usage)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			onEvent(Event{Type: EventMessageDelta, Data: MessageDelta{
+				Content: choice.Delta.Content,
+				Role:    "assistant",
+				Type:    "answer",
+			}})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("流式对话失败: %v", err)
+	}
+
+	onEvent(Event{Type: EventChatCompleted, Data: ChatCompleted{ChatID: chatID, Usage: usage}})
+	onEvent(Event{Type: EventConversationEnd, Data: ConversationEnd{Status: "completed"}})
+	return nil
+}
+
+// CancelChat 无服务端取消接口，停止读取流即可结束生成
+func (p *OpenAIProvider) CancelChat(conversationID string, chatID string) error {
+	return nil
+}
+
+func (p *OpenAIProvider) RunWorkflow(req *WorkflowRequest) (*WorkflowResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (p *OpenAIProvider) RunWorkflowStream(req *WorkflowRequest, onEvent EventHandler) error {
+	return ErrNotSupported
+}
+
+func (p *OpenAIProvider) UploadFile(file io.Reader) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (p *OpenAIProvider) buildRequest(req *ChatRequest, stream bool) *openAIChatRequest {
+	chatReq := &openAIChatRequest{
+		Model:    p.Model,
+		Messages: make([]openAIRequestMessage, 0, len(req.Messages)),
+		Stream:   stream,
+	}
+	if stream {
+		chatReq.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+	}
+	for _, message := range req.Messages {
+		chatReq.Messages = append(chatReq.Messages, openAIRequestMessage{
+			Role:    message.Role,
+			Content: buildOpenAIContent(message),
+		})
+	}
+	return chatReq
+}
+
+// buildOpenAIContent 仅支持通过URL指定的图片，file_id和文件片段无法传递给OpenAI接口，会被忽略
+func buildOpenAIContent(message *models.Message) interface{} {
+	if message.Role != "user" || !message.Parts.HasAttachment() {
+		return message.Content
+	}
+
+	parts := make([]openAIContentPart, 0, len(message.Parts))
+	for _, part := range message.Parts {
+		switch {
+		case part.Type == models.MessagePartText:
+			parts = append(parts, openAIContentPart{Type: "text", Text: part.Text})
+		case part.Type == models.MessagePartImage && part.FileURL != "":
+			parts = append(parts, openAIContentPart{
+				Type: "image_url",
+				ImageURL: &struct {
+					URL string `json:"url"`
+				}{URL: part.FileURL},
+			})
+		}
+	}
+	return parts
+}
+
+func (p *OpenAIProvider) post(ctx context.Context, chatReq *openAIChatRequest) (*http.Response, error) {
+	if p.APIKey == "" {
+		return nil, errors.New("未配置OPENAI_API_KEY")
+	}
+
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	httpResp, err := p.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("请求对话接口失败: %w", &utils.UpstreamError{Kind: utils.ErrorKindUnavailable, Err: err})
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		defer httpResp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+		return nil, fmt.Errorf("请求对话接口失败: %w", &utils.UpstreamError{
+			Kind:    openAIErrorKind(httpResp.StatusCode),
+			Message: httpResp.Status + " " + string(respBody),
+		})
+	}
+	return httpResp, nil
+}
+
+// openAIErrorKind 根据HTTP状态码对OpenAI兼容接口的错误分类
+func openAIErrorKind(statusCode int) utils.ErrorKind {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return utils.ErrorKindAuth
+	case statusCode == http.StatusTooManyRequests:
+		return utils.ErrorKindRateLimited
+	case statusCode == http.StatusPaymentRequired:
+		return utils.ErrorKindQuota
+	case statusCode >= http.StatusInternalServerError:
+		return utils.ErrorKindUnavailable
+	default:
+		return utils.ErrorKindBadRequest
+	}
+}
+
+func toOpenAIUsage(usage *openAIUsage) Usage {
+	if usage == nil {
+		return Usage{}
+	}
+	return Usage{
+		TokenCount:  usage.TotalTokens,
+		OutputCount: usage.CompletionTokens,
+		InputCount:  usage.PromptTokens,
+	}
+}
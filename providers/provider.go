@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"context"
+	"coze-agent-platform/models"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// 对话后端名称
+const (
+	ProviderCoze   = "coze"
+	ProviderOpenAI = "openai"
+	ProviderFake   = "fake"
+
+	DefaultProvider = ProviderCoze
+)
+
+// ErrNotSupported 对话后端不支持该操作
+var ErrNotSupported = errors.New("当前对话后端不支持该操作")
+
+// ChatRequest 发起对话的请求
+type ChatRequest struct {
+	BotID          string            // Coze Bot ID，其他后端可忽略
+	ConversationID string            // 后端侧的对话ID
+	UserID         uint              // 本地用户ID
+	Messages       []*models.Message // 历史消息及本次用户消息
+
+	CustomVariables map[string]string // 已按Agent声明校验的Bot变量，其他后端可忽略
+	MetaData        map[string]string // 对话元数据，其他后端可忽略
+
+	WorkflowID string // 对话流ID，设置后通过Coze对话流接口对话
+}
+
+// ChatResult 非流式对话的结果
+type ChatResult struct {
+	ChatID         string `json:"chat_id"`
+	ConversationID string `json:"conversation_id"`
+	MessageID      string `json:"message_id"`
+	Content        string `json:"content"`
+	Usage          Usage  `json:"usage"`
+}
+
+// WorkflowRequest 运行工作流的请求
+type WorkflowRequest struct {
+	WorkflowID string
+	Parameters map[string]interface{} // 已校验并注入可信参数后的工作流参数
+	IsAsync    bool
+}
+
+// WorkflowResult 运行工作流的结果，异步运行时仅返回ExecuteID
+type WorkflowResult struct {
+	ExecuteID  string `json:"execute_id"`
+	Output     string `json:"data"`
+	TokenCount int    `json:"token"`
+	LogID      string `json:"log_id"`
+	DebugURL   string `json:"debug_url"`
+}
+
+// ChatProvider 对话后端，Coze之外的后端通过实现该接口接入
+type ChatProvider interface {
+	Name() string
+	CreateConversation(botID string, metaData map[string]string) (string, error)
+	Chat(req *ChatRequest) (*ChatResult, error)
+	ChatStream(ctx context.Context, req *ChatRequest, onEvent EventHandler) error
+	CancelChat(conversationID string, chatID string) error
+	RunWorkflow(req *WorkflowRequest) (*WorkflowResult, error)
+	RunWorkflowStream(req *WorkflowRequest, onEvent EventHandler) error
+	UploadFile(file io.Reader) (string, error)
+}
+
+// HistorySeeder 对话历史保存在远端的后端，创建对话时写入已有的历史消息，用于导入对话
+type HistorySeeder interface {
+	CreateConversationWithHistory(botID string, metaData map[string]string, messages []*models.Message) (string, error)
+}
+
+// WorkspaceScoped 凭证按工作空间区分的后端，返回使用指定工作空间凭证的后端实例
+type WorkspaceScoped interface {
+	ForWorkspace(workspaceId uint) ChatProvider
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]ChatProvider)
+)
+
+// Register 注册对话后端，同名后端会被覆盖
+func Register(provider ChatProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[provider.Name()] = provider
+}
+
+// Get 根据名称获取对话后端，名称为空时使用默认后端
+func Get(name string) (ChatProvider, error) {
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的对话后端: %s", name)
+	}
+	return provider, nil
+}
@@ -2,6 +2,7 @@ package routers
 
 import (
 	"coze-agent-platform/controllers"
+	"coze-agent-platform/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,13 +17,17 @@ func SetupRoutes(r *gin.Engine) {
 		public.POST("/auth/login", controllers.Login)
 		public.POST("/auth/register", controllers.Register)
 
-		api.Group("/coze").GET("/token", controllers.GetCozeToken)
+		// Coze回调，通过签名校验
+		public.POST("/webhooks/coze", controllers.ReceiveCozeWebhook)
 	}
 
 	// 需要认证的路由
 	auth := api.Group("/")
-	// auth.Use(middleware.JWTAuth())
+	auth.Use(middleware.JWTAuth())
 	{
+		// Coze访问令牌
+		auth.GET("/coze/token", controllers.GetCozeToken)
+
 		// 用户相关
 		auth.GET("/users/profile", controllers.GetUserProfile)
 		auth.PUT("/users/profile", controllers.UpdateUserProfile)
@@ -30,24 +35,95 @@ func SetupRoutes(r *gin.Engine) {
 		// Agent相关
 		auth.GET("/agents", controllers.ListAgents)
 		auth.POST("/agents", controllers.CreateAgent)
-		auth.GET("/agents/:id", controllers.GetAgent)
-		auth.PUT("/agents/:id", controllers.UpdateAgent)
-		auth.DELETE("/agents/:id", controllers.DeleteAgent)
+
+		// 单个Agent的访问需校验所有者或管理员
+		agent := auth.Group("/agents/:id", middleware.AgentAccess())
+		agent.GET("", controllers.GetAgent)
+		agent.PUT("", controllers.UpdateAgent)
+		agent.DELETE("", controllers.DeleteAgent)
+		agent.POST("/publish", controllers.PublishAgent)
+		agent.GET("/drift", controllers.GetAgentDrift)
 
 		// 对话相关
 		auth.GET("/conversations", controllers.ListConversations)
 		auth.POST("/conversations", controllers.CreateConversation)
-		auth.GET("/conversations/:id", controllers.GetConversation)
-		auth.DELETE("/conversations/:id", controllers.DeleteConversation)
+		auth.GET("/conversations/export", controllers.ExportConversations)
+		auth.POST("/conversations/import", controllers.ImportConversation)
+
+		// 单个对话及其消息的访问需校验所有者或管理员
+		conversation := auth.Group("/conversations/:id", middleware.ConversationAccess())
+		conversation.GET("", controllers.GetConversation)
+		conversation.PATCH("", controllers.UpdateConversation)
+		conversation.DELETE("", controllers.DeleteConversation)
+		conversation.GET("/export", controllers.ExportConversation)
+
+		// 对话文件夹
+		auth.GET("/conversation-folders", controllers.ListConversationFolders)
+		auth.POST("/conversation-folders", controllers.CreateConversationFolder)
+		auth.PUT("/conversation-folders/:id", controllers.UpdateConversationFolder)
+		auth.DELETE("/conversation-folders/:id", controllers.DeleteConversationFolder)
 
 		// 消息相关
-		auth.GET("/conversations/:id/messages", controllers.GetMessages)
-		auth.POST("/conversations/:id/messages", controllers.SendMessage)
+		conversation.GET("/messages", controllers.GetMessages)
+		conversation.POST("/messages", controllers.SendMessage)
+		conversation.POST("/messages/:message_id/edit", controllers.EditMessage)
+		conversation.POST("/messages/:message_id/regenerate", controllers.RegenerateMessage)
+		conversation.GET("/branch", controllers.GetMessageBranch)
+		conversation.PUT("/branch", controllers.SwitchMessageBranch)
 		auth.POST("/conversations/messages/stream", controllers.SendMessageStream)
+		conversation.POST("/chats/:chat_id/cancel", controllers.CancelChat)
 		auth.POST("/conversations/workflow", controllers.SendMessageWorkFlow)
 		auth.POST("/conversations/workflow/stream", controllers.SendMessageWorkFlowStream)
+		auth.GET("/workflows/runs", controllers.ListWorkflowRuns)
+		auth.GET("/workflows/runs/:id", controllers.GetWorkflowRun)
+		auth.POST("/workflows/runs/:id/resume", controllers.ResumeWorkflowRun)
+
+		// 回复评价，按消息所属对话校验权限
+		auth.GET("/messages/:id/feedback", controllers.GetMessageFeedback)
+		auth.POST("/messages/:id/feedback", controllers.SubmitMessageFeedback)
+		auth.DELETE("/messages/:id/feedback", controllers.DeleteMessageFeedback)
+
+		// 搜索
+		auth.GET("/search", controllers.Search)
+
+		// 工具调用
+		auth.GET("/tools", controllers.ListTools)
+		conversation.POST("/chats/:chat_id/tool_outputs", controllers.SubmitToolOutputs)
+
+		// 知识库相关
+		auth.GET("/knowledge/datasets", controllers.ListDatasets)
+		auth.POST("/knowledge/datasets", controllers.CreateDataset)
+		auth.POST("/knowledge/datasets/:dataset_id/documents", controllers.CreateDocument)
+		auth.GET("/knowledge/documents", controllers.ListDocuments)
+		auth.GET("/knowledge/documents/:id", controllers.GetDocument)
+		auth.DELETE("/knowledge/documents/:id", controllers.DeleteDocument)
+
+		// 语音相关
+		auth.POST("/audio/transcriptions", controllers.CreateTranscription)
+		auth.POST("/audio/speech", controllers.CreateSpeech)
+		auth.GET("/audio/voices", controllers.ListVoices)
 
 		// 文件上传
 		auth.POST("/common/upload/file", controllers.UploadFile)
 	}
+
+	// 管理员路由
+	admin := api.Group("/admin")
+	admin.Use(middleware.JWTAuth(), middleware.AdminAuth())
+	{
+		admin.POST("/conversations/:id/sync", controllers.SyncConversationMessages)
+		admin.GET("/webhooks/events", controllers.ListWebhookEvents)
+		admin.GET("/webhooks/events/:id", controllers.GetWebhookEvent)
+		admin.POST("/webhooks/events/:id/reprocess", controllers.ReprocessWebhookEvent)
+		admin.POST("/search/reindex", controllers.ReindexSearch)
+		admin.GET("/feedback/report", controllers.GetFeedbackReport)
+
+		// 工作空间
+		admin.GET("/workspaces", controllers.ListWorkspaces)
+		admin.POST("/workspaces", controllers.CreateWorkspace)
+		admin.GET("/workspaces/:id", controllers.GetWorkspace)
+		admin.PUT("/workspaces/:id", controllers.UpdateWorkspace)
+		admin.DELETE("/workspaces/:id", controllers.DeleteWorkspace)
+		admin.PUT("/users/:id/workspace", controllers.SetUserWorkspace)
+	}
 }
@@ -0,0 +1,371 @@
+package search
+
+import (
+	"bytes"
+	"coze-agent-platform/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultElasticsearchURL      = "http://localhost:9200"
+	defaultElasticsearchIndex    = "coze_agent_platform"
+	defaultElasticsearchAnalyzer = "standard"
+)
+
+// errElasticsearchNotFound 文档或索引不存在
+var errElasticsearchNotFound = errors.New("Elasticsearch文档不存在")
+
+// ElasticsearchIndex 基于Elasticsearch的搜索后端，消息和对话标题分别作为文档写入同一个索引。
+// 通过环境变量 ELASTICSEARCH_URL、ELASTICSEARCH_INDEX、ELASTICSEARCH_USERNAME、ELASTICSEARCH_PASSWORD 配置，
+// ELASTICSEARCH_ANALYZER 指定中文分词器（如安装了IK插件时使用 ik_max_word），默认 standard
+type ElasticsearchIndex struct {
+	BaseURL    string
+	Index      string
+	Username   string
+	Password   string
+	Analyzer   string
+	HTTPClient *http.Client
+
+	ensureMu sync.Mutex
+	ensured  bool
+}
+
+var _ SearchIndex = (*ElasticsearchIndex)(nil)
+
+func NewElasticsearchIndex() SearchIndex {
+	baseURL := os.Getenv("ELASTICSEARCH_URL")
+	if baseURL == "" {
+		baseURL = defaultElasticsearchURL
+	}
+	index := os.Getenv("ELASTICSEARCH_INDEX")
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+	analyzer := os.Getenv("ELASTICSEARCH_ANALYZER")
+	if analyzer == "" {
+		analyzer = defaultElasticsearchAnalyzer
+	}
+
+	return &ElasticsearchIndex{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Index:      index,
+		Username:   os.Getenv("ELASTICSEARCH_USERNAME"),
+		Password:   os.Getenv("ELASTICSEARCH_PASSWORD"),
+		Analyzer:   analyzer,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// elasticsearchDocument 索引中的文档，对话标题在搜索时从数据库读取以免改名后过期
+type elasticsearchDocument struct {
+	Type           string    `json:"type"`
+	UserID         uint      `json:"user_id"`
+	ConversationID uint      `json:"conversation_id"`
+	MessageID      uint      `json:"message_id,omitempty"`
+	AgentID        uint      `json:"agent_id"`
+	Role           string    `json:"role,omitempty"`
+	Title          string    `json:"title,omitempty"`
+	Content        string    `json:"content,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (i *ElasticsearchIndex) Name() string {
+	return BackendElasticsearch
+}
+
+// IndexMessage 写入消息文档，message.Conversation 未加载时从数据库查询所属对话
+func (i *ElasticsearchIndex) IndexMessage(message *models.Message) error {
+	conversation := &message.Conversation
+	if conversation.ID == 0 {
+		conversation = &models.Conversation{}
+		err := models.DB.First(conversation, message.ConversationId).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return i.putDocument(messageDocumentId(message.ID), &elasticsearchDocument{
+		Type:           HitTypeMessage,
+		UserID:         conversation.UserId,
+		ConversationID: conversation.ID,
+		MessageID:      message.ID,
+		AgentID:        conversation.AgentId,
+		Role:           message.Role,
+		Content:        message.Content,
+		CreatedAt:      message.CreatedAt,
+	})
+}
+
+func (i *ElasticsearchIndex) RemoveMessage(id uint) error {
+	return i.deleteDocument(messageDocumentId(id))
+}
+
+func (i *ElasticsearchIndex) IndexConversation(conversation *models.Conversation) error {
+	return i.putDocument(conversationDocumentId(conversation.ID), &elasticsearchDocument{
+		Type:           HitTypeConversation,
+		UserID:         conversation.UserId,
+		ConversationID: conversation.ID,
+		AgentID:        conversation.AgentId,
+		Title:          conversation.Title,
+		CreatedAt:      conversation.CreatedAt,
+	})
+}
+
+// RemoveConversation 删除对话及其全部消息的文档
+func (i *ElasticsearchIndex) RemoveConversation(id uint) error {
+	if err := i.ensureIndex(); err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"conversation_id": id},
+		},
+	}
+	return i.do(http.MethodPost, "/"+i.Index+"/_delete_by_query?conflicts=proceed", body, nil)
+}
+
+type elasticsearchSearchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Score     float64               `json:"_score"`
+			Source    elasticsearchDocument `json:"_source"`
+			Highlight map[string][]string   `json:"highlight"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func (i *ElasticsearchIndex) Search(query *Query) (*Result, error) {
+	if strings.TrimSpace(query.Keyword) == "" {
+		return &Result{Hits: []*Hit{}}, nil
+	}
+	if err := i.ensureIndex(); err != nil {
+		return nil, err
+	}
+
+	filters := []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"user_id": query.UserID}},
+	}
+	if query.Type != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"type": query.Type}})
+	}
+	if query.AgentID != 0 {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"agent_id": query.AgentID}})
+	}
+	if query.Role != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"role": query.Role}})
+	}
+	if query.StartTime != nil || query.EndTime != nil {
+		createdAt := map[string]interface{}{}
+		if query.StartTime != nil {
+			createdAt["gte"] = query.StartTime.Format(time.RFC3339)
+		}
+		if query.EndTime != nil {
+			createdAt["lt"] = query.EndTime.Format(time.RFC3339)
+		}
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"created_at": createdAt}})
+	}
+
+	body := map[string]interface{}{
+		"from":             (query.Page - 1) * query.PageSize,
+		"size":             query.PageSize,
+		"track_total_hits": true,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": []interface{}{
+					map[string]interface{}{
+						"multi_match": map[string]interface{}{
+							"query":    query.Keyword,
+							"fields":   []string{"content", "title"},
+							"operator": "and",
+						},
+					},
+				},
+				"filter": filters,
+			},
+		},
+		"highlight": map[string]interface{}{
+			"encoder":   "html",
+			"pre_tags":  []string{"<em>"},
+			"post_tags": []string{"</em>"},
+			"fields": map[string]interface{}{
+				"content": map[string]interface{}{"fragment_size": highlightFragmentSize, "number_of_fragments": 1, "no_match_size": highlightFragmentSize},
+				"title":   map[string]interface{}{"number_of_fragments": 0},
+			},
+		},
+		"sort": []interface{}{
+			"_score",
+			map[string]interface{}{"created_at": "desc"},
+		},
+	}
+
+	var resp elasticsearchSearchResponse
+	if err := i.do(http.MethodPost, "/"+i.Index+"/_search", body, &resp); err != nil {
+		return nil, err
+	}
+
+	hits := make([]*Hit, 0, len(resp.Hits.Hits))
+	conversationIds := make([]uint, 0, len(resp.Hits.Hits))
+	for _, item := range resp.Hits.Hits {
+		doc := item.Source
+		field, text := "content", doc.Content
+		if doc.Type == HitTypeConversation {
+			field, text = "title", doc.Title
+		}
+		highlight := Highlight(text, query.Terms(), highlightFragmentSize)
+		if fragments := item.Highlight[field]; len(fragments) > 0 {
+			highlight = fragments[0]
+		}
+
+		hits = append(hits, &Hit{
+			Type:           doc.Type,
+			ConversationID: doc.ConversationID,
+			MessageID:      doc.MessageID,
+			Role:           doc.Role,
+			AgentID:        doc.AgentID,
+			Highlight:      highlight,
+			Score:          item.Score,
+			CreatedAt:      doc.CreatedAt,
+		})
+		conversationIds = append(conversationIds, doc.ConversationID)
+	}
+
+	// 对话标题以数据库为准
+	if len(conversationIds) > 0 {
+		var conversations []models.Conversation
+		if err := models.DB.Select("id", "title").Where("id IN ?", conversationIds).Find(&conversations).Error; err != nil {
+			return nil, err
+		}
+		titles := make(map[uint]string, len(conversations))
+		for _, conversation := range conversations {
+			titles[conversation.ID] = conversation.Title
+		}
+		for _, hit := range hits {
+			hit.ConversationTitle = titles[hit.ConversationID]
+		}
+	}
+
+	return &Result{Hits: hits, Total: resp.Hits.Total.Value}, nil
+}
+
+// 辅助函数：索引不存在时按映射创建，创建成功后不再检查
+func (i *ElasticsearchIndex) ensureIndex() error {
+	i.ensureMu.Lock()
+	defer i.ensureMu.Unlock()
+	if i.ensured {
+		return nil
+	}
+
+	err := i.do(http.MethodHead, "/"+i.Index, nil, nil)
+	if errors.Is(err, errElasticsearchNotFound) {
+		text := map[string]interface{}{"type": "text", "analyzer": i.Analyzer}
+		keyword := map[string]interface{}{"type": "keyword"}
+		long := map[string]interface{}{"type": "long"}
+		mapping := map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"type":            keyword,
+					"user_id":         long,
+					"conversation_id": long,
+					"message_id":      long,
+					"agent_id":        long,
+					"role":            keyword,
+					"title":           text,
+					"content":         text,
+					"created_at":      map[string]interface{}{"type": "date"},
+				},
+			},
+		}
+		err = i.do(http.MethodPut, "/"+i.Index, mapping, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	i.ensured = true
+	return nil
+}
+
+// 辅助函数：写入文档，同ID的文档会被覆盖
+func (i *ElasticsearchIndex) putDocument(id string, doc *elasticsearchDocument) error {
+	if err := i.ensureIndex(); err != nil {
+		return err
+	}
+	return i.do(http.MethodPut, "/"+i.Index+"/_doc/"+id, doc, nil)
+}
+
+// 辅助函数：删除文档，文档不存在时忽略
+func (i *ElasticsearchIndex) deleteDocument(id string) error {
+	if err := i.ensureIndex(); err != nil {
+		return err
+	}
+	err := i.do(http.MethodDelete, "/"+i.Index+"/_doc/"+id, nil, nil)
+	if errors.Is(err, errElasticsearchNotFound) {
+		return nil
+	}
+	return err
+}
+
+// 辅助函数：发送请求并解析响应，404返回 errElasticsearchNotFound
+func (i *ElasticsearchIndex) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, i.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if i.Username != "" {
+		req.SetBasicAuth(i.Username, i.Password)
+	}
+
+	resp, err := i.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求Elasticsearch失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errElasticsearchNotFound
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("Elasticsearch返回错误 %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func messageDocumentId(id uint) string {
+	return fmt.Sprintf("message-%d", id)
+}
+
+func conversationDocumentId(id uint) string {
+	return fmt.Sprintf("conversation-%d", id)
+}
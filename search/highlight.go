@@ -0,0 +1,90 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// 高亮片段的最大长度（字符数）
+const highlightFragmentSize = 100
+
+// Highlight 截取内容中第一个关键词附近的片段，HTML转义后用<em>标记关键词，不区分大小写
+func Highlight(content string, terms []string, size int) string {
+	runes := []rune(content)
+	lower := lowerRunes(runes)
+
+	keywords := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		if term != "" {
+			keywords = append(keywords, lowerRunes([]rune(term)))
+		}
+	}
+
+	// 第一个关键词出现的位置之前保留四分之一的上下文
+	start := 0
+	if first := indexOfAny(lower, keywords, 0); first > 0 {
+		start = first - size/4
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + size
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := matchAt(lower, keywords, i); n > 0 {
+			builder.WriteString("<em>")
+			builder.WriteString(html.EscapeString(string(runes[i : i+n])))
+			builder.WriteString("</em>")
+			i += n
+			continue
+		}
+		builder.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		builder.WriteString("…")
+	}
+	return builder.String()
+}
+
+// 辅助函数：逐字符转小写，保持长度不变以便按下标对应原文
+func lowerRunes(runes []rune) []rune {
+	result := make([]rune, len(runes))
+	for i, r := range runes {
+		result[i] = unicode.ToLower(r)
+	}
+	return result
+}
+
+// 辅助函数：返回从from开始第一个关键词出现的位置，没有时返回-1
+func indexOfAny(text []rune, keywords [][]rune, from int) int {
+	for i := from; i < len(text); i++ {
+		if matchAt(text, keywords, i) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// 辅助函数：返回在位置i匹配的最长关键词长度，不匹配时返回0
+func matchAt(text []rune, keywords [][]rune, i int) int {
+	longest := 0
+	for _, keyword := range keywords {
+		n := len(keyword)
+		if n <= longest || i+n > len(text) {
+			continue
+		}
+		if string(text[i:i+n]) == string(keyword) {
+			longest = n
+		}
+	}
+	return longest
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	"coze-agent-platform/models"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 搜索后端名称，通过环境变量 SEARCH_BACKEND 选择
+const (
+	BackendMySQL         = "mysql"
+	BackendElasticsearch = "elasticsearch"
+
+	DefaultBackend = BackendMySQL
+)
+
+// 搜索结果类型
+const (
+	HitTypeMessage      = "message"
+	HitTypeConversation = "conversation"
+)
+
+// Query 搜索条件，只搜索 UserID 的对话和消息
+type Query struct {
+	UserID    uint
+	Keyword   string
+	Type      string     // message、conversation，为空时都搜索
+	AgentID   uint       // 按Agent筛选
+	Role      string     // 只搜索该角色的消息，设置后不返回对话标题的匹配
+	StartTime *time.Time // 创建时间不早于
+	EndTime   *time.Time // 创建时间早于
+	Page      int
+	PageSize  int
+}
+
+// Terms 按空白拆分的关键词
+func (q *Query) Terms() []string {
+	return strings.Fields(q.Keyword)
+}
+
+// Hit 一条搜索结果
+type Hit struct {
+	Type              string    `json:"type"` // message、conversation
+	ConversationID    uint      `json:"conversation_id"`
+	ConversationTitle string    `json:"conversation_title"`
+	MessageID         uint      `json:"message_id,omitempty"`
+	Role              string    `json:"role,omitempty"`
+	AgentID           uint      `json:"agent_id"`
+	Highlight         string    `json:"highlight"` // HTML转义后的片段，关键词用<em>标记
+	Score             float64   `json:"score"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Result 搜索结果及总数
+type Result struct {
+	Hits  []*Hit
+	Total int64
+}
+
+// SearchIndex 全文搜索后端。消息和对话变更后由服务层调用索引方法，
+// 直接查询业务表的后端可将索引方法实现为空操作
+type SearchIndex interface {
+	Name() string
+	IndexMessage(message *models.Message) error
+	RemoveMessage(id uint) error
+	IndexConversation(conversation *models.Conversation) error
+	RemoveConversation(id uint) error
+	Search(query *Query) (*Result, error)
+}
+
+var (
+	currentMu sync.RWMutex
+	current   SearchIndex
+)
+
+// SetIndex 设置使用的搜索后端
+func SetIndex(index SearchIndex) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = index
+}
+
+// Current 返回使用的搜索后端，未设置时使用MySQL全文索引
+func Current() SearchIndex {
+	currentMu.RLock()
+	index := current
+	currentMu.RUnlock()
+	if index != nil {
+		return index
+	}
+
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	if current == nil {
+		current = NewMySQLIndex()
+	}
+	return current
+}
+
+// NewIndexFromEnv 根据环境变量 SEARCH_BACKEND 创建搜索后端
+func NewIndexFromEnv() (SearchIndex, error) {
+	backend := os.Getenv("SEARCH_BACKEND")
+	if backend == "" {
+		backend = DefaultBackend
+	}
+
+	switch backend {
+	case BackendMySQL:
+		return NewMySQLIndex(), nil
+	case BackendElasticsearch:
+		return NewElasticsearchIndex(), nil
+	default:
+		return nil, fmt.Errorf("未知的搜索后端: %s", backend)
+	}
+}
@@ -0,0 +1,196 @@
+package search
+
+import (
+	"coze-agent-platform/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ngram分词的默认长度（ngram_token_size），短于该长度的关键词无法通过全文索引匹配
+const mysqlNgramTokenSize = 2
+
+// MySQLIndex 基于 message.content 和 conversation.title 上的FULLTEXT索引（ngram分词）搜索，
+// 直接查询业务表，无需单独维护索引
+type MySQLIndex struct{}
+
+var _ SearchIndex = (*MySQLIndex)(nil)
+
+func NewMySQLIndex() SearchIndex {
+	return &MySQLIndex{}
+}
+
+func (i *MySQLIndex) Name() string {
+	return BackendMySQL
+}
+
+func (i *MySQLIndex) IndexMessage(message *models.Message) error {
+	return nil
+}
+
+func (i *MySQLIndex) RemoveMessage(id uint) error {
+	return nil
+}
+
+func (i *MySQLIndex) IndexConversation(conversation *models.Conversation) error {
+	return nil
+}
+
+func (i *MySQLIndex) RemoveConversation(id uint) error {
+	return nil
+}
+
+type mysqlSearchRow struct {
+	Type              string
+	MessageId         uint
+	ConversationId    uint
+	ConversationTitle string
+	Role              string
+	AgentId           uint
+	Content           string
+	CreatedAt         time.Time
+	Score             float64
+}
+
+func (i *MySQLIndex) Search(query *Query) (*Result, error) {
+	terms := query.Terms()
+	if len(terms) == 0 {
+		return &Result{Hits: []*Hit{}}, nil
+	}
+
+	var parts []string
+	var args []interface{}
+	if query.Type == "" || query.Type == HitTypeMessage {
+		match := mysqlMatch("m.content", terms)
+		sql := "SELECT 'message' AS type, m.id AS message_id, m.conversation_id, c.title AS conversation_title, m.role, c.agent_id, m.content, m.created_at, " + match.score +
+			" AS score FROM message m JOIN conversation c ON c.id = m.conversation_id" +
+			" WHERE m.deleted_at IS NULL AND c.deleted_at IS NULL AND c.user_id = ? AND " + match.cond
+		args = append(args, match.scoreArgs...)
+		args = append(args, query.UserID)
+		args = append(args, match.condArgs...)
+		if query.Role != "" {
+			sql += " AND m.role = ?"
+			args = append(args, query.Role)
+		}
+		filterSQL, filterArgs := mysqlFilters(query, "m")
+		parts = append(parts, sql+filterSQL)
+		args = append(args, filterArgs...)
+	}
+	if (query.Type == "" || query.Type == HitTypeConversation) && query.Role == "" {
+		match := mysqlMatch("c.title", terms)
+		sql := "SELECT 'conversation' AS type, 0 AS message_id, c.id AS conversation_id, c.title AS conversation_title, '' AS role, c.agent_id, c.title AS content, c.created_at, " + match.score +
+			" AS score FROM conversation c" +
+			" WHERE c.deleted_at IS NULL AND c.user_id = ? AND " + match.cond
+		args = append(args, match.scoreArgs...)
+		args = append(args, query.UserID)
+		args = append(args, match.condArgs...)
+		filterSQL, filterArgs := mysqlFilters(query, "c")
+		parts = append(parts, sql+filterSQL)
+		args = append(args, filterArgs...)
+	}
+	if len(parts) == 0 {
+		return &Result{Hits: []*Hit{}}, nil
+	}
+	union := strings.Join(parts, " UNION ALL ")
+
+	var total int64
+	if err := models.DB.Raw("SELECT COUNT(*) FROM ("+union+") t", args...).Scan(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []mysqlSearchRow
+	offset := (query.Page - 1) * query.PageSize
+	pageArgs := append(append([]interface{}{}, args...), query.PageSize, offset)
+	err := models.DB.Raw("SELECT * FROM ("+union+") t ORDER BY score DESC, created_at DESC, message_id DESC, conversation_id DESC LIMIT ? OFFSET ?", pageArgs...).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]*Hit, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, &Hit{
+			Type:              row.Type,
+			ConversationID:    row.ConversationId,
+			ConversationTitle: row.ConversationTitle,
+			MessageID:         row.MessageId,
+			Role:              row.Role,
+			AgentID:           row.AgentId,
+			Highlight:         Highlight(row.Content, terms, highlightFragmentSize),
+			Score:             row.Score,
+			CreatedAt:         row.CreatedAt,
+		})
+	}
+	return &Result{Hits: hits, Total: total}, nil
+}
+
+// mysqlMatchClause 匹配条件及相关度表达式
+type mysqlMatchClause struct {
+	score     string
+	scoreArgs []interface{}
+	cond      string
+	condArgs  []interface{}
+}
+
+// 辅助函数：生成匹配条件。关键词均不短于ngram长度时使用全文索引，否则退化为LIKE
+func mysqlMatch(column string, terms []string) mysqlMatchClause {
+	useFulltext := true
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < mysqlNgramTokenSize {
+			useFulltext = false
+			break
+		}
+	}
+
+	if useFulltext {
+		// 每个关键词作为必须出现的短语，去掉双引号避免破坏布尔查询语法
+		phrases := make([]string, 0, len(terms))
+		for _, term := range terms {
+			phrases = append(phrases, `+"`+strings.ReplaceAll(term, `"`, " ")+`"`)
+		}
+		against := strings.Join(phrases, " ")
+		expr := "MATCH(" + column + ") AGAINST(? IN BOOLEAN MODE)"
+		return mysqlMatchClause{
+			score:     expr,
+			scoreArgs: []interface{}{against},
+			cond:      expr,
+			condArgs:  []interface{}{against},
+		}
+	}
+
+	conds := make([]string, 0, len(terms))
+	condArgs := make([]interface{}, 0, len(terms))
+	for _, term := range terms {
+		conds = append(conds, column+" LIKE ?")
+		condArgs = append(condArgs, "%"+escapeLike(term)+"%")
+	}
+	return mysqlMatchClause{
+		score:    "0",
+		cond:     "(" + strings.Join(conds, " AND ") + ")",
+		condArgs: condArgs,
+	}
+}
+
+// 辅助函数：Agent和时间范围筛选，alias为带created_at列的表别名
+func mysqlFilters(query *Query, alias string) (string, []interface{}) {
+	var sql string
+	var args []interface{}
+	if query.AgentID != 0 {
+		sql += " AND c.agent_id = ?"
+		args = append(args, query.AgentID)
+	}
+	if query.StartTime != nil {
+		sql += " AND " + alias + ".created_at >= ?"
+		args = append(args, *query.StartTime)
+	}
+	if query.EndTime != nil {
+		sql += " AND " + alias + ".created_at < ?"
+		args = append(args, *query.EndTime)
+	}
+	return sql, args
+}
+
+// 辅助函数：转义LIKE中的通配符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
+	"coze-agent-platform/utils/coze"
+	"errors"
+	"strings"
+	"time"
+
+	cozeapi "github.com/coze-dev/coze-go"
+)
+
+type agentPublishService struct{}
+
+func NewAgentPublishService() models.AgentPublishService {
+	return &agentPublishService{}
+}
+
+// PublishAgent 将Agent定义同步到Coze Bot并发布，未绑定Bot时先在Coze中创建
+func (s *agentPublishService) PublishAgent(agent *models.Agent) error {
+	if agent.Provider != "" && agent.Provider != providers.ProviderCoze {
+		return errors.New("仅Coze后端的Agent支持发布")
+	}
+
+	config, err := agent.ParseConfig()
+	if err != nil {
+		return err
+	}
+
+	// 用户指定的Bot可能属于其他租户或未通过平台管理，只用于对话，不能被发布覆盖
+	if agent.CozeBotID != "" && !agent.CozeBotManaged {
+		return errors.New("Agent绑定的Bot不是由平台创建的，不能发布")
+	}
+
+	cozeClient, err := GetUserCozeClient(agent.UserID)
+	if err != nil {
+		return err
+	}
+
+	def := buildBotDefinition(agent, config)
+	if agent.CozeBotID == "" {
+		spaceId := config.SpaceID
+		if spaceId == "" {
+			spaceId = coze.DefaultSpaceID()
+		}
+		if spaceId == "" {
+			return errors.New("缺少空间ID")
+		}
+
+		botId, err := cozeClient.CreateBot(spaceId, def)
+		if err != nil {
+			return s.recordFailure(agent, err)
+		}
+
+		// 立即保存Bot ID，避免发布失败后重试时重复创建
+		agent.CozeBotID = botId
+		agent.CozeBotManaged = true
+		if err := models.DB.Model(agent).Select("coze_bot_id", "coze_bot_managed").Updates(agent).Error; err != nil {
+			return err
+		}
+
+		// 创建接口不支持绑定知识库
+		if def.Knowledge != nil {
+			if err := cozeClient.UpdateBot(botId, def); err != nil {
+				return s.recordFailure(agent, err)
+			}
+		}
+	} else if err := cozeClient.UpdateBot(agent.CozeBotID, def); err != nil {
+		return s.recordFailure(agent, err)
+	}
+
+	version, err := cozeClient.PublishBot(agent.CozeBotID)
+	if err != nil {
+		return s.recordFailure(agent, err)
+	}
+
+	now := time.Now()
+	agent.PublishStatus = models.AgentPublishPublished
+	agent.PublishVersion = version
+	agent.PublishError = ""
+	agent.PublishedAt = &now
+	return models.DB.Model(agent).Select("publish_status", "publish_version", "publish_error", "published_at").Updates(agent).Error
+}
+
+func (s *agentPublishService) recordFailure(agent *models.Agent, cause error) error {
+	agent.PublishStatus = models.AgentPublishFailed
+	agent.PublishError = cause.Error()
+	if err := models.DB.Model(agent).Select("publish_status", "publish_error").Updates(agent).Error; err != nil {
+		return err
+	}
+	return cause
+}
+
+// GetAgentDrift 比较Agent本地定义与Coze Bot已发布的定义。
+// Coze的Bot查询接口不返回知识库和工作流绑定，这两项不参与比较
+func (s *agentPublishService) GetAgentDrift(agent *models.Agent) (*models.AgentDrift, error) {
+	if agent.CozeBotID == "" {
+		return nil, errors.New("Agent未绑定Coze Bot")
+	}
+
+	config, err := agent.ParseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cozeClient, err := GetUserCozeClient(agent.UserID)
+	if err != nil {
+		return nil, err
+	}
+	bot, err := cozeClient.RetrieveBot(agent.CozeBotID)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &models.AgentDrift{
+		AgentID:        agent.ID,
+		BotID:          agent.CozeBotID,
+		PublishStatus:  agent.PublishStatus,
+		PublishVersion: agent.PublishVersion,
+		RemoteVersion:  bot.Version,
+		Items:          []models.AgentDriftItem{},
+	}
+
+	var remotePrompt, remotePrologue, remoteModelId string
+	var remoteQuestions []string
+	if bot.PromptInfo != nil {
+		remotePrompt = bot.PromptInfo.Prompt
+	}
+	if bot.OnboardingInfo != nil {
+		remotePrologue = bot.OnboardingInfo.Prologue
+		remoteQuestions = bot.OnboardingInfo.SuggestedQuestions
+	}
+	if bot.ModelInfo != nil {
+		remoteModelId = bot.ModelInfo.ModelID
+	}
+
+	compare := func(field string, local string, remote string) {
+		if local != remote {
+			drift.Items = append(drift.Items, models.AgentDriftItem{Field: field, Local: local, Remote: remote})
+		}
+	}
+	compare("name", agent.Name, bot.Name)
+	compare("description", agent.Description, bot.Description)
+	compare("prompt", agent.Prompt, remotePrompt)
+	compare("prologue", config.Prologue, remotePrologue)
+	compare("suggested_questions", strings.Join(config.SuggestedQuestions, "\n"), strings.Join(remoteQuestions, "\n"))
+	if config.Model != nil {
+		compare("model_id", config.Model.ModelID, remoteModelId)
+	}
+	if agent.PublishVersion != "" {
+		compare("version", agent.PublishVersion, bot.Version)
+	}
+
+	drift.InSync = len(drift.Items) == 0
+	return drift, nil
+}
+
+// 辅助函数：根据Agent及其配置构建Coze Bot定义
+func buildBotDefinition(agent *models.Agent, config *models.AgentConfig) *coze.BotDefinition {
+	def := &coze.BotDefinition{
+		Name:               agent.Name,
+		Description:        agent.Description,
+		Prompt:             agent.Prompt,
+		Prologue:           config.Prologue,
+		SuggestedQuestions: config.SuggestedQuestions,
+	}
+
+	if config.Model != nil {
+		def.Model = &cozeapi.BotModelInfoConfig{
+			ModelID:          config.Model.ModelID,
+			Temperature:      config.Model.Temperature,
+			TopP:             config.Model.TopP,
+			TopK:             config.Model.TopK,
+			MaxTokens:        config.Model.MaxTokens,
+			ContextRound:     config.Model.ContextRound,
+			ResponseFormat:   config.Model.ResponseFormat,
+			PresencePenalty:  config.Model.PresencePenalty,
+			FrequencyPenalty: config.Model.FrequencyPenalty,
+		}
+	}
+	if config.Knowledge != nil {
+		def.Knowledge = &cozeapi.BotKnowledge{
+			DatasetIDs:     config.Knowledge.DatasetIDs,
+			AutoCall:       config.Knowledge.AutoCall,
+			SearchStrategy: config.Knowledge.SearchStrategy,
+		}
+	}
+	if agent.CozeWorkflowID != "" {
+		def.WorkflowIDs = []string{agent.CozeWorkflowID}
+	}
+	return def
+}
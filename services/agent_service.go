@@ -35,10 +35,33 @@ func (s *agentService) GetAgentsByUserID(userId uint) ([]*models.Agent, error) {
 	return agents, err
 }
 
+func (s *agentService) ListAgentsByUserID(userId uint, page, pageSize int) ([]*models.Agent, int64, error) {
+	var agents []*models.Agent
+	var total int64
+
+	query := models.DB.Model(&models.Agent{}).Where("user_id = ?", userId)
+
+	// 计算总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	err := query.Preload("User").Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&agents).Error
+	return agents, total, err
+}
+
 func (s *agentService) UpdateAgent(agent *models.Agent) error {
 	return models.DB.Save(agent).Error
 }
 
+func (s *agentService) IsCozeBotBound(botId string, excludeAgentId uint) (bool, error) {
+	var count int64
+	err := models.DB.Model(&models.Agent{}).Where("coze_bot_id = ? AND id <> ?", botId, excludeAgentId).Count(&count).Error
+	return count > 0, err
+}
+
 func (s *agentService) DeleteAgent(id uint) error {
 	return models.DB.Delete(&models.Agent{}, id).Error
 }
@@ -54,6 +77,6 @@ func (s *agentService) ListAgents(page, pageSize int) ([]*models.Agent, int64, e
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	err := models.DB.Preload("User").Offset(offset).Limit(pageSize).Find(&agents).Error
+	err := models.DB.Preload("User").Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&agents).Error
 	return agents, total, err
 }
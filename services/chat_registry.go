@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"coze-agent-platform/models"
+	"sync"
+	"time"
+)
+
+// ActiveChat 进行中的流式对话，取消时通过cancel停止读取流
+type ActiveChat struct {
+	ConversationId uint
+	ChatID         string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+
+	// 取消后保存的部分回复，流结束前由流式处理协程写入
+	Message *models.Message
+}
+
+var (
+	activeChatsMu sync.RWMutex
+	activeChats   = make(map[string]*ActiveChat)
+)
+
+// NewActiveChat 创建进行中的对话，cancel用于停止流式读取
+func NewActiveChat(conversationId uint, cancel context.CancelFunc) *ActiveChat {
+	return &ActiveChat{
+		ConversationId: conversationId,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+}
+
+// RegisterActiveChat 在收到chat_id后登记进行中的对话，仅当前实例可取消
+func RegisterActiveChat(chatID string, chat *ActiveChat) {
+	activeChatsMu.Lock()
+	defer activeChatsMu.Unlock()
+	chat.ChatID = chatID
+	activeChats[chatID] = chat
+}
+
+// GetActiveChat 根据chat_id获取进行中的对话
+func GetActiveChat(chatID string) (*ActiveChat, bool) {
+	activeChatsMu.RLock()
+	defer activeChatsMu.RUnlock()
+	chat, ok := activeChats[chatID]
+	return chat, ok
+}
+
+// HasActiveChat 对话中是否有进行中的流式对话
+func HasActiveChat(conversationId uint) bool {
+	activeChatsMu.RLock()
+	defer activeChatsMu.RUnlock()
+	for _, chat := range activeChats {
+		if chat.ConversationId == conversationId {
+			return true
+		}
+	}
+	return false
+}
+
+// Cancel 停止流式读取
+func (a *ActiveChat) Cancel() {
+	a.cancel()
+}
+
+// Finish 流式处理结束，注销对话并唤醒等待者
+func (a *ActiveChat) Finish() {
+	a.once.Do(func() {
+		activeChatsMu.Lock()
+		if a.ChatID != "" && activeChats[a.ChatID] == a {
+			delete(activeChats, a.ChatID)
+		}
+		activeChatsMu.Unlock()
+		close(a.done)
+	})
+}
+
+// Wait 等待流式处理结束，超时返回false
+func (a *ActiveChat) Wait(timeout time.Duration) bool {
+	select {
+	case <-a.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
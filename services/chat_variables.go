@@ -0,0 +1,103 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Coze对元数据的限制
+const (
+	maxMetaDataPairs    = 16
+	maxMetaDataKeyLen   = 64
+	maxMetaDataValueLen = 512
+)
+
+// BuildUserMetaData 根据用户信息构建元数据，用户不存在时仅包含user_id
+func BuildUserMetaData(userId uint) map[string]string {
+	metaData := map[string]string{
+		"user_id": strconv.FormatUint(uint64(userId), 10),
+	}
+
+	user, err := NewUserService().GetUserByID(userId)
+	if err != nil {
+		return metaData
+	}
+
+	metaData["username"] = user.Username
+	metaData["role"] = strconv.Itoa(user.Role)
+	if user.Nickname != "" {
+		metaData["nickname"] = user.Nickname
+	}
+	return metaData
+}
+
+// BuildChatMetaData 合并调用方传入的元数据与用户元数据，调用方传入的同名用户字段会被覆盖
+func BuildChatMetaData(userId uint, metaData map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(metaData)+4)
+	for key, value := range metaData {
+		if key == "" || len(key) > maxMetaDataKeyLen {
+			return nil, fmt.Errorf("元数据键长度需在1到%d之间: %s", maxMetaDataKeyLen, key)
+		}
+		if len(value) > maxMetaDataValueLen {
+			return nil, fmt.Errorf("元数据 %s 的值不能超过%d字节", key, maxMetaDataValueLen)
+		}
+		result[key] = value
+	}
+
+	for key, value := range BuildUserMetaData(userId) {
+		result[key] = value
+	}
+
+	if len(result) > maxMetaDataPairs {
+		return nil, fmt.Errorf("元数据不能超过%d项", maxMetaDataPairs)
+	}
+	return result, nil
+}
+
+// BuildChatVariables 按对话所属Agent声明的变量校验调用方传入的变量，并补全默认值
+func BuildChatVariables(conversation *models.Conversation, variables map[string]string) (map[string]string, error) {
+	config := &models.AgentConfig{}
+	if conversation.AgentId != 0 {
+		agent, err := NewAgentService().GetAgentByID(conversation.AgentId)
+		if err != nil {
+			return nil, err
+		}
+		if config, err = agent.ParseConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 按名称排序，保证错误信息稳定
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := config.Variable(name); !ok {
+			return nil, fmt.Errorf("Agent未声明变量: %s", name)
+		}
+	}
+
+	result := make(map[string]string, len(config.Variables))
+	for _, variable := range config.Variables {
+		value, ok := variables[variable.Name]
+		if !ok || value == "" {
+			value = variable.Default
+		}
+		if value == "" {
+			if variable.Required {
+				return nil, fmt.Errorf("缺少变量: %s", variable.Name)
+			}
+			continue
+		}
+		result[variable.Name] = value
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type conversationFolderService struct{}
+
+func NewConversationFolderService() models.ConversationFolderService {
+	return &conversationFolderService{}
+}
+
+func (s *conversationFolderService) CreateFolder(folder *models.ConversationFolder) error {
+	return models.DB.Create(folder).Error
+}
+
+func (s *conversationFolderService) GetFolderById(id uint) (*models.ConversationFolder, error) {
+	var folder models.ConversationFolder
+	err := models.DB.First(&folder, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("文件夹不存在")
+		}
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (s *conversationFolderService) ListFoldersByUserId(userId uint) ([]*models.ConversationFolder, error) {
+	var folders []*models.ConversationFolder
+	err := models.DB.Where("user_id = ?", userId).Order("sort ASC, id ASC").Find(&folders).Error
+	return folders, err
+}
+
+func (s *conversationFolderService) UpdateFolder(folder *models.ConversationFolder) error {
+	return models.DB.Save(folder).Error
+}
+
+// DeleteFolder 删除文件夹，其中的对话移出文件夹但不删除
+func (s *conversationFolderService) DeleteFolder(id uint) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Conversation{}).Where("folder_id = ?", id).Update("folder_id", 0).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ConversationFolder{}, id).Error
+	})
+}
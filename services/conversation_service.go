@@ -2,7 +2,7 @@ package services
 
 import (
 	"coze-agent-platform/models"
-	"coze-agent-platform/utils/coze"
+	"coze-agent-platform/providers"
 	"errors"
 	"fmt"
 
@@ -16,19 +16,23 @@ func NewConversationService() models.ConversationService {
 }
 
 func (s *conversationService) CreateConversation(conversation *models.Conversation) error {
-	// 创建Coze对话
-	cozeConv, err := coze.New()
+	// 在Agent选择的对话后端创建对话
+	provider, botId, err := GetConversationProvider(conversation)
 	if err != nil {
-		return fmt.Errorf("初始化Coze对话失败: %v", err.Error())
+		return err
 	}
 
-	cozeConversationID, err := cozeConv.CreateConversation()
+	cozeConversationID, err := provider.CreateConversation(botId, BuildUserMetaData(conversation.UserId))
 	if err != nil {
-		return fmt.Errorf("创建Coze对话失败: %v", err.Error())
+		return fmt.Errorf("创建对话失败: %w", err)
 	}
 
 	conversation.CozeConversationID = cozeConversationID
-	return models.DB.Create(conversation).Error
+	if err := models.DB.Create(conversation).Error; err != nil {
+		return err
+	}
+	indexConversationAsync(conversation)
+	return nil
 }
 
 func (s *conversationService) GetConversationById(id uint) (*models.Conversation, error) {
@@ -55,18 +59,212 @@ func (s *conversationService) GetConversationByCozeId(cozeConversationId string)
 	return &conversation, nil
 }
 
-func (s *conversationService) GetConversationsByUserId(userId uint) ([]*models.Conversation, error) {
+func (s *conversationService) GetConversationsByUserId(userId uint, filter models.ConversationFilter, page, pageSize int) ([]*models.Conversation, int64, error) {
 	var conversations []*models.Conversation
-	err := models.DB.Where("user_id = ?", userId).Order("created_at DESC").Find(&conversations).Error
-	return conversations, err
+	var total int64
+
+	query := models.DB.Model(&models.Conversation{}).Where("user_id = ? AND archived = ?", userId, filter.Archived)
+	if filter.FolderId != nil {
+		query = query.Where("folder_id = ?", *filter.FolderId)
+	}
+	if filter.AgentId != 0 {
+		query = query.Where("agent_id = ?", filter.AgentId)
+	}
+	if filter.Tag != "" {
+		query = query.Where("id IN (?)", models.DB.Model(&models.ConversationTag{}).Select("conversation_id").Where("user_id = ? AND tag = ?", userId, filter.Tag))
+	}
+
+	// 计算总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询，置顶的对话在前，按ID排序保证创建时间相同时顺序稳定
+	offset := (page - 1) * pageSize
+	err := query.Order("pinned DESC, pinned_at DESC, created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&conversations).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.LoadConversationTags(conversations...); err != nil {
+		return nil, 0, err
+	}
+	return conversations, total, nil
+}
+
+func (s *conversationService) GetAllConversationsByUserId(userId uint) ([]*models.Conversation, error) {
+	var conversations []*models.Conversation
+	err := models.DB.Where("user_id = ?", userId).Order("created_at ASC, id ASC").Find(&conversations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.LoadConversationTags(conversations...); err != nil {
+		return nil, err
+	}
+	return conversations, nil
 }
 
+func (s *conversationService) ImportConversation(conversation *models.Conversation, messages []*models.Message) error {
+	provider, botId, err := GetConversationProvider(conversation)
+	if err != nil {
+		return err
+	}
+
+	// Coze的对话历史保存在远端，需写入历史消息后续对话才能延续上下文
+	metaData := BuildUserMetaData(conversation.UserId)
+	var cozeConversationID string
+	if seeder, ok := provider.(providers.HistorySeeder); ok {
+		cozeConversationID, err = seeder.CreateConversationWithHistory(botId, metaData, messages)
+	} else {
+		cozeConversationID, err = provider.CreateConversation(botId, metaData)
+	}
+	if err != nil {
+		return fmt.Errorf("创建对话失败: %w", err)
+	}
+	conversation.CozeConversationID = cozeConversationID
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return err
+		}
+
+		var parentId uint
+		for _, message := range messages {
+			message.ConversationId = conversation.ID
+			message.CozeConversationId = cozeConversationID
+			message.ParentId = parentId
+			if err := tx.Create(message).Error; err != nil {
+				return err
+			}
+			parentId = message.ID
+		}
+
+		conversation.ActiveMessageId = parentId
+		return tx.Model(&models.Conversation{}).Where("id = ?", conversation.ID).Update("active_message_id", parentId).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	indexConversationAsync(conversation)
+	indexMessagesAsync(messages)
+	return nil
+}
+
+// StartBranchConversation 对话后端支持时新建远端对话并写入新分支的历史消息，之后的对话在新的远端对话中进行
+func (s *conversationService) StartBranchConversation(conversation *models.Conversation, history []*models.Message) (bool, error) {
+	provider, botId, err := GetConversationProvider(conversation)
+	if err != nil {
+		return false, err
+	}
+	seeder, ok := provider.(providers.HistorySeeder)
+	if !ok {
+		return false, nil
+	}
+
+	cozeConversationID, err := seeder.CreateConversationWithHistory(botId, BuildUserMetaData(conversation.UserId), history)
+	if err != nil {
+		return false, fmt.Errorf("创建分支对话失败: %w", err)
+	}
+	// 已有消息仍属于原来的Coze对话，消息同步和评价转发按消息记录的Coze对话进行
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Message{}).
+			Where("conversation_id = ? AND (coze_conversation_id = '' OR coze_conversation_id IS NULL)", conversation.ID).
+			Update("coze_conversation_id", conversation.CozeConversationID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Conversation{}).Where("id = ?", conversation.ID).Update("coze_conversation_id", cozeConversationID).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	conversation.CozeConversationID = cozeConversationID
+	return true, nil
+}
+
+func (s *conversationService) SetCozeConversationId(conversation *models.Conversation, cozeConversationId string) error {
+	err := models.DB.Model(&models.Conversation{}).Where("id = ?", conversation.ID).Update("coze_conversation_id", cozeConversationId).Error
+	if err != nil {
+		return err
+	}
+	conversation.CozeConversationID = cozeConversationId
+	return nil
+}
+
+// UpdateConversation 当前分支由消息服务维护，不随对话信息保存
 func (s *conversationService) UpdateConversation(conversation *models.Conversation) error {
-	return models.DB.Save(conversation).Error
+	if err := models.DB.Omit("active_message_id").Save(conversation).Error; err != nil {
+		return err
+	}
+	indexConversationAsync(conversation)
+	return nil
+}
+
+// SetConversationTags 替换对话的全部标签
+func (s *conversationService) SetConversationTags(conversation *models.Conversation, tags []string) error {
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&models.ConversationTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+
+		records := make([]models.ConversationTag, 0, len(tags))
+		for _, tag := range tags {
+			records = append(records, models.ConversationTag{
+				ConversationId: conversation.ID,
+				UserId:         conversation.UserId,
+				Tag:            tag,
+			})
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	conversation.Tags = tags
+	return nil
+}
+
+// LoadConversationTags 批量查询并填充对话的标签
+func (s *conversationService) LoadConversationTags(conversations ...*models.Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(conversations))
+	for _, conversation := range conversations {
+		ids = append(ids, conversation.ID)
+	}
+
+	var records []models.ConversationTag
+	if err := models.DB.Where("conversation_id IN ?", ids).Order("id ASC").Find(&records).Error; err != nil {
+		return err
+	}
+
+	tagsById := make(map[uint][]string, len(conversations))
+	for _, record := range records {
+		tagsById[record.ConversationId] = append(tagsById[record.ConversationId], record.Tag)
+	}
+	for _, conversation := range conversations {
+		conversation.Tags = tagsById[conversation.ID]
+		if conversation.Tags == nil {
+			conversation.Tags = []string{}
+		}
+	}
+	return nil
 }
 
 func (s *conversationService) DeleteConversation(id uint) error {
-	return models.DB.Delete(&models.Conversation{}, id).Error
+	if err := models.DB.Delete(&models.Conversation{}, id).Error; err != nil {
+		return err
+	}
+	removeConversationAsync(id)
+	return nil
 }
 
 func (s *conversationService) ListConversations(page, pageSize int) ([]*models.Conversation, int64, error) {
@@ -80,6 +278,56 @@ func (s *conversationService) ListConversations(page, pageSize int) ([]*models.C
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	err := models.DB.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&conversations).Error
+	err := models.DB.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&conversations).Error
 	return conversations, total, err
 }
+
+// GetConversationProvider 获取对话所属Agent选择的对话后端及绑定的BotID，未绑定Agent时使用默认后端和全局Bot。
+// 后端使用对话所属用户的工作空间凭证
+func GetConversationProvider(conversation *models.Conversation) (providers.ChatProvider, string, error) {
+	if conversation.AgentId == 0 {
+		provider, err := GetAgentProvider(nil, conversation.UserId)
+		return provider, "", err
+	}
+
+	agent, err := NewAgentService().GetAgentByID(conversation.AgentId)
+	if err != nil {
+		return nil, "", err
+	}
+	if agent.Status != 1 {
+		return nil, "", errors.New("Agent已禁用")
+	}
+
+	provider, err := GetAgentProvider(agent, conversation.UserId)
+	if err != nil {
+		return nil, "", err
+	}
+	return provider, agent.CozeBotID, nil
+}
+
+// GetAgentProvider 获取Agent选择的对话后端，agent为nil或未指定时使用默认后端，userId 为调用方
+func GetAgentProvider(agent *models.Agent, userId uint) (providers.ChatProvider, error) {
+	name := ""
+	if agent != nil {
+		name = agent.Provider
+	}
+	return GetUserProvider(name, userId)
+}
+
+// GetUserProvider 获取对话后端，凭证按工作空间区分的后端使用用户所属工作空间的凭证
+func GetUserProvider(name string, userId uint) (providers.ChatProvider, error) {
+	provider, err := providers.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped, ok := provider.(providers.WorkspaceScoped)
+	if !ok {
+		return provider, nil
+	}
+	workspaceId, err := GetUserWorkspaceId(userId)
+	if err != nil {
+		return nil, err
+	}
+	return scoped.ForWorkspace(workspaceId), nil
+}
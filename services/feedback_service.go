@@ -0,0 +1,219 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
+	"coze-agent-platform/utils/coze"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// 报表中差评原因、差评最多的对话及Agent的数量上限
+const (
+	feedbackReportTopReasons         = 10
+	feedbackReportWorstConversations = 10
+	feedbackReportMaxAgents          = 50
+)
+
+type feedbackService struct{}
+
+func NewFeedbackService() models.FeedbackService {
+	return &feedbackService{}
+}
+
+func (s *feedbackService) SubmitFeedback(feedback *models.MessageFeedback, message *models.Message, conversation *models.Conversation) error {
+	existing, err := s.GetFeedback(feedback.MessageId, feedback.UserId)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		feedback.ID = existing.ID
+		feedback.CreatedAt = existing.CreatedAt
+	}
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(feedback).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feedback_id = ?", feedback.ID).Delete(&models.MessageFeedbackReason{}).Error; err != nil {
+			return err
+		}
+		if len(feedback.Reasons) == 0 {
+			return nil
+		}
+
+		reasons := make([]models.MessageFeedbackReason, 0, len(feedback.Reasons))
+		for _, reason := range feedback.Reasons {
+			reasons = append(reasons, models.MessageFeedbackReason{FeedbackId: feedback.ID, Reason: reason})
+		}
+		return tx.Create(&reasons).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 同步失败不影响本地保存，记录在评价上
+	feedbackType := coze.FeedbackTypeLike
+	if feedback.Rating == models.FeedbackRatingNegative {
+		feedbackType = coze.FeedbackTypeUnlike
+	}
+	s.forward(feedback, message, conversation, func(client *coze.Client) error {
+		return client.SubmitMessageFeedback(message.ResolveCozeConversationId(conversation), message.CozeMessageId, &coze.MessageFeedback{
+			FeedbackType: feedbackType,
+			ReasonTypes:  feedback.Reasons,
+			Comment:      feedback.Comment,
+		})
+	})
+	return models.DB.Model(feedback).Select("forward_status", "forward_error").Updates(feedback).Error
+}
+
+// GetFeedback 用户未评价时返回nil
+func (s *feedbackService) GetFeedback(messageId uint, userId uint) (*models.MessageFeedback, error) {
+	var feedback models.MessageFeedback
+	err := models.DB.Where("message_id = ? AND user_id = ?", messageId, userId).First(&feedback).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var reasons []string
+	if err := models.DB.Model(&models.MessageFeedbackReason{}).Where("feedback_id = ?", feedback.ID).Order("id ASC").Pluck("reason", &reasons).Error; err != nil {
+		return nil, err
+	}
+	feedback.Reasons = reasons
+	return &feedback, nil
+}
+
+func (s *feedbackService) DeleteFeedback(feedback *models.MessageFeedback, message *models.Message, conversation *models.Conversation) error {
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("feedback_id = ?", feedback.ID).Delete(&models.MessageFeedbackReason{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.MessageFeedback{}, feedback.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 只有同步过的评价需要从Coze删除
+	if feedback.ForwardStatus == models.FeedbackForwardSynced {
+		s.forward(feedback, message, conversation, func(client *coze.Client) error {
+			return client.DeleteMessageFeedback(message.ResolveCozeConversationId(conversation), message.CozeMessageId)
+		})
+	}
+	return nil
+}
+
+func (s *feedbackService) GetFeedbackReport(filter models.FeedbackReportFilter) (*models.FeedbackReport, error) {
+	report := &models.FeedbackReport{}
+
+	err := applyFeedbackFilter(models.DB.Table("message_feedback f"), filter).
+		Select(feedbackStatsColumns).
+		Scan(&report.FeedbackStats).Error
+	if err != nil {
+		return nil, err
+	}
+	report.SatisfactionRate = satisfactionRate(report.Positive, report.Total)
+
+	err = applyFeedbackFilter(models.DB.Table("message_feedback f"), filter).
+		Select("f.agent_id AS agent_id, " + feedbackStatsColumns).
+		Group("f.agent_id").
+		Order("total DESC, agent_id ASC").
+		Limit(feedbackReportMaxAgents).
+		Scan(&report.Agents).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range report.Agents {
+		agent.SatisfactionRate = satisfactionRate(agent.Positive, agent.Total)
+	}
+
+	err = applyFeedbackFilter(models.DB.Table("message_feedback_reason r").Joins("JOIN message_feedback f ON f.id = r.feedback_id"), filter).
+		Where("f.rating = ?", models.FeedbackRatingNegative).
+		Select("r.reason AS reason, COUNT(*) AS count").
+		Group("r.reason").
+		Order("count DESC, reason ASC").
+		Limit(feedbackReportTopReasons).
+		Scan(&report.TopNegativeReasons).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyFeedbackFilter(models.DB.Table("message_feedback f").Joins("JOIN conversation c ON c.id = f.conversation_id AND c.deleted_at IS NULL"), filter).
+		Select("f.conversation_id AS conversation_id, c.title AS title, c.user_id AS user_id, c.agent_id AS agent_id, " +
+			"SUM(CASE WHEN f.rating > 0 THEN 1 ELSE 0 END) AS positive, SUM(CASE WHEN f.rating < 0 THEN 1 ELSE 0 END) AS negative").
+		Group("f.conversation_id, c.title, c.user_id, c.agent_id").
+		Having("negative > 0").
+		Order("negative DESC, positive ASC, conversation_id DESC").
+		Limit(feedbackReportWorstConversations).
+		Scan(&report.WorstConversations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// 辅助函数：评价是否可同步到Coze，可同步时调用send并记录同步结果
+func (s *feedbackService) forward(feedback *models.MessageFeedback, message *models.Message, conversation *models.Conversation, send func(client *coze.Client) error) {
+	feedback.ForwardStatus = models.FeedbackForwardUnsupported
+	feedback.ForwardError = ""
+
+	// 本地生成的消息ID或以chat_id代替的消息ID在Coze中不存在
+	if message.CozeMessageId == "" || message.CozeMessageId == message.ChatId || message.SyncStatus == models.MessageSyncLocalOnly {
+		return
+	}
+	provider, _, err := GetConversationProvider(conversation)
+	if err != nil || provider.Name() != providers.ProviderCoze {
+		return
+	}
+
+	client, err := GetUserCozeClient(conversation.UserId)
+	if err == nil {
+		err = send(client)
+	}
+	if err != nil {
+		feedback.ForwardStatus = models.FeedbackForwardFailed
+		feedback.ForwardError = truncateRunes(err.Error(), 500)
+		return
+	}
+	feedback.ForwardStatus = models.FeedbackForwardSynced
+}
+
+// 评价统计的查询列，评价表别名为f
+const feedbackStatsColumns = "COUNT(*) AS total, " +
+	"COALESCE(SUM(CASE WHEN f.rating > 0 THEN 1 ELSE 0 END), 0) AS positive, " +
+	"COALESCE(SUM(CASE WHEN f.rating < 0 THEN 1 ELSE 0 END), 0) AS negative"
+
+// 辅助函数：按Agent和评价时间筛选，评价表别名为f
+func applyFeedbackFilter(query *gorm.DB, filter models.FeedbackReportFilter) *gorm.DB {
+	if filter.AgentId != 0 {
+		query = query.Where("f.agent_id = ?", filter.AgentId)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("f.created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("f.created_at < ?", *filter.EndTime)
+	}
+	return query
+}
+
+func satisfactionRate(positive, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(positive) / float64(total)
+}
+
+// 辅助函数：按字符截断字符串
+func truncateRunes(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type knowledgeDocumentService struct{}
+
+func NewKnowledgeDocumentService() models.KnowledgeDocumentService {
+	return &knowledgeDocumentService{}
+}
+
+func (s *knowledgeDocumentService) CreateKnowledgeDocument(document *models.KnowledgeDocument) error {
+	return models.DB.Create(document).Error
+}
+
+func (s *knowledgeDocumentService) GetKnowledgeDocumentById(id uint) (*models.KnowledgeDocument, error) {
+	var document models.KnowledgeDocument
+	err := models.DB.First(&document, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("知识库文档不存在")
+		}
+		return nil, err
+	}
+	return &document, nil
+}
+
+func (s *knowledgeDocumentService) GetKnowledgeDocumentsByUserId(userId uint, datasetId string, agentId uint, page, pageSize int) ([]*models.KnowledgeDocument, int64, error) {
+	var documents []*models.KnowledgeDocument
+	var total int64
+
+	query := models.DB.Model(&models.KnowledgeDocument{}).Where("user_id = ?", userId)
+	if datasetId != "" {
+		query = query.Where("dataset_id = ?", datasetId)
+	}
+	if agentId != 0 {
+		query = query.Where("agent_id = ?", agentId)
+	}
+
+	// 计算总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&documents).Error
+	return documents, total, err
+}
+
+func (s *knowledgeDocumentService) UpdateKnowledgeDocument(document *models.KnowledgeDocument) error {
+	return models.DB.Save(document).Error
+}
+
+func (s *knowledgeDocumentService) DeleteKnowledgeDocument(id uint) error {
+	return models.DB.Delete(&models.KnowledgeDocument{}, id).Error
+}
+
+func (s *knowledgeDocumentService) CreateKnowledgeDataset(dataset *models.KnowledgeDataset) error {
+	return models.DB.Create(dataset).Error
+}
+
+func (s *knowledgeDocumentService) GetKnowledgeDatasetByDatasetId(datasetId string) (*models.KnowledgeDataset, error) {
+	var dataset models.KnowledgeDataset
+	err := models.DB.Where("dataset_id = ?", datasetId).First(&dataset).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("知识库不存在或不是通过平台创建的")
+		}
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// RefreshKnowledgeDocumentProgress 从Coze同步处理中文档的进度
+func (s *knowledgeDocumentService) RefreshKnowledgeDocumentProgress(document *models.KnowledgeDocument) error {
+	if document.Status != models.KnowledgeDocumentStatusProcessing {
+		return nil
+	}
+
+	cozeClient, err := GetUserCozeClient(document.UserId)
+	if err != nil {
+		return fmt.Errorf("初始化Coze客户端失败: %v", err)
+	}
+
+	progressList, err := cozeClient.GetDocumentProgress(document.DatasetId, []string{document.DocumentId})
+	if err != nil {
+		return err
+	}
+
+	for _, progress := range progressList {
+		if progress.DocumentID != document.DocumentId {
+			continue
+		}
+		document.Status = int(progress.Status)
+		document.Progress = progress.Progress
+		document.StatusDescript = progress.StatusDescript
+		return s.UpdateKnowledgeDocument(document)
+	}
+
+	return nil
+}
@@ -14,7 +14,11 @@ func NewMessageService() models.MessageService {
 }
 
 func (s *messageService) CreateMessage(message *models.Message) error {
-	return models.DB.Create(message).Error
+	if err := models.DB.Create(message).Error; err != nil {
+		return err
+	}
+	indexMessageAsync(message)
+	return nil
 }
 
 func (s *messageService) GetMessageById(id uint) (*models.Message, error) {
@@ -41,33 +45,256 @@ func (s *messageService) GetMessagesByConversationId(conversationId uint, limit
 	return messages, err
 }
 
-func (s *messageService) GetRecentMessages(conversationId uint, limit int) ([]*models.Message, error) {
+func (s *messageService) GetMessagesPageByConversationId(conversationId uint, allBranches bool, page, pageSize int) ([]*models.Message, int64, error) {
 	var messages []*models.Message
+	var total int64
 
-	// 获取最近的消息，按创建时间倒序，然后取指定数量
-	err := models.DB.Where("conversation_id = ?", conversationId).
-		Order("created_at DESC").
-		Limit(limit).
-		Find(&messages).Error
+	query, err := s.branchQuery(conversationId, allBranches)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 计算总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询，第一页为最早的消息
+	offset := (page - 1) * pageSize
+	err = query.Order("created_at ASC, id ASC").Offset(offset).Limit(pageSize).Find(&messages).Error
+	return messages, total, err
+}
+
+// GetMessagesByCursor beforeId 非0时返回该消息之前的最近 limit 条，afterId 非0时返回该消息之后的 limit 条，
+// 均为0时返回最新的 limit 条。消息ID随写入递增，以ID作为游标
+func (s *messageService) GetMessagesByCursor(conversationId uint, allBranches bool, beforeId, afterId uint, limit int) ([]*models.Message, bool, error) {
+	var messages []*models.Message
+
+	query, err := s.branchQuery(conversationId, allBranches)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 多取一条用于判断是否还有更多消息
+	query = query.Limit(limit + 1)
+	if afterId != 0 {
+		query = query.Where("id > ?", afterId).Order("id ASC")
+	} else {
+		if beforeId != 0 {
+			query = query.Where("id < ?", beforeId)
+		}
+		query = query.Order("id DESC")
+	}
+
+	if err := query.Find(&messages).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	// 向前翻页时按ID倒序查询，转换为时间正序
+	if afterId == 0 {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, hasMore, nil
+}
+
+// branchQuery 对话消息的查询，allBranches 为false时只查询当前分支上的消息
+func (s *messageService) branchQuery(conversationId uint, allBranches bool) (*gorm.DB, error) {
+	query := models.DB.Model(&models.Message{}).Where("conversation_id = ?", conversationId)
+	if allBranches {
+		return query, nil
+	}
+
+	leafId, err := s.GetActiveLeafId(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 从当前分支末尾沿 parent_id 递归向上查询分支上的消息ID
+	var ids []uint
+	err = models.DB.Raw(`WITH RECURSIVE path AS (
+	SELECT m.id, m.parent_id FROM message m WHERE m.id = ? AND m.deleted_at IS NULL
+	UNION ALL
+	SELECT m.id, m.parent_id FROM message m JOIN path ON m.id = path.parent_id WHERE m.deleted_at IS NULL
+)
+SELECT id FROM path`, leafId).Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return query.Where("id IN ?", ids), nil
+}
+
+// GetRecentMessages 沿当前分支向上取最近的消息，作为发送给对话后端的历史
+func (s *messageService) GetRecentMessages(conversationId uint, limit int) ([]*models.Message, error) {
+	leafId, err := s.GetActiveLeafId(conversationId)
+	if err != nil {
+		return nil, err
+	}
+	if leafId == 0 {
+		return []*models.Message{}, nil
+	}
+	return s.GetMessagePath(leafId, limit)
+}
+
+func (s *messageService) GetMessagePath(messageId uint, limit int) ([]*models.Message, error) {
+	var messages []*models.Message
 
+	// 从指定消息沿 parent_id 递归向上查询，depth 为距指定消息的层数
+	err := models.DB.Raw(`WITH RECURSIVE path AS (
+	SELECT m.*, 1 AS depth FROM message m WHERE m.id = ? AND m.deleted_at IS NULL
+	UNION ALL
+	SELECT m.*, path.depth + 1 FROM message m JOIN path ON m.id = path.parent_id
+	WHERE m.deleted_at IS NULL AND (? = 0 OR path.depth < ?)
+)
+SELECT * FROM path ORDER BY depth DESC`, messageId, limit, limit).Scan(&messages).Error
 	if err != nil {
 		return nil, err
 	}
+	return messages, nil
+}
 
-	// 将消息按时间正序排列（最旧的在前面）
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+func (s *messageService) GetActiveBranch(conversationId uint, limit int) ([]*models.Message, error) {
+	messages, err := s.GetRecentMessages(conversationId, limit)
+	if err != nil || len(messages) == 0 {
+		return messages, err
 	}
 
+	parentIds := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		parentIds = append(parentIds, message.ParentId)
+	}
+
+	var siblings []*models.Message
+	err = models.DB.Select("id", "parent_id").
+		Where("conversation_id = ? AND parent_id IN ?", conversationId, parentIds).
+		Order("id ASC").
+		Find(&siblings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	siblingIds := make(map[uint][]uint, len(messages))
+	for _, sibling := range siblings {
+		siblingIds[sibling.ParentId] = append(siblingIds[sibling.ParentId], sibling.ID)
+	}
+	for _, message := range messages {
+		message.SiblingIds = siblingIds[message.ParentId]
+	}
 	return messages, nil
 }
 
+// GetActiveLeafId 分支功能之前创建的对话没有当前分支，首次调用时按时间顺序将已有消息串联为一条分支
+func (s *messageService) GetActiveLeafId(conversationId uint) (uint, error) {
+	var conversation models.Conversation
+	err := models.DB.Select("id", "active_message_id").First(&conversation, conversationId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("对话不存在")
+		}
+		return 0, err
+	}
+	if conversation.ActiveMessageId != 0 {
+		return conversation.ActiveMessageId, nil
+	}
+
+	var messages []*models.Message
+	err = models.DB.Select("id", "parent_id").
+		Where("conversation_id = ?", conversationId).
+		Order("created_at ASC, id ASC").
+		Find(&messages).Error
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	leafId := messages[len(messages)-1].ID
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		for i := 1; i < len(messages); i++ {
+			if messages[i].ParentId != 0 {
+				continue
+			}
+			if err := tx.Model(&models.Message{}).Where("id = ?", messages[i].ID).Update("parent_id", messages[i-1].ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Conversation{}).Where("id = ?", conversationId).Update("active_message_id", leafId).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return leafId, nil
+}
+
+func (s *messageService) AppendMessage(message *models.Message) error {
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Conversation{}).Where("id = ?", message.ConversationId).Update("active_message_id", message.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	indexMessageAsync(message)
+	return nil
+}
+
+func (s *messageService) SetActiveMessage(conversationId uint, messageId uint) error {
+	return models.DB.Model(&models.Conversation{}).Where("id = ?", conversationId).Update("active_message_id", messageId).Error
+}
+
+func (s *messageService) SwitchBranch(conversationId uint, messageId uint) (uint, error) {
+	message, err := s.GetMessageById(messageId)
+	if err != nil {
+		return 0, err
+	}
+	if message.ConversationId != conversationId {
+		return 0, errors.New("消息不属于该对话")
+	}
+
+	// 每层选择最新的子消息，直到分支末尾
+	leafId := message.ID
+	for {
+		var child models.Message
+		err := models.DB.Select("id").
+			Where("conversation_id = ? AND parent_id = ?", conversationId, leafId).
+			Order("id DESC").
+			Take(&child).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		leafId = child.ID
+	}
+
+	if err := s.SetActiveMessage(conversationId, leafId); err != nil {
+		return 0, err
+	}
+	return leafId, nil
+}
+
 func (s *messageService) UpdateMessage(message *models.Message) error {
-	return models.DB.Save(message).Error
+	if err := models.DB.Save(message).Error; err != nil {
+		return err
+	}
+	indexMessageAsync(message)
+	return nil
 }
 
 func (s *messageService) DeleteMessage(id uint) error {
-	return models.DB.Delete(&models.Message{}, id).Error
+	if err := models.DB.Delete(&models.Message{}, id).Error; err != nil {
+		return err
+	}
+	removeMessageAsync(id)
+	return nil
 }
 
 func (s *messageService) ListMessages(page, pageSize int) ([]*models.Message, int64, error) {
@@ -81,6 +308,6 @@ func (s *messageService) ListMessages(page, pageSize int) ([]*models.Message, in
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	err := models.DB.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&messages).Error
+	err := models.DB.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&messages).Error
 	return messages, total, err
 }
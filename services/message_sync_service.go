@@ -0,0 +1,304 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
+	"coze-agent-platform/utils/coze"
+	"errors"
+	"log"
+	"time"
+
+	cozeapi "github.com/coze-dev/coze-go"
+	"gorm.io/gorm"
+)
+
+// 新消息在此时间内不参与同步，避免与进行中的对话重复写入
+const messageSyncGracePeriod = 3 * time.Minute
+
+type messageSyncService struct{}
+
+func NewMessageSyncService() models.MessageSyncService {
+	return &messageSyncService{}
+}
+
+// SyncConversationMessages 将本地消息与Coze对话历史对齐：
+// 修正本地消息的Coze消息ID，标记内容不一致或Coze中不存在的消息，并补录本地缺失的问答消息
+func (s *messageSyncService) SyncConversationMessages(conversationId uint) (*models.MessageSyncResult, error) {
+	conversation, err := NewConversationService().GetConversationById(conversationId)
+	if err != nil {
+		return nil, err
+	}
+	if HasActiveChat(conversationId) {
+		return nil, errors.New("对话正在进行中，请稍后同步")
+	}
+
+	var localMessages []*models.Message
+	if err := models.DB.Where("conversation_id = ?", conversationId).Order("created_at ASC, id ASC").Find(&localMessages).Error; err != nil {
+		return nil, err
+	}
+
+	result := &models.MessageSyncResult{
+		ConversationId: conversationId,
+		LocalCount:     len(localMessages),
+	}
+
+	provider, _, err := GetConversationProvider(conversation)
+	if err != nil {
+		return nil, err
+	}
+	if provider.Name() != providers.ProviderCoze {
+		// 其他后端没有远端对话历史
+		for _, message := range localMessages {
+			if message.SyncStatus == models.MessageSyncPending {
+				message.SyncStatus = models.MessageSyncLocalOnly
+				if err := models.DB.Model(message).Update("sync_status", message.SyncStatus).Error; err != nil {
+					return nil, err
+				}
+				result.LocalOnly++
+			}
+		}
+		return result, nil
+	}
+
+	cozeClient, err := GetUserCozeClient(conversation.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 编辑、重新生成和切换分支会新建Coze对话，消息与其所在的Coze对话比对
+	localGroups := make(map[string][]*models.Message)
+	for _, message := range localMessages {
+		cozeConversationId := message.ResolveCozeConversationId(conversation)
+		localGroups[cozeConversationId] = append(localGroups[cozeConversationId], message)
+	}
+	if _, ok := localGroups[conversation.CozeConversationID]; !ok {
+		localGroups[conversation.CozeConversationID] = nil
+	}
+
+	var remoteMessages []*cozeapi.Message
+	matched := make(map[string]bool)
+	for cozeConversationId, messages := range localGroups {
+		remote, err := listRemoteMessages(cozeClient, cozeConversationId)
+		if err != nil {
+			return nil, err
+		}
+		result.RemoteCount += len(remote)
+		// 只从对话当前的Coze对话补录消息
+		if cozeConversationId == conversation.CozeConversationID {
+			remoteMessages = remote
+		}
+
+		for _, message := range messages {
+			if err := syncLocalMessage(message, remote, matched, result); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 每轮对话会将历史消息作为附加消息再次写入Coze对话，远端同一问答可能有多份。
+	// 与本地消息或已补录消息角色和内容相同的远端消息视为重复，不补录
+	seen := make(map[string]bool, len(localMessages)+len(remoteMessages))
+	for _, message := range localMessages {
+		seen[message.Role+"\x00"+message.Content] = true
+	}
+
+	// 补录本地缺失的消息，按时间插入当前分支
+	graceBefore := time.Now().Add(-messageSyncGracePeriod).Unix()
+	var path []*models.Message
+	pathLoaded := false
+	for _, remote := range remoteMessages {
+		if matched[remote.ID] || remote.CreatedAt > graceBefore {
+			continue
+		}
+		key := string(remote.Role) + "\x00" + remote.Content
+		if seen[key] {
+			result.Duplicated++
+			continue
+		}
+		seen[key] = true
+
+		if !pathLoaded {
+			if path, err = getActivePath(conversationId); err != nil {
+				return nil, err
+			}
+			pathLoaded = true
+		}
+
+		message := &models.Message{
+			CreatedAt:          time.Unix(remote.CreatedAt, 0),
+			CozeMessageId:      remote.ID,
+			ChatId:             remote.ChatID,
+			CozeConversationId: conversation.CozeConversationID,
+			ConversationId:     conversationId,
+			ModelId:            1,
+			Role:               string(remote.Role),
+			Content:            remote.Content,
+			Status:             models.MessageStatusCompleted,
+			SyncStatus:         models.MessageSyncSynced,
+		}
+		if path, err = insertPathMessage(path, message); err != nil {
+			return nil, err
+		}
+		result.Backfilled++
+	}
+
+	return result, nil
+}
+
+// listRemoteMessages 返回Coze对话中的问题和回答，工具调用等中间消息不落库
+func listRemoteMessages(cozeClient *coze.Client, cozeConversationId string) ([]*cozeapi.Message, error) {
+	cozeMessages, err := cozeClient.ListConversationMessages(cozeConversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteMessages := make([]*cozeapi.Message, 0, len(cozeMessages))
+	for _, message := range cozeMessages {
+		if message.Type == cozeapi.MessageTypeQuestion || message.Type == cozeapi.MessageTypeAnswer {
+			remoteMessages = append(remoteMessages, message)
+		}
+	}
+	return remoteMessages, nil
+}
+
+// syncLocalMessage 按对应的Coze消息修正本地消息的Coze消息ID并更新同步状态
+func syncLocalMessage(message *models.Message, remoteMessages []*cozeapi.Message, matched map[string]bool, result *models.MessageSyncResult) error {
+	remote := matchRemoteMessage(message, remoteMessages, matched)
+
+	syncStatus := models.MessageSyncLocalOnly
+	if remote != nil {
+		matched[remote.ID] = true
+		if message.CozeMessageId != remote.ID {
+			message.CozeMessageId = remote.ID
+			result.Updated++
+		}
+		if message.ChatId == "" {
+			message.ChatId = remote.ChatID
+		}
+		syncStatus = models.MessageSyncSynced
+		if message.Content != remote.Content {
+			syncStatus = models.MessageSyncDiverged
+		}
+	}
+
+	message.SyncStatus = syncStatus
+	switch syncStatus {
+	case models.MessageSyncDiverged:
+		result.Diverged++
+	case models.MessageSyncLocalOnly:
+		result.LocalOnly++
+	}
+
+	return models.DB.Model(message).Select("coze_message_id", "chat_id", "sync_status").Updates(message).Error
+}
+
+// getActivePath 返回对话当前分支上的全部消息，按从第一条到最后一条的顺序排列
+func getActivePath(conversationId uint) ([]*models.Message, error) {
+	messageService := NewMessageService()
+	leafId, err := messageService.GetActiveLeafId(conversationId)
+	if err != nil || leafId == 0 {
+		return nil, err
+	}
+	return messageService.GetMessagePath(leafId, 0)
+}
+
+// insertPathMessage 将消息插入当前分支中创建时间不晚于它的最后一条消息之后，
+// 原来的下一条消息改为该消息的回复；插入到末尾时更新对话的当前分支。返回插入后的分支
+func insertPathMessage(path []*models.Message, message *models.Message) ([]*models.Message, error) {
+	index := len(path)
+	for index > 0 && path[index-1].CreatedAt.After(message.CreatedAt) {
+		index--
+	}
+	if index > 0 {
+		message.ParentId = path[index-1].ID
+	}
+
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		if index < len(path) {
+			return tx.Model(&models.Message{}).Where("id = ?", path[index].ID).Update("parent_id", message.ID).Error
+		}
+		return tx.Model(&models.Conversation{}).Where("id = ?", message.ConversationId).Update("active_message_id", message.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if index < len(path) {
+		path[index].ParentId = message.ID
+	}
+	indexMessageAsync(message)
+
+	path = append(path, nil)
+	copy(path[index+1:], path[index:])
+	path[index] = message
+	return path, nil
+}
+
+// GetPendingSyncConversationIds 获取存在未同步消息的对话
+func (s *messageSyncService) GetPendingSyncConversationIds(limit int) ([]uint, error) {
+	var conversationIds []uint
+	err := models.DB.Model(&models.Message{}).
+		Where("sync_status = ? AND created_at < ?", models.MessageSyncPending, time.Now().Add(-messageSyncGracePeriod)).
+		Distinct("conversation_id").
+		Limit(limit).
+		Pluck("conversation_id", &conversationIds).Error
+	return conversationIds, err
+}
+
+// matchRemoteMessage 依次按Coze消息ID、chat_id、角色和内容查找对应的Coze消息
+func matchRemoteMessage(message *models.Message, remoteMessages []*cozeapi.Message, matched map[string]bool) *cozeapi.Message {
+	for _, remote := range remoteMessages {
+		if !matched[remote.ID] && remote.ID == message.CozeMessageId {
+			return remote
+		}
+	}
+
+	// 流式回复曾以chat_id作为消息ID保存
+	if message.Role == string(cozeapi.MessageRoleAssistant) {
+		chatId := message.ChatId
+		if chatId == "" {
+			chatId = message.CozeMessageId
+		}
+		for _, remote := range remoteMessages {
+			if !matched[remote.ID] && remote.Type == cozeapi.MessageTypeAnswer && remote.ChatID == chatId {
+				return remote
+			}
+		}
+	}
+
+	for _, remote := range remoteMessages {
+		if !matched[remote.ID] && string(remote.Role) == message.Role && remote.Content == message.Content {
+			return remote
+		}
+	}
+	return nil
+}
+
+// StartMessageSyncer 启动后台任务，定期同步存在未同步消息的对话
+func StartMessageSyncer(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		syncService := NewMessageSyncService()
+		for range ticker.C {
+			if models.DB == nil {
+				continue
+			}
+
+			conversationIds, err := syncService.GetPendingSyncConversationIds(20)
+			if err != nil {
+				log.Printf("查询待同步对话失败: %v", err)
+				continue
+			}
+
+			for _, conversationId := range conversationIds {
+				if _, err := syncService.SyncConversationMessages(conversationId); err != nil {
+					log.Printf("同步对话消息失败 conversation_id=%d: %v", conversationId, err)
+				}
+			}
+		}
+	}()
+}
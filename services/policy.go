@@ -0,0 +1,86 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"errors"
+)
+
+// ErrForbidden 无权访问资源
+var ErrForbidden = errors.New("无权访问该资源")
+
+// Actor 发起请求的用户，来自JWT
+type Actor struct {
+	UserID uint
+	Role   int
+}
+
+// IsAdmin 管理员可访问所有用户的资源
+func (a Actor) IsAdmin() bool {
+	return a.Role == models.UserRoleAdmin
+}
+
+// 资源访问策略：资源所有者或管理员可访问，其他用户返回 ErrForbidden
+
+func ownedBy(actor Actor, ownerId uint) error {
+	if actor.UserID == 0 {
+		return ErrForbidden
+	}
+	if actor.IsAdmin() || actor.UserID == ownerId {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanAccessConversation 对话及其消息的访问策略
+func CanAccessConversation(actor Actor, conversation *models.Conversation) error {
+	return ownedBy(actor, conversation.UserId)
+}
+
+// CanAccessAgent Agent的查看、修改、发布及在对话中使用
+func CanAccessAgent(actor Actor, agent *models.Agent) error {
+	return ownedBy(actor, agent.UserID)
+}
+
+// CanAccessWorkflowRun 工作流运行记录的访问策略
+func CanAccessWorkflowRun(actor Actor, run *models.WorkflowRun) error {
+	return ownedBy(actor, run.UserId)
+}
+
+// CanAccessKnowledgeDocument 知识库文档的访问策略
+func CanAccessKnowledgeDocument(actor Actor, document *models.KnowledgeDocument) error {
+	return ownedBy(actor, document.UserId)
+}
+
+// CanAccessKnowledgeDataset 向知识库上传文档及查看、删除其中文档的访问策略
+func CanAccessKnowledgeDataset(actor Actor, dataset *models.KnowledgeDataset) error {
+	return ownedBy(actor, dataset.UserId)
+}
+
+// CanUseKnowledgeSpace 知识库的查询、创建及文档上传使用用户所属工作空间的Coze凭证，
+// 未加入工作空间（workspaceId为0）时使用全局凭证，仅管理员可操作
+func CanUseKnowledgeSpace(actor Actor, workspaceId uint) error {
+	if actor.UserID == 0 {
+		return ErrForbidden
+	}
+	if actor.IsAdmin() || workspaceId != 0 {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanUseAgentId 新建对话或运行工作流时校验指定的Agent，agentId 为0表示不使用Agent
+func CanUseAgentId(actor Actor, agentId uint) error {
+	if agentId == 0 {
+		return nil
+	}
+	agent, err := NewAgentService().GetAgentByID(agentId)
+	if err != nil {
+		return err
+	}
+	return CanAccessAgent(actor, agent)
+}
+
+// CanAccessConversationFolder 对话文件夹的访问策略
+func CanAccessConversationFolder(actor Actor, folder *models.ConversationFolder) error {
+	return ownedBy(actor, folder.UserId)
+}
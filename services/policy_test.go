@@ -0,0 +1,153 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"errors"
+	"testing"
+)
+
+const (
+	ownerId uint = 1
+	otherId uint = 2
+	adminId uint = 3
+)
+
+var (
+	owner     = Actor{UserID: ownerId, Role: models.UserRoleNormal}
+	otherUser = Actor{UserID: otherId, Role: models.UserRoleNormal}
+	admin     = Actor{UserID: adminId, Role: models.UserRoleAdmin}
+	anonymous = Actor{}
+)
+
+func TestOwnedBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Actor
+		ownerId uint
+		wantErr error
+	}{
+		{"所有者", owner, ownerId, nil},
+		{"其他用户", otherUser, ownerId, ErrForbidden},
+		{"管理员", admin, ownerId, nil},
+		{"未登录", anonymous, ownerId, ErrForbidden},
+		{"未登录访问无所有者的资源", anonymous, 0, ErrForbidden},
+		{"令牌中的角色无效", Actor{UserID: otherId, Role: 99}, ownerId, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ownedBy(tt.actor, tt.ownerId); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ownedBy() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanAccessConversation(t *testing.T) {
+	conversation := &models.Conversation{ID: 10, UserId: ownerId}
+
+	tests := []struct {
+		name    string
+		actor   Actor
+		wantErr error
+	}{
+		{"所有者", owner, nil},
+		{"其他用户", otherUser, ErrForbidden},
+		{"管理员", admin, nil},
+		{"未登录", anonymous, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CanAccessConversation(tt.actor, conversation); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CanAccessConversation() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanAccessConversationFolder(t *testing.T) {
+	folder := &models.ConversationFolder{ID: 20, UserId: ownerId}
+
+	tests := []struct {
+		name    string
+		actor   Actor
+		wantErr error
+	}{
+		{"所有者", owner, nil},
+		{"其他用户", otherUser, ErrForbidden},
+		{"管理员", admin, nil},
+		{"未登录", anonymous, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CanAccessConversationFolder(tt.actor, folder); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CanAccessConversationFolder() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOwnedResourcePolicies(t *testing.T) {
+	policies := []struct {
+		name  string
+		check func(actor Actor) error
+	}{
+		{"Agent", func(actor Actor) error {
+			return CanAccessAgent(actor, &models.Agent{UserID: ownerId})
+		}},
+		{"工作流运行记录", func(actor Actor) error {
+			return CanAccessWorkflowRun(actor, &models.WorkflowRun{UserId: ownerId})
+		}},
+		{"知识库文档", func(actor Actor) error {
+			return CanAccessKnowledgeDocument(actor, &models.KnowledgeDocument{UserId: ownerId})
+		}},
+		{"知识库", func(actor Actor) error {
+			return CanAccessKnowledgeDataset(actor, &models.KnowledgeDataset{UserId: ownerId})
+		}},
+	}
+
+	for _, policy := range policies {
+		t.Run(policy.name, func(t *testing.T) {
+			if err := policy.check(owner); err != nil {
+				t.Errorf("所有者访问被拒绝: %v", err)
+			}
+			if err := policy.check(admin); err != nil {
+				t.Errorf("管理员访问被拒绝: %v", err)
+			}
+			if err := policy.check(otherUser); !errors.Is(err, ErrForbidden) {
+				t.Errorf("其他用户访问 = %v, want %v", err, ErrForbidden)
+			}
+		})
+	}
+}
+
+func TestCanUseKnowledgeSpace(t *testing.T) {
+	tests := []struct {
+		name        string
+		actor       Actor
+		workspaceId uint
+		wantErr     error
+	}{
+		{"工作空间成员", otherUser, 5, nil},
+		{"未加入工作空间的用户", otherUser, 0, ErrForbidden},
+		{"管理员使用全局凭证", admin, 0, nil},
+		{"未登录", anonymous, 5, ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CanUseKnowledgeSpace(tt.actor, tt.workspaceId); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CanUseKnowledgeSpace() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanUseAgentIdWithoutAgent(t *testing.T) {
+	// 不使用Agent时不查询数据库
+	if err := CanUseAgentId(otherUser, 0); err != nil {
+		t.Errorf("CanUseAgentId(0) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"coze-agent-platform/search"
+	"log"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// 索引在后台更新，失败时只记录日志，不影响消息和对话的保存
+
+func indexMessageAsync(message *models.Message) {
+	snapshot := *message
+	go func() {
+		if err := search.Current().IndexMessage(&snapshot); err != nil {
+			log.Printf("更新消息搜索索引失败 message_id=%d: %v", snapshot.ID, err)
+		}
+	}()
+}
+
+// 批量写入的消息在同一个后台任务中依次更新索引
+func indexMessagesAsync(messages []*models.Message) {
+	snapshots := make([]models.Message, 0, len(messages))
+	for _, message := range messages {
+		snapshots = append(snapshots, *message)
+	}
+	go func() {
+		index := search.Current()
+		for i := range snapshots {
+			if err := index.IndexMessage(&snapshots[i]); err != nil {
+				log.Printf("更新消息搜索索引失败 message_id=%d: %v", snapshots[i].ID, err)
+			}
+		}
+	}()
+}
+
+func removeMessageAsync(id uint) {
+	go func() {
+		if err := search.Current().RemoveMessage(id); err != nil {
+			log.Printf("删除消息搜索索引失败 message_id=%d: %v", id, err)
+		}
+	}()
+}
+
+func indexConversationAsync(conversation *models.Conversation) {
+	snapshot := *conversation
+	go func() {
+		if err := search.Current().IndexConversation(&snapshot); err != nil {
+			log.Printf("更新对话搜索索引失败 conversation_id=%d: %v", snapshot.ID, err)
+		}
+	}()
+}
+
+func removeConversationAsync(id uint) {
+	go func() {
+		if err := search.Current().RemoveConversation(id); err != nil {
+			log.Printf("删除对话搜索索引失败 conversation_id=%d: %v", id, err)
+		}
+	}()
+}
+
+// 同一时间只运行一个重建任务
+var reindexRunning int32
+
+// StartSearchReindex 在后台将全部对话和消息写入搜索索引，已有任务在运行时返回false
+func StartSearchReindex() bool {
+	if !atomic.CompareAndSwapInt32(&reindexRunning, 0, 1) {
+		return false
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&reindexRunning, 0)
+
+		index := search.Current()
+		var conversations []*models.Conversation
+		var indexed, failed int
+		err := models.DB.FindInBatches(&conversations, 100, func(tx *gorm.DB, batch int) error {
+			for _, conversation := range conversations {
+				if err := index.IndexConversation(conversation); err != nil {
+					failed++
+				} else {
+					indexed++
+				}
+			}
+			return nil
+		}).Error
+		if err != nil {
+			log.Printf("重建对话搜索索引失败: %v", err)
+			return
+		}
+
+		var messages []*models.Message
+		err = models.DB.Preload("Conversation").FindInBatches(&messages, 200, func(tx *gorm.DB, batch int) error {
+			for _, message := range messages {
+				// 所属对话已删除
+				if message.Conversation.ID == 0 {
+					continue
+				}
+				if err := index.IndexMessage(message); err != nil {
+					failed++
+				} else {
+					indexed++
+				}
+			}
+			return nil
+		}).Error
+		if err != nil {
+			log.Printf("重建消息搜索索引失败: %v", err)
+			return
+		}
+
+		log.Printf("搜索索引重建完成 backend=%s 成功=%d 失败=%d", index.Name(), indexed, failed)
+	}()
+	return true
+}
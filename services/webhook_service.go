@@ -0,0 +1,285 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Coze回调事件类型
+const (
+	WebhookWorkflowRunFinished     = "workflow.run.finished"
+	WebhookWorkflowRunInterrupted  = "workflow.run.interrupted"
+	WebhookConversationMessageDone = "conversation.message.completed"
+)
+
+// WebhookPayload Webhook请求体
+type WebhookPayload struct {
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	CreatedAt int64           `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WorkflowRunFinishedEvent 异步工作流运行结束
+type WorkflowRunFinishedEvent struct {
+	WorkflowID   string `json:"workflow_id"`
+	ExecuteID    string `json:"execute_id"`
+	Status       string `json:"status"` // success、fail
+	Output       string `json:"output"`
+	ErrorMessage string `json:"error_message"`
+	TokenCount   int    `json:"token_count"`
+	LogID        string `json:"log_id"`
+	DebugURL     string `json:"debug_url"`
+}
+
+// WorkflowRunInterruptedEvent 工作流运行到问答或中断节点
+type WorkflowRunInterruptedEvent struct {
+	WorkflowID    string `json:"workflow_id"`
+	ExecuteID     string `json:"execute_id"`
+	EventID       string `json:"event_id"`
+	InterruptType int    `json:"interrupt_type"`
+	NodeTitle     string `json:"node_title"`
+	Prompt        string `json:"prompt"`
+}
+
+// ConversationMessageEvent 已发布的Bot在对话中产生了一条完整消息
+type ConversationMessageEvent struct {
+	ConversationID string `json:"conversation_id"` // Coze对话ID
+	ChatID         string `json:"chat_id"`
+	MessageID      string `json:"message_id"`
+	Role           string `json:"role"`
+	Content        string `json:"content"`
+	TokenCount     int    `json:"token_count"`
+}
+
+// WebhookHandler 处理一类Webhook事件，data为请求体中的data字段
+type WebhookHandler func(data json.RawMessage) error
+
+var (
+	webhookHandlersMu sync.RWMutex
+	webhookHandlers   = map[string]WebhookHandler{
+		WebhookWorkflowRunFinished:     handleWorkflowRunFinished,
+		WebhookWorkflowRunInterrupted:  handleWorkflowRunInterrupted,
+		WebhookConversationMessageDone: handleConversationMessage,
+	}
+)
+
+// RegisterWebhookHandler 注册事件处理器，同类型的处理器会被覆盖
+func RegisterWebhookHandler(eventType string, handler WebhookHandler) {
+	webhookHandlersMu.Lock()
+	defer webhookHandlersMu.Unlock()
+	webhookHandlers[eventType] = handler
+}
+
+func getWebhookHandler(eventType string) (WebhookHandler, bool) {
+	webhookHandlersMu.RLock()
+	defer webhookHandlersMu.RUnlock()
+	handler, ok := webhookHandlers[eventType]
+	return handler, ok
+}
+
+type webhookService struct{}
+
+func NewWebhookService() models.WebhookService {
+	return &webhookService{}
+}
+
+func (s *webhookService) ReceiveEvent(event *models.WebhookEvent) (bool, error) {
+	event.Status = models.WebhookEventPending
+
+	// 事件ID唯一，重复投递时不插入
+	result := models.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ProcessEvent 分发事件到对应的处理器并记录处理结果，可对失败的事件重复调用
+func (s *webhookService) ProcessEvent(event *models.WebhookEvent) error {
+	var payload WebhookPayload
+	err := json.Unmarshal([]byte(event.Payload), &payload)
+	if err == nil {
+		if handler, ok := getWebhookHandler(event.EventType); ok {
+			err = handler(payload.Data)
+			event.Status = models.WebhookEventProcessed
+		} else {
+			event.Status = models.WebhookEventIgnored
+		}
+	}
+
+	now := time.Now()
+	event.Attempts++
+	event.ProcessedAt = &now
+	event.Error = ""
+	if err != nil {
+		event.Status = models.WebhookEventFailed
+		event.Error = err.Error()
+	}
+
+	if saveErr := models.DB.Model(event).Select("status", "attempts", "error", "processed_at").Updates(event).Error; saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+func (s *webhookService) GetWebhookEventById(id uint) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	err := models.DB.First(&event, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("Webhook事件不存在")
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *webhookService) ListWebhookEvents(status, eventType string, page, pageSize int) ([]*models.WebhookEvent, int64, error) {
+	var events []*models.WebhookEvent
+	var total int64
+
+	query := models.DB.Model(&models.WebhookEvent{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&events).Error
+	return events, total, err
+}
+
+// 辅助函数：根据Coze执行ID获取工作流运行记录
+func getWorkflowRunByExecuteId(executeId string) (*models.WorkflowRun, error) {
+	if executeId == "" {
+		return nil, errors.New("缺少execute_id")
+	}
+
+	var run models.WorkflowRun
+	err := models.DB.Where("execute_id = ?", executeId).First(&run).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 回调可能早于运行记录保存，重新处理即可
+			return nil, fmt.Errorf("工作流运行记录不存在 execute_id=%s", executeId)
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+func handleWorkflowRunFinished(data json.RawMessage) error {
+	var event WorkflowRunFinishedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("事件数据格式错误: %v", err)
+	}
+
+	run, err := getWorkflowRunByExecuteId(event.ExecuteID)
+	if err != nil {
+		return err
+	}
+	if run.Status == models.WorkflowRunStatusSuccess || run.Status == models.WorkflowRunStatusFail {
+		return nil
+	}
+
+	switch event.Status {
+	case models.WorkflowRunStatusSuccess, models.WorkflowRunStatusFail:
+		run.Status = event.Status
+	default:
+		return fmt.Errorf("未知的工作流运行状态: %s", event.Status)
+	}
+	run.Output = event.Output
+	run.ErrorMessage = event.ErrorMessage
+	run.TokenCount = event.TokenCount
+	run.LogId = event.LogID
+	run.DebugUrl = event.DebugURL
+	return NewWorkflowRunService().UpdateWorkflowRun(run)
+}
+
+func handleWorkflowRunInterrupted(data json.RawMessage) error {
+	var event WorkflowRunInterruptedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("事件数据格式错误: %v", err)
+	}
+
+	run, err := getWorkflowRunByExecuteId(event.ExecuteID)
+	if err != nil {
+		return err
+	}
+	if run.Status != models.WorkflowRunStatusRunning {
+		return nil
+	}
+
+	run.Status = models.WorkflowRunStatusInterrupted
+	run.InterruptEventId = event.EventID
+	run.InterruptType = event.InterruptType
+	run.InterruptNode = event.NodeTitle
+	run.InterruptPrompt = event.Prompt
+	return NewWorkflowRunService().UpdateWorkflowRun(run)
+}
+
+func handleConversationMessage(data json.RawMessage) error {
+	var event ConversationMessageEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("事件数据格式错误: %v", err)
+	}
+	if event.MessageID == "" {
+		return errors.New("缺少message_id")
+	}
+
+	conversation, err := NewConversationService().GetConversationByCozeId(event.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	// 消息已通过对话接口或同步任务保存
+	var count int64
+	if err := models.DB.Model(&models.Message{}).Where("coze_message_id = ?", event.MessageID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	role := event.Role
+	if role == "" {
+		role = "assistant"
+	}
+
+	// 追加到当前分支末尾
+	messageService := NewMessageService()
+	parentId, err := messageService.GetActiveLeafId(conversation.ID)
+	if err != nil {
+		return err
+	}
+	message := &models.Message{
+		CozeMessageId:      event.MessageID,
+		ChatId:             event.ChatID,
+		CozeConversationId: event.ConversationID,
+		ConversationId:     conversation.ID,
+		ParentId:           parentId,
+		Role:               role,
+		Content:            event.Content,
+		Tokens:             event.TokenCount,
+		Status:             models.MessageStatusCompleted,
+		SyncStatus:         models.MessageSyncSynced,
+	}
+	if err := messageService.AppendMessage(message); err != nil {
+		return err
+	}
+
+	return models.DB.Model(conversation).Update("updated_at", time.Now()).Error
+}
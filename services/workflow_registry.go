@@ -0,0 +1,109 @@
+package services
+
+import (
+	"coze-agent-platform/config"
+	"coze-agent-platform/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// 可信参数来源，由服务端从请求中提取后注入工作流参数
+const (
+	TrustedClientIP  = "client_ip"
+	TrustedUserID    = "user_id"
+	TrustedUserToken = "user_token"
+)
+
+// WorkflowSpec 本地注册的工作流定义
+type WorkflowSpec struct {
+	WorkflowID string            `json:"workflow_id"`
+	Name       string            `json:"name"`
+	Schema     json.RawMessage   `json:"schema"` // 调用方可传入参数的JSON Schema
+	Inject     map[string]string `json:"inject"` // 参数名 -> 可信参数来源
+}
+
+// WorkflowCaller 工作流调用方的可信信息
+type WorkflowCaller struct {
+	ClientIP string
+	UserID   uint
+}
+
+var (
+	workflowsMu sync.RWMutex
+	workflows   = make(map[string]*WorkflowSpec)
+)
+
+// RegisterWorkflow 注册工作流定义，同ID的定义会被覆盖
+func RegisterWorkflow(spec WorkflowSpec) {
+	workflowsMu.Lock()
+	defer workflowsMu.Unlock()
+	workflows[spec.WorkflowID] = &spec
+}
+
+// GetWorkflow 根据工作流ID获取已注册的定义
+func GetWorkflow(workflowId string) (*WorkflowSpec, bool) {
+	workflowsMu.RLock()
+	defer workflowsMu.RUnlock()
+	spec, ok := workflows[workflowId]
+	return spec, ok
+}
+
+// RegisterDefaultWorkflows 注册配置文件中的默认工作流
+func RegisterDefaultWorkflows() {
+	workflowId := config.GetCozeConfig().WorkFlowID
+	if workflowId == "" {
+		return
+	}
+
+	RegisterWorkflow(WorkflowSpec{
+		WorkflowID: workflowId,
+		Name:       "默认工作流",
+		Schema:     json.RawMessage(`{"type":"object","properties":{"input":{"type":"string"}},"required":["input"]}`),
+		Inject: map[string]string{
+			"ip":    TrustedClientIP,
+			"token": TrustedUserToken,
+		},
+	})
+}
+
+// BuildWorkflowParameters 校验调用方参数并注入可信参数，调用方传入的同名可信参数会被覆盖
+func BuildWorkflowParameters(workflowId string, parameters map[string]interface{}, caller WorkflowCaller) (map[string]interface{}, error) {
+	spec, ok := GetWorkflow(workflowId)
+	if !ok {
+		return nil, fmt.Errorf("工作流 %s 未注册", workflowId)
+	}
+
+	result := make(map[string]interface{}, len(parameters)+len(spec.Inject))
+	for key, value := range parameters {
+		if _, trusted := spec.Inject[key]; trusted {
+			continue
+		}
+		result[key] = value
+	}
+
+	if err := utils.ValidateJSONSchema(spec.Schema, result); err != nil {
+		return nil, err
+	}
+
+	for key, source := range spec.Inject {
+		switch source {
+		case TrustedClientIP:
+			result[key] = caller.ClientIP
+		case TrustedUserID:
+			result[key] = strconv.FormatUint(uint64(caller.UserID), 10)
+		case TrustedUserToken:
+			// 注入只能用于工作流回调的短期令牌，不转发调用方的登录令牌
+			token, err := utils.GenerateWorkflowToken(caller.UserID, config.Cfg.JWT.Secret)
+			if err != nil {
+				return nil, fmt.Errorf("生成工作流令牌失败: %v", err)
+			}
+			result[key] = token
+		default:
+			return nil, fmt.Errorf("未知的可信参数来源: %s", source)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"coze-agent-platform/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	cozeapi "github.com/coze-dev/coze-go"
+	"gorm.io/gorm"
+)
+
+const (
+	// 异步运行连续查询运行结果失败的次数上限
+	maxWorkflowRunSyncAttempts = 30
+	// 流式运行超过该时间未结束视为连接已中断
+	streamWorkflowRunTimeout = 30 * time.Minute
+)
+
+type workflowRunService struct{}
+
+func NewWorkflowRunService() models.WorkflowRunService {
+	return &workflowRunService{}
+}
+
+func (s *workflowRunService) CreateWorkflowRun(run *models.WorkflowRun) error {
+	return models.DB.Create(run).Error
+}
+
+func (s *workflowRunService) GetWorkflowRunById(id uint) (*models.WorkflowRun, error) {
+	var run models.WorkflowRun
+	err := models.DB.First(&run, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("工作流运行记录不存在")
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (s *workflowRunService) GetWorkflowRunsByUserId(userId uint, status string, page, pageSize int) ([]*models.WorkflowRun, int64, error) {
+	var runs []*models.WorkflowRun
+	var total int64
+
+	query := models.DB.Model(&models.WorkflowRun{}).Where("user_id = ?", userId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 计算总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&runs).Error
+	return runs, total, err
+}
+
+// GetRunningAsyncWorkflowRuns 获取待同步的异步运行记录，查询失败过的记录排在后面，避免占满批次
+func (s *workflowRunService) GetRunningAsyncWorkflowRuns(limit int) ([]*models.WorkflowRun, error) {
+	var runs []*models.WorkflowRun
+	err := models.DB.Where("status = ? AND is_async = ? AND execute_id <> ''", models.WorkflowRunStatusRunning, true).
+		Order("sync_attempts ASC, updated_at ASC").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+func (s *workflowRunService) UpdateWorkflowRun(run *models.WorkflowRun) error {
+	return models.DB.Save(run).Error
+}
+
+// SyncWorkflowRunStatus 从Coze运行记录同步工作流运行状态
+func (s *workflowRunService) SyncWorkflowRunStatus(run *models.WorkflowRun) error {
+	cozeClient, err := GetUserCozeClient(run.UserId)
+	if err != nil {
+		return s.recordSyncFailure(run, fmt.Errorf("初始化Coze客户端失败: %v", err))
+	}
+
+	history, err := cozeClient.RetrieveWorkflowRun(run.WorkflowId, run.ExecuteId)
+	if err != nil {
+		return s.recordSyncFailure(run, err)
+	}
+
+	switch history.ExecuteStatus {
+	case cozeapi.WorkflowExecuteStatusSuccess:
+		run.Status = models.WorkflowRunStatusSuccess
+	case cozeapi.WorkflowExecuteStatusFail:
+		run.Status = models.WorkflowRunStatusFail
+	default:
+		// 仍在运行中，仅刷新更新时间以便轮询其他记录
+		run.SyncAttempts = 0
+		return models.DB.Model(run).Updates(map[string]interface{}{
+			"sync_attempts": 0,
+			"updated_at":    time.Now(),
+		}).Error
+	}
+
+	run.Output = history.Output
+	run.LogId = history.LogID
+	run.DebugUrl = history.DebugURL
+	run.ErrorMessage = history.ErrorMessage
+	run.SyncAttempts = 0
+	return s.UpdateWorkflowRun(run)
+}
+
+// FailStaleStreamWorkflowRuns 将before之前开始且仍在运行的流式运行标记为失败。
+// 流式运行没有execute_id，服务重启或连接中断后无法从Coze查询结果
+func (s *workflowRunService) FailStaleStreamWorkflowRuns(before time.Time) (int64, error) {
+	result := models.DB.Model(&models.WorkflowRun{}).
+		Where("status = ? AND is_async = ? AND updated_at < ?", models.WorkflowRunStatusRunning, false, before).
+		Updates(map[string]interface{}{
+			"status":        models.WorkflowRunStatusFail,
+			"error_message": "流式运行连接已中断，无法获取运行结果",
+		})
+	return result.RowsAffected, result.Error
+}
+
+// 辅助函数：记录一次查询失败，连续失败达到上限后将运行记录标记为失败
+func (s *workflowRunService) recordSyncFailure(run *models.WorkflowRun, cause error) error {
+	run.SyncAttempts++
+	if run.SyncAttempts >= maxWorkflowRunSyncAttempts {
+		run.Status = models.WorkflowRunStatusFail
+		run.ErrorMessage = fmt.Sprintf("查询运行结果连续失败%d次: %v", run.SyncAttempts, cause)
+		if err := s.UpdateWorkflowRun(run); err != nil {
+			return err
+		}
+		return cause
+	}
+
+	if err := models.DB.Model(run).Updates(map[string]interface{}{
+		"sync_attempts": run.SyncAttempts,
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	return cause
+}
+
+// StartWorkflowRunPoller 启动后台任务，定期同步运行中的异步工作流状态，并将超时未结束的流式运行标记为失败
+func StartWorkflowRunPoller(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		runService := NewWorkflowRunService()
+		for range ticker.C {
+			if models.DB == nil {
+				continue
+			}
+
+			if count, err := runService.FailStaleStreamWorkflowRuns(time.Now().Add(-streamWorkflowRunTimeout)); err != nil {
+				log.Printf("标记超时的流式工作流失败: %v", err)
+			} else if count > 0 {
+				log.Printf("已将%d条超时的流式工作流标记为失败", count)
+			}
+
+			runs, err := runService.GetRunningAsyncWorkflowRuns(50)
+			if err != nil {
+				log.Printf("查询运行中的工作流失败: %v", err)
+				continue
+			}
+
+			for _, run := range runs {
+				if err := runService.SyncWorkflowRunStatus(run); err != nil {
+					log.Printf("同步工作流运行状态失败 run_id=%d: %v", run.ID, err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"coze-agent-platform/config"
+	"coze-agent-platform/models"
+	"coze-agent-platform/utils"
+	"coze-agent-platform/utils/coze"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type workspaceService struct{}
+
+func NewWorkspaceService() models.WorkspaceService {
+	return &workspaceService{}
+}
+
+func (s *workspaceService) CreateWorkspace(workspace *models.Workspace, privateKey string) error {
+	if strings.TrimSpace(privateKey) == "" {
+		return errors.New("缺少Coze私钥")
+	}
+
+	encrypted, err := utils.EncryptCredential(privateKey)
+	if err != nil {
+		return err
+	}
+	workspace.CozePrivateKey = encrypted
+	return models.DB.Create(workspace).Error
+}
+
+func (s *workspaceService) GetWorkspaceById(id uint) (*models.Workspace, error) {
+	var workspace models.Workspace
+	err := models.DB.First(&workspace, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("工作空间不存在")
+		}
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+func (s *workspaceService) ListWorkspaces(page, pageSize int) ([]*models.Workspace, int64, error) {
+	var workspaces []*models.Workspace
+	var total int64
+
+	if err := models.DB.Model(&models.Workspace{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := models.DB.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&workspaces).Error
+	return workspaces, total, err
+}
+
+func (s *workspaceService) UpdateWorkspace(workspace *models.Workspace, privateKey string) error {
+	if strings.TrimSpace(privateKey) != "" {
+		encrypted, err := utils.EncryptCredential(privateKey)
+		if err != nil {
+			return err
+		}
+		workspace.CozePrivateKey = encrypted
+	}
+
+	if err := models.DB.Save(workspace).Error; err != nil {
+		return err
+	}
+	coze.InvalidateWorkspace(workspace.ID)
+	return nil
+}
+
+func (s *workspaceService) DeleteWorkspace(id uint) error {
+	var count int64
+	if err := models.DB.Model(&models.User{}).Where("workspace_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("工作空间下仍有用户，无法删除")
+	}
+
+	if err := models.DB.Delete(&models.Workspace{}, id).Error; err != nil {
+		return err
+	}
+	coze.InvalidateWorkspace(id)
+	return nil
+}
+
+func (s *workspaceService) SetUserWorkspace(userId uint, workspaceId uint) error {
+	if workspaceId != 0 {
+		if _, err := s.GetWorkspaceById(workspaceId); err != nil {
+			return err
+		}
+	}
+
+	result := models.DB.Model(&models.User{}).Where("id = ?", userId).Update("workspace_id", workspaceId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	return nil
+}
+
+func (s *workspaceService) GetWorkspaceCozeConfig(id uint) (*config.CozeConfig, error) {
+	workspace, err := s.GetWorkspaceById(id)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.Status != 1 {
+		return nil, errors.New("工作空间已禁用")
+	}
+
+	privateKey, err := utils.DecryptCredential(workspace.CozePrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := workspace.CozeAPIURL
+	if apiURL == "" {
+		apiURL = config.GetCozeConfig().APIURL
+	}
+	return &config.CozeConfig{
+		APIURL:      apiURL,
+		ClientID:    workspace.CozeClientID,
+		PublicKeyID: workspace.CozePublicKeyID,
+		PrivateKey:  privateKey,
+		BotID:       workspace.CozeBotID,
+	}, nil
+}
+
+// GetUserWorkspaceId 返回用户所属的工作空间ID，未登录或未加入工作空间时返回0
+func GetUserWorkspaceId(userId uint) (uint, error) {
+	if userId == 0 {
+		return 0, nil
+	}
+
+	var user models.User
+	err := models.DB.Select("id", "workspace_id").First(&user, userId).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, errors.New("用户不存在")
+		}
+		return 0, err
+	}
+	return user.WorkspaceId, nil
+}
+
+// GetUserCozeClient 返回用户所属工作空间的Coze客户端
+func GetUserCozeClient(userId uint) (*coze.Client, error) {
+	workspaceId, err := GetUserWorkspaceId(userId)
+	if err != nil {
+		return nil, err
+	}
+	return coze.ForWorkspace(workspaceId)
+}
+
+// LoadWorkspaceCozeConfig 供 coze.SetWorkspaceConfigLoader 使用
+func LoadWorkspaceCozeConfig(workspaceId uint) (*config.CozeConfig, error) {
+	return NewWorkspaceService().GetWorkspaceCozeConfig(workspaceId)
+}
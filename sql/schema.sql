@@ -13,10 +13,12 @@ CREATE TABLE IF NOT EXISTS user (
     avatar VARCHAR(255) COMMENT '头像URL',
     role VARCHAR(20) DEFAULT 'user' COMMENT '角色：user/admin',
     status INT DEFAULT 1 COMMENT '状态：1-正常，0-禁用',
+    workspace_id INT UNSIGNED DEFAULT 0 COMMENT '所属工作空间Id，0表示使用全局Coze配置',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     deleted_at TIMESTAMP NULL COMMENT '删除时间',
-    INDEX idx_username (username)
+    INDEX idx_username (username),
+    INDEX idx_workspace_id (workspace_id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
 
 -- 聊天会话表
@@ -24,7 +26,41 @@ CREATE TABLE IF NOT EXISTS conversation (
     id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '会话Id',
     coze_conversation_id VARCHAR(100) NOT NULL COMMENT 'Coze会话Id',
     user_id INT UNSIGNED NOT NULL COMMENT '用户Id',
+    agent_id INT UNSIGNED DEFAULT 0 COMMENT 'AgentId，为0时使用全局Bot',
     title VARCHAR(100) COMMENT '会话标题',
+    workflow_id VARCHAR(100) DEFAULT '' COMMENT '绑定的对话流Id，为空时与Bot对话',
+    archived TINYINT(1) DEFAULT 0 COMMENT '是否已归档',
+    pinned TINYINT(1) DEFAULT 0 COMMENT '是否置顶',
+    pinned_at TIMESTAMP NULL COMMENT '置顶时间',
+    folder_id INT UNSIGNED DEFAULT 0 COMMENT '所属文件夹Id，为0时不在文件夹中',
+    active_message_id INT UNSIGNED DEFAULT 0 COMMENT '当前分支的最后一条消息Id',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    deleted_at TIMESTAMP NULL COMMENT '删除时间',
+    INDEX idx_user_id (user_id),
+    INDEX idx_user_archived_pinned (user_id, archived, pinned),
+    INDEX idx_agent_id (agent_id),
+    INDEX idx_folder_id (folder_id),
+    FULLTEXT INDEX ft_title (title) WITH PARSER ngram
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 对话标签表
+CREATE TABLE IF NOT EXISTS conversation_tag (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '标签记录Id',
+    conversation_id INT UNSIGNED NOT NULL COMMENT '会话Id',
+    user_id INT UNSIGNED NOT NULL COMMENT '用户Id',
+    tag VARCHAR(20) NOT NULL COMMENT '标签',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    UNIQUE KEY uk_conversation_tag (conversation_id, tag),
+    INDEX idx_user_tag (user_id, tag)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 对话文件夹表
+CREATE TABLE IF NOT EXISTS conversation_folder (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '文件夹Id',
+    user_id INT UNSIGNED NOT NULL COMMENT '用户Id',
+    name VARCHAR(50) NOT NULL COMMENT '名称',
+    sort INT DEFAULT 0 COMMENT '排序，越小越靠前',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     deleted_at TIMESTAMP NULL COMMENT '删除时间',
@@ -35,14 +71,149 @@ CREATE TABLE IF NOT EXISTS conversation (
 CREATE TABLE IF NOT EXISTS message (
     id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '消息Id',
     coze_message_id VARCHAR(100) NOT NULL COMMENT 'Coze消息Id',
+    chat_id VARCHAR(100) COMMENT 'Coze对话轮次Id',
+    coze_conversation_id VARCHAR(100) DEFAULT '' COMMENT '消息所在的Coze会话Id，为空时为会话当前的Coze会话',
     conversation_id INT UNSIGNED NOT NULL COMMENT '会话Id',
+    parent_id INT UNSIGNED DEFAULT 0 COMMENT '上一条消息Id，为0时是对话的第一条消息',
     model_id INT UNSIGNED NOT NULL COMMENT 'AI模型Id',
     metadata VARCHAR(255) COMMENT '元数据，如模型参数等',
     role VARCHAR(10) NOT NULL COMMENT 'user或assistant',
     content TEXT NOT NULL COMMENT '消息内容',
+    parts JSON NULL COMMENT '多模态内容片段',
     tokens INT DEFAULT 0 COMMENT '消耗Token数量',
+    status VARCHAR(20) DEFAULT 'completed' COMMENT '状态：completed-已完成，cancelled-已取消',
+    sync_status VARCHAR(20) DEFAULT '' COMMENT '同步状态：空-未同步，synced-已同步，diverged-内容不一致，local_only-仅本地存在',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     deleted_at TIMESTAMP NULL COMMENT '删除时间',
-    INDEX idx_chat_id (conversation_id)
+    INDEX idx_chat_id (conversation_id),
+    INDEX idx_coze_chat_id (chat_id),
+    INDEX idx_parent_id (parent_id),
+    INDEX idx_sync_status (sync_status),
+    FULLTEXT INDEX ft_content (content) WITH PARSER ngram
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 回复评价表
+CREATE TABLE IF NOT EXISTS message_feedback (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '评价Id',
+    message_id INT UNSIGNED NOT NULL COMMENT '消息Id',
+    user_id INT UNSIGNED NOT NULL COMMENT '评价用户Id',
+    conversation_id INT UNSIGNED NOT NULL COMMENT '会话Id',
+    agent_id INT UNSIGNED DEFAULT 0 COMMENT 'AgentId',
+    rating INT NOT NULL COMMENT '评价：1-好评，-1-差评',
+    comment VARCHAR(1000) COMMENT '评价说明',
+    forward_status VARCHAR(20) DEFAULT '' COMMENT '同步状态：synced-已同步到Coze，failed-同步失败，unsupported-不支持同步',
+    forward_error VARCHAR(500) COMMENT '同步失败原因',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_message_user (message_id, user_id),
+    INDEX idx_conversation_id (conversation_id),
+    INDEX idx_agent_created (agent_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 评价原因表
+CREATE TABLE IF NOT EXISTS message_feedback_reason (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '记录Id',
+    feedback_id INT UNSIGNED NOT NULL COMMENT '评价Id',
+    reason VARCHAR(20) NOT NULL COMMENT '原因标签',
+    INDEX idx_feedback_id (feedback_id),
+    INDEX idx_reason (reason)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 工作流运行记录表
+CREATE TABLE IF NOT EXISTS workflow_run (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '运行记录Id',
+    workflow_id VARCHAR(100) NOT NULL COMMENT 'Coze工作流Id',
+    user_id INT UNSIGNED NOT NULL COMMENT '用户Id',
+    execute_id VARCHAR(100) COMMENT 'Coze运行Id',
+    parameters TEXT COMMENT '调用方传入的参数',
+    is_async TINYINT(1) DEFAULT 0 COMMENT '是否异步运行',
+    status VARCHAR(20) NOT NULL COMMENT '状态：running/success/fail/interrupted',
+    output LONGTEXT COMMENT '运行输出',
+    token_count INT DEFAULT 0 COMMENT '消耗Token数量',
+    log_id VARCHAR(100) COMMENT 'Coze日志Id',
+    debug_url VARCHAR(500) COMMENT '调试地址',
+    error_message TEXT COMMENT '错误信息',
+    interrupt_event_id VARCHAR(100) COMMENT '中断事件Id',
+    interrupt_type INT DEFAULT 0 COMMENT '中断类型',
+    interrupt_node VARCHAR(100) COMMENT '中断节点',
+    interrupt_prompt TEXT COMMENT '中断节点提示内容',
+    sync_attempts INT DEFAULT 0 COMMENT '连续查询运行结果失败次数',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    deleted_at TIMESTAMP NULL COMMENT '删除时间',
+    INDEX idx_workflow_id (workflow_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_execute_id (execute_id),
+    INDEX idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 知识库文档表
+CREATE TABLE IF NOT EXISTS knowledge_document (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '文档记录Id',
+    dataset_id VARCHAR(100) NOT NULL COMMENT 'Coze知识库Id',
+    document_id VARCHAR(100) NOT NULL UNIQUE COMMENT 'Coze文档Id',
+    user_id INT UNSIGNED NOT NULL COMMENT '上传用户Id',
+    agent_id INT UNSIGNED DEFAULT 0 COMMENT '所属AgentId',
+    name VARCHAR(255) NOT NULL COMMENT '文档名称',
+    source_type VARCHAR(10) NOT NULL COMMENT '来源：file/url',
+    source_url VARCHAR(1000) COMMENT '网页地址',
+    size BIGINT DEFAULT 0 COMMENT '文件大小',
+    status INT DEFAULT 0 COMMENT '状态：0-处理中，1-已完成，9-失败',
+    progress INT DEFAULT 0 COMMENT '处理进度',
+    status_descript VARCHAR(255) COMMENT '状态描述',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    deleted_at TIMESTAMP NULL COMMENT '删除时间',
+    INDEX idx_dataset_id (dataset_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_agent_id (agent_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 知识库表，记录通过平台创建的知识库及其创建者
+CREATE TABLE IF NOT EXISTS knowledge_dataset (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '知识库记录Id',
+    dataset_id VARCHAR(100) NOT NULL UNIQUE COMMENT 'Coze知识库Id',
+    space_id VARCHAR(100) NOT NULL COMMENT 'Coze空间Id',
+    user_id INT UNSIGNED NOT NULL COMMENT '创建用户Id',
+    name VARCHAR(255) NOT NULL COMMENT '知识库名称',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    deleted_at TIMESTAMP NULL COMMENT '删除时间',
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- Webhook事件表
+CREATE TABLE IF NOT EXISTS webhook_event (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '事件记录Id',
+    source VARCHAR(20) NOT NULL COMMENT '来源：coze',
+    event_id VARCHAR(100) NOT NULL COMMENT '事件Id',
+    event_type VARCHAR(100) NOT NULL COMMENT '事件类型',
+    payload LONGTEXT COMMENT '原始请求体',
+    status VARCHAR(20) NOT NULL COMMENT '处理状态：pending/processed/failed/ignored',
+    attempts INT DEFAULT 0 COMMENT '处理次数',
+    error TEXT COMMENT '最近一次处理错误',
+    processed_at TIMESTAMP NULL COMMENT '最近一次处理时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    deleted_at TIMESTAMP NULL COMMENT '删除时间',
+    UNIQUE INDEX idx_source_event_id (source, event_id),
+    INDEX idx_event_type (event_type),
+    INDEX idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 工作空间表
+CREATE TABLE IF NOT EXISTS workspace (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY COMMENT '工作空间Id',
+    name VARCHAR(100) NOT NULL COMMENT '名称',
+    description VARCHAR(500) COMMENT '描述',
+    status INT DEFAULT 1 COMMENT '状态：1-正常，0-禁用',
+    coze_api_url VARCHAR(255) COMMENT 'Coze API地址，为空时使用全局配置',
+    coze_client_id VARCHAR(100) NOT NULL COMMENT 'Coze OAuth应用Id',
+    coze_public_key_id VARCHAR(100) NOT NULL COMMENT 'Coze OAuth公钥Id',
+    coze_private_key TEXT NOT NULL COMMENT 'Coze OAuth私钥，AES-GCM加密',
+    coze_bot_id VARCHAR(100) COMMENT '默认BotId',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    deleted_at TIMESTAMP NULL COMMENT '删除时间'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,128 @@
+package transcript
+
+import (
+	"coze-agent-platform/models"
+	"strings"
+	"time"
+)
+
+// 导出格式
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+
+	DefaultFormat = FormatMarkdown
+)
+
+// DocumentVersion JSON导出格式的版本，导入时用于识别本服务导出的文件
+const DocumentVersion = 1
+
+// Document 一个对话的导出内容，JSON导出格式即该结构
+type Document struct {
+	Version      int              `json:"version"`
+	ExportedAt   time.Time        `json:"exported_at"`
+	Conversation ConversationInfo `json:"conversation"`
+	Messages     []*Message       `json:"messages"`
+}
+
+// ConversationInfo 对话信息
+type ConversationInfo struct {
+	ID         uint      `json:"id"`
+	Title      string    `json:"title"`
+	AgentID    uint      `json:"agent_id"`
+	WorkflowID string    `json:"workflow_id,omitempty"`
+	Tags       []string  `json:"tags"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Message 当前分支上的一条消息
+type Message struct {
+	ID        uint                `json:"id,omitempty"`
+	Role      string              `json:"role"` // user、assistant、system
+	Content   string              `json:"content"`
+	Parts     models.MessageParts `json:"parts,omitempty"`
+	Status    string              `json:"status,omitempty"` // completed、cancelled
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// NewDocument 根据对话及其当前分支上的消息生成导出内容
+func NewDocument(conversation *models.Conversation, messages []*models.Message) *Document {
+	tags := conversation.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	document := &Document{
+		Version:    DocumentVersion,
+		ExportedAt: time.Now(),
+		Conversation: ConversationInfo{
+			ID:         conversation.ID,
+			Title:      conversation.Title,
+			AgentID:    conversation.AgentId,
+			WorkflowID: conversation.WorkflowId,
+			Tags:       tags,
+			CreatedAt:  conversation.CreatedAt,
+			UpdatedAt:  conversation.UpdatedAt,
+		},
+		Messages: make([]*Message, 0, len(messages)),
+	}
+	for _, message := range messages {
+		document.Messages = append(document.Messages, &Message{
+			ID:        message.ID,
+			Role:      message.Role,
+			Content:   message.Content,
+			Parts:     message.Parts,
+			Status:    message.Status,
+			CreatedAt: message.CreatedAt,
+		})
+	}
+	return document
+}
+
+// Title 对话标题，为空时使用第一条用户消息的开头
+func (d *Document) Title() string {
+	if d.Conversation.Title != "" {
+		return d.Conversation.Title
+	}
+	for _, message := range d.Messages {
+		if message.Role != "user" {
+			continue
+		}
+		if content := strings.Join(strings.Fields(message.Content), " "); content != "" {
+			return truncateRunes(content, defaultTitleLength)
+		}
+	}
+	return "未命名对话"
+}
+
+// ToMessages 转换为待保存的本地消息，按顺序依次作为上一条消息的回复。
+// 图片和文件片段引用的是原后端的文件，不随消息导入
+func (d *Document) ToMessages() []*models.Message {
+	messages := make([]*models.Message, 0, len(d.Messages))
+	for _, message := range d.Messages {
+		status := models.MessageStatusCompleted
+		if message.Status == models.MessageStatusCancelled {
+			status = models.MessageStatusCancelled
+		}
+		messages = append(messages, &models.Message{
+			Role:      message.Role,
+			Content:   message.Content,
+			Status:    status,
+			CreatedAt: message.CreatedAt,
+		})
+	}
+	return messages
+}
+
+// 未设置标题时从第一条用户消息截取的长度
+const defaultTitleLength = 30
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,143 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxImportMessages 单个对话最多导入的消息数
+const MaxImportMessages = 1000
+
+// MaxMessageContentBytes 单条消息内容的最大字节数，与消息表content字段的TEXT类型一致
+const MaxMessageContentBytes = 65535
+
+// importMessage 兼容本服务导出的消息和OpenAI格式的消息
+type importMessage struct {
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"` // 字符串，或OpenAI格式的内容片段数组
+	Status    string          `json:"status"`
+	CreatedAt *time.Time      `json:"created_at"`
+}
+
+// openAIContentPart OpenAI格式的内容片段，只导入文本片段
+type openAIContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type importDocument struct {
+	Version      int               `json:"version"`
+	Conversation *ConversationInfo `json:"conversation"`
+	Messages     []*importMessage  `json:"messages"`
+}
+
+// ParseImport 解析导入数据，支持本服务的JSON导出格式、OpenAI格式的消息数组，
+// 以及包含 messages 字段的OpenAI请求体。工具调用相关的消息和空消息会被忽略
+func ParseImport(data []byte) (*Document, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("导入内容为空")
+	}
+
+	var raw importDocument
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &raw.Messages); err != nil {
+			return nil, fmt.Errorf("消息格式错误: %v", err)
+		}
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("导入内容格式错误: %v", err)
+	}
+
+	document := &Document{Version: DocumentVersion}
+	if raw.Conversation != nil {
+		document.Conversation = *raw.Conversation
+	}
+
+	// 没有时间的消息使用导入时间，按顺序递增以保持先后关系
+	now := time.Now()
+	for i, message := range raw.Messages {
+		if message == nil {
+			continue
+		}
+		role, ok := normalizeRole(message.Role)
+		if !ok {
+			return nil, fmt.Errorf("第%d条消息的角色不支持: %s", i+1, message.Role)
+		}
+		if role == "" {
+			continue
+		}
+		content, err := parseContent(message.Content)
+		if err != nil {
+			return nil, fmt.Errorf("第%d条消息的内容格式错误: %v", i+1, err)
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		if len(content) > MaxMessageContentBytes {
+			return nil, fmt.Errorf("第%d条消息的内容不能超过%d字节", i+1, MaxMessageContentBytes)
+		}
+
+		createdAt := now.Add(time.Duration(i) * time.Millisecond)
+		if message.CreatedAt != nil && !message.CreatedAt.IsZero() {
+			createdAt = *message.CreatedAt
+		}
+		document.Messages = append(document.Messages, &Message{
+			Role:      role,
+			Content:   content,
+			Status:    message.Status,
+			CreatedAt: createdAt,
+		})
+	}
+
+	if len(document.Messages) == 0 {
+		return nil, errors.New("没有可导入的消息")
+	}
+	if len(document.Messages) > MaxImportMessages {
+		return nil, fmt.Errorf("消息数量不能超过%d条", MaxImportMessages)
+	}
+	return document, nil
+}
+
+// normalizeRole 返回本地保存的角色，返回空字符串表示忽略该消息，ok为false表示角色不支持
+func normalizeRole(role string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "user":
+		return "user", true
+	case "assistant":
+		return "assistant", true
+	case "system", "developer":
+		return "system", true
+	case "tool", "function":
+		return "", true
+	}
+	return "", false
+}
+
+// parseContent 内容为字符串，或OpenAI格式的内容片段数组（只保留文本片段）
+func parseContent(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var content string
+		err := json.Unmarshal(raw, &content)
+		return content, err
+	}
+
+	var parts []openAIContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", err
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
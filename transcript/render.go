@@ -0,0 +1,162 @@
+package transcript
+
+import (
+	"coze-agent-platform/models"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 导出文件中的时间格式
+const timeLayout = "2006-01-02 15:04:05"
+
+// 文件名中标题部分的最大长度
+const fileNameTitleLength = 40
+
+var roleNames = map[string]string{
+	"user":      "用户",
+	"assistant": "助手",
+	"system":    "系统",
+}
+
+// ValidFormat 是否为支持的导出格式
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatMarkdown, FormatJSON, FormatHTML:
+		return true
+	}
+	return false
+}
+
+// ContentType 导出格式对应的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[\\/:*?"<>|\s]+`)
+
+// FileName 导出文件名：conversation-{id}-{标题}.{格式}
+func FileName(document *Document, format string) string {
+	title := strings.Trim(unsafeFileNameChars.ReplaceAllString(document.Title(), "_"), "_.")
+	title = truncateRunes(title, fileNameTitleLength)
+	if title == "" {
+		return fmt.Sprintf("conversation-%d.%s", document.Conversation.ID, format)
+	}
+	return fmt.Sprintf("conversation-%d-%s.%s", document.Conversation.ID, title, format)
+}
+
+// Render 按指定格式输出导出内容
+func Render(w io.Writer, format string, document *Document) error {
+	switch format {
+	case FormatMarkdown:
+		return renderMarkdown(w, document)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, document)
+	}
+	return fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+func renderMarkdown(w io.Writer, document *Document) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# %s\n\n", document.Title())
+	fmt.Fprintf(&builder, "- 对话ID：%d\n", document.Conversation.ID)
+	fmt.Fprintf(&builder, "- 创建时间：%s\n", document.Conversation.CreatedAt.Format(timeLayout))
+	if len(document.Conversation.Tags) > 0 {
+		fmt.Fprintf(&builder, "- 标签：%s\n", strings.Join(document.Conversation.Tags, "、"))
+	}
+	fmt.Fprintf(&builder, "- 导出时间：%s\n", document.ExportedAt.Format(timeLayout))
+
+	for _, message := range document.Messages {
+		fmt.Fprintf(&builder, "\n---\n\n### %s · %s\n\n", roleName(message.Role), message.CreatedAt.Format(timeLayout))
+		builder.WriteString(message.Content)
+		builder.WriteString("\n")
+		for _, part := range message.Parts {
+			if attachment := attachmentName(part.Type); attachment != "" {
+				fmt.Fprintf(&builder, "\n- %s：%s\n", attachment, attachmentRef(part.FileURL, part.FileID))
+			}
+		}
+		if message.Status == models.MessageStatusCancelled {
+			builder.WriteString("\n*（回复已取消）*\n")
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func roleName(role string) string {
+	if name, ok := roleNames[role]; ok {
+		return name
+	}
+	return role
+}
+
+func attachmentName(partType string) string {
+	switch partType {
+	case models.MessagePartImage:
+		return "图片"
+	case models.MessagePartFile:
+		return "文件"
+	}
+	return ""
+}
+
+func attachmentRef(fileURL, fileID string) string {
+	if fileURL != "" {
+		return fileURL
+	}
+	return "file_id=" + fileID
+}
+
+// 内容经 html/template 转义，保留换行
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"roleName":       roleName,
+	"attachmentName": attachmentName,
+	"attachmentRef":  attachmentRef,
+	"formatTime": func(t time.Time) string {
+		return t.Format(timeLayout)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 800px; margin: 0 auto; padding: 24px; font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; color: #1f2329; }
+.meta { color: #8f959e; font-size: 13px; }
+.message { margin: 16px 0; padding: 12px 16px; border-radius: 8px; background: #f5f6f7; }
+.message.user { background: #e8f3ff; }
+.message.system { background: #fff7e8; }
+.role { font-weight: 600; margin-bottom: 6px; }
+.role .time { font-weight: normal; color: #8f959e; font-size: 12px; margin-left: 8px; }
+.content { white-space: pre-wrap; word-break: break-word; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">对话ID：{{.Conversation.ID}} · 创建时间：{{formatTime .Conversation.CreatedAt}}{{if .Conversation.Tags}} · 标签：{{range $i, $tag := .Conversation.Tags}}{{if $i}}、{{end}}{{$tag}}{{end}}{{end}}</p>
+{{range .Messages}}<div class="message {{.Role}}">
+<div class="role">{{roleName .Role}}<span class="time">{{formatTime .CreatedAt}}</span></div>
+<div class="content">{{.Content}}</div>
+{{range .Parts}}{{$part := .}}{{with attachmentName .Type}}<div class="meta">{{.}}：{{attachmentRef $part.FileURL $part.FileID}}</div>{{end}}{{end}}
+{{if eq .Status "cancelled"}}<div class="meta">（回复已取消）</div>{{end}}
+</div>
+{{end}}</body>
+</html>
+`))
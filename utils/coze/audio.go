@@ -0,0 +1,129 @@
+package coze
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// MaxSpeechInputBytes 单次语音合成的文本长度上限（字节）
+const MaxSpeechInputBytes = 1024
+
+// 语音合成支持的音频格式及对应的Content-Type
+var speechContentTypes = map[string]string{
+	string(coze.AudioFormatMP3):     "audio/mpeg",
+	string(coze.AudioFormatWAV):     "audio/wav",
+	string(coze.AudioFormatPCM):     "audio/pcm",
+	string(coze.AudioFormatOGGOPUS): "audio/ogg",
+	string(coze.AudioFormatM4A):     "audio/mp4",
+	string(coze.AudioFormatAAC):     "audio/aac",
+}
+
+// DefaultVoiceID 返回默认的音色ID，未在请求中指定音色时使用
+func DefaultVoiceID() string {
+	return os.Getenv("COZE_VOICE_ID")
+}
+
+// SpeechContentType 返回音频格式对应的Content-Type，格式不支持时返回false
+func SpeechContentType(format string) (string, bool) {
+	contentType, ok := speechContentTypes[format]
+	return contentType, ok
+}
+
+// Transcribe 语音转文字
+func (audio *Client) Transcribe(filename string, file io.Reader) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var resp *coze.CreateAudioTranscriptionsResp
+	err := audio.call(ctx, "audio.transcriptions.create", false, func() (err error) {
+		resp, err = audio.Api.Audio.Transcriptions.Create(ctx, &coze.AudioSpeechTranscriptionsReq{
+			Filename: filename,
+			Audio:    file,
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("语音识别失败: %w", err)
+	}
+
+	return resp.Data.Text, nil
+}
+
+// Speech 文字转语音，返回的音频流由调用方关闭，speed为0时使用默认语速
+func (audio *Client) Speech(ctx context.Context, input string, voiceID string, format string, speed float32) (io.ReadCloser, error) {
+	req := &coze.CreateAudioSpeechReq{
+		Input:          input,
+		VoiceID:        voiceID,
+		ResponseFormat: coze.AudioFormat(format).Ptr(),
+	}
+	if speed > 0 {
+		req.Speed = &speed
+	}
+
+	var resp *coze.CreateAudioSpeechResp
+	err := audio.call(ctx, "audio.speech.create", true, func() (err error) {
+		resp, err = audio.Api.Audio.Speech.Create(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("语音合成失败: %w", err)
+	}
+
+	return resp.Data, nil
+}
+
+// ListVoices 获取可用音色列表，返回音色及是否还有下一页
+func (audio *Client) ListVoices(filterSystemVoice bool, page int, size int) ([]*coze.Voice, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp coze.NumberPaged[coze.Voice]
+	err := audio.call(ctx, "audio.voices.list", true, func() (err error) {
+		resp, err = audio.Api.Audio.Voices.List(ctx, &coze.ListAudioVoicesReq{
+			FilterSystemVoice: filterSystemVoice,
+			PageNum:           page,
+			PageSize:          size,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("获取音色列表失败: %w", err)
+	}
+
+	return resp.Items(), resp.HasMore(), nil
+}
+
+// SplitSpeechInput 将长文本按句子切分为不超过单次合成上限的片段
+func SplitSpeechInput(text string) []string {
+	var segments []string
+	text = strings.TrimSpace(text)
+	for len(text) > MaxSpeechInputBytes {
+		// 在上限内回退到合法的UTF-8边界
+		end := MaxSpeechInputBytes
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+
+		// 优先在句末标点处切分
+		if i := strings.LastIndexAny(text[:end], "。！？；.!?;\n"); i > 0 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			end = i + size
+		}
+
+		if segment := strings.TrimSpace(text[:end]); segment != "" {
+			segments = append(segments, segment)
+		}
+		text = strings.TrimSpace(text[end:])
+	}
+	if text != "" {
+		segments = append(segments, text)
+	}
+	return segments
+}
@@ -0,0 +1,264 @@
+package coze
+
+import (
+	"context"
+	"coze-agent-platform/config"
+	"coze-agent-platform/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// 在Token过期前提前刷新的时间
+	tokenRefreshBefore = 60 * time.Second
+	// 跨实例刷新锁的持有时间及等待其他实例刷新的最长时间
+	tokenLockTTL  = 10 * time.Second
+	tokenLockWait = 5 * time.Second
+)
+
+// 仅当锁仍由自己持有时才释放
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// cachedToken Redis中缓存的Token
+type cachedToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresAt   int64  `json:"expires_at"` // Unix时间戳，单位秒
+}
+
+func (t *cachedToken) valid() bool {
+	return t != nil && t.AccessToken != "" &&
+		time.Now().Add(tokenRefreshBefore).Before(time.Unix(t.ExpiresAt, 0))
+}
+
+// TokenProvider 实现 coze.Auth，在Token过期前自动刷新。
+// 进程内通过互斥锁合并并发刷新，多实例之间通过Redis锁合并刷新。
+type TokenProvider struct {
+	cacheKey string
+	oauth    *coze.JWTOAuthClient
+
+	mu    sync.Mutex
+	token *cachedToken
+}
+
+var _ coze.Auth = (*TokenProvider)(nil)
+
+// NewTokenProvider 根据Coze配置创建Token提供者，cacheKey 为Token在Redis中的缓存键
+func NewTokenProvider(cacheKey string, cozeConfig *config.CozeConfig) (*TokenProvider, error) {
+	// 优先使用配置文件中的私钥字符串，如果为空则尝试读取文件
+	var jwtOauthPrivateKey string
+	if cozeConfig.PrivateKey != "" {
+		jwtOauthPrivateKey = cozeConfig.PrivateKey
+	} else if cozeConfig.PrivateKeyFilePath != "" {
+		privateKeyBytes, err := os.ReadFile(cozeConfig.PrivateKeyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("读取私钥文件失败: %v", err)
+		}
+		jwtOauthPrivateKey = string(privateKeyBytes)
+	} else {
+		return nil, fmt.Errorf("未配置私钥")
+	}
+
+	oauth, err := coze.NewJWTOAuthClient(coze.NewJWTOAuthClientParam{
+		PrivateKeyPEM: jwtOauthPrivateKey,
+		ClientID:      cozeConfig.ClientID,
+		PublicKey:     cozeConfig.PublicKeyID,
+	}, coze.WithAuthBaseURL(cozeConfig.APIURL))
+	if err != nil {
+		return nil, fmt.Errorf("创建JWT OAuth客户端失败: %v", err)
+	}
+
+	return &TokenProvider{
+		cacheKey: cacheKey,
+		oauth:    oauth,
+	}, nil
+}
+
+// Token 返回有效的访问令牌，即将过期时自动刷新
+func (p *TokenProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token.valid() {
+		return p.token.AccessToken, nil
+	}
+
+	token, err := p.refresh(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	p.token = token
+	return token.AccessToken, nil
+}
+
+// ForceRefresh 令牌被服务端拒绝时强制刷新，rejected 为被拒绝的令牌
+func (p *TokenProvider) ForceRefresh(ctx context.Context, rejected string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 其他请求已经完成刷新
+	if p.token.valid() && p.token.AccessToken != rejected {
+		return p.token.AccessToken, nil
+	}
+
+	token, err := p.refresh(ctx, rejected)
+	if err != nil {
+		return "", err
+	}
+	p.token = token
+	return token.AccessToken, nil
+}
+
+// refresh 依次尝试Redis缓存、获取Redis锁后调用OAuth接口，rejected 非空时忽略与之相同的缓存令牌
+func (p *TokenProvider) refresh(ctx context.Context, rejected string) (*cachedToken, error) {
+	if utils.RDB == nil {
+		return p.fetch(ctx)
+	}
+
+	if token := p.loadCached(ctx); token.valid() && token.AccessToken != rejected {
+		return token, nil
+	}
+
+	lockKey := p.cacheKey + ":lock"
+	lockValue := newLockValue()
+	deadline := time.Now().Add(tokenLockWait)
+	for {
+		locked, err := utils.RDB.SetNX(ctx, lockKey, lockValue, tokenLockTTL).Result()
+		if err != nil {
+			// Redis不可用时直接获取，不阻塞请求
+			log.Printf("获取Coze Token刷新锁失败: %v", err)
+			return p.fetch(ctx)
+		}
+		if locked {
+			break
+		}
+
+		// 其他实例正在刷新，等待其写入缓存
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+		if token := p.loadCached(ctx); token.valid() && token.AccessToken != rejected {
+			return token, nil
+		}
+		if time.Now().After(deadline) {
+			log.Printf("等待Coze Token刷新超时，直接获取")
+			return p.fetch(ctx)
+		}
+	}
+	defer releaseLockScript.Run(context.Background(), utils.RDB, []string{lockKey}, lockValue)
+
+	// 获取锁期间可能已有其他实例完成刷新
+	if token := p.loadCached(ctx); token.valid() && token.AccessToken != rejected {
+		return token, nil
+	}
+
+	token, err := p.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.storeCached(ctx, token)
+	return token, nil
+}
+
+// fetch 调用OAuth接口获取新令牌
+func (p *TokenProvider) fetch(ctx context.Context) (*cachedToken, error) {
+	resp, err := p.oauth.GetAccessToken(ctx, nil)
+	if err != nil {
+		// 保留错误类型，凭证无效时不重试、不计入熔断
+		return nil, fmt.Errorf("获取AccessToken失败: %w", classifyError(err))
+	}
+	// Coze返回的 expires_in 为过期时间的Unix时间戳
+	return &cachedToken{AccessToken: resp.AccessToken, ExpiresAt: resp.ExpiresIn}, nil
+}
+
+func (p *TokenProvider) loadCached(ctx context.Context) *cachedToken {
+	data, err := utils.RDB.Get(ctx, p.cacheKey).Result()
+	if err != nil {
+		return nil
+	}
+	var token cachedToken
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return nil
+	}
+	return &token
+}
+
+func (p *TokenProvider) storeCached(ctx context.Context, token *cachedToken) {
+	expiration := time.Until(time.Unix(token.ExpiresAt, 0)) - tokenRefreshBefore
+	if expiration <= 0 {
+		return
+	}
+	data, _ := json.Marshal(token)
+	if err := utils.RDB.Set(ctx, p.cacheKey, data, expiration).Err(); err != nil {
+		log.Printf("警告: 无法将token存储到Redis: %v", err)
+	}
+}
+
+func (p *TokenProvider) clearCached(ctx context.Context) {
+	if utils.RDB == nil {
+		return
+	}
+	if err := utils.RDB.Del(ctx, p.cacheKey).Err(); err != nil {
+		log.Printf("警告: 无法清除Redis中的token: %v", err)
+	}
+}
+
+func newLockValue() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// authRetryTransport 请求返回401时强制刷新令牌并重试一次
+type authRetryTransport struct {
+	base     http.RoundTripper
+	provider *TokenProvider
+}
+
+func (t *authRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// 请求体无法重放时不重试
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	rejected := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	token, refreshErr := t.provider.ForceRefresh(req.Context(), rejected)
+	if refreshErr != nil {
+		log.Printf("Coze Token强制刷新失败: %v", refreshErr)
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("Authorization", "Bearer "+token)
+	resp.Body.Close()
+
+	return t.base.RoundTrip(retry)
+}
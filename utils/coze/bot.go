@@ -0,0 +1,132 @@
+package coze
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// 发布Bot时使用的渠道，1024为API渠道
+const botAPIConnectorID = "1024"
+
+// BotDefinition 创建或更新Coze Bot时使用的定义
+type BotDefinition struct {
+	Name               string
+	Description        string
+	Prompt             string
+	Prologue           string
+	SuggestedQuestions []string
+	Model              *coze.BotModelInfoConfig
+	Knowledge          *coze.BotKnowledge
+	WorkflowIDs        []string
+}
+
+func (def *BotDefinition) onboardingInfo() *coze.BotOnboardingInfo {
+	if def.Prologue == "" && len(def.SuggestedQuestions) == 0 {
+		return nil
+	}
+	return &coze.BotOnboardingInfo{
+		Prologue:           def.Prologue,
+		SuggestedQuestions: def.SuggestedQuestions,
+	}
+}
+
+func (def *BotDefinition) workflowIDList() *coze.WorkflowIDList {
+	if len(def.WorkflowIDs) == 0 {
+		return nil
+	}
+	list := &coze.WorkflowIDList{}
+	for _, id := range def.WorkflowIDs {
+		list.IDs = append(list.IDs, coze.WorkflowIDInfo{ID: id})
+	}
+	return list
+}
+
+// CreateBot 在空间下创建Bot，返回Bot ID。知识库绑定需创建后通过 UpdateBot 设置
+func (bot *Client) CreateBot(spaceID string, def *BotDefinition) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp *coze.CreateBotsResp
+	err := bot.call(ctx, "bots.create", false, func() (err error) {
+		resp, err = bot.Api.Bots.Create(ctx, &coze.CreateBotsReq{
+			SpaceID:         spaceID,
+			Name:            def.Name,
+			Description:     def.Description,
+			PromptInfo:      &coze.BotPromptInfo{Prompt: def.Prompt},
+			OnboardingInfo:  def.onboardingInfo(),
+			ModelInfoConfig: def.Model,
+			WorkflowIDList:  def.workflowIDList(),
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("创建Bot失败: %w", err)
+	}
+
+	return resp.BotID, nil
+}
+
+// UpdateBot 更新Bot的草稿定义，发布后生效
+func (bot *Client) UpdateBot(botID string, def *BotDefinition) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	err := bot.call(ctx, "bots.update", true, func() error {
+		_, err := bot.Api.Bots.Update(ctx, &coze.UpdateBotsReq{
+			BotID:           botID,
+			Name:            def.Name,
+			Description:     def.Description,
+			PromptInfo:      &coze.BotPromptInfo{Prompt: def.Prompt},
+			OnboardingInfo:  def.onboardingInfo(),
+			Knowledge:       def.Knowledge,
+			ModelInfoConfig: def.Model,
+			WorkflowIDList:  def.workflowIDList(),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("更新Bot失败: %w", err)
+	}
+
+	return nil
+}
+
+// PublishBot 将Bot发布到API渠道，返回发布后的版本号
+func (bot *Client) PublishBot(botID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp *coze.PublishBotsResp
+	err := bot.call(ctx, "bots.publish", false, func() (err error) {
+		resp, err = bot.Api.Bots.Publish(ctx, &coze.PublishBotsReq{
+			BotID:        botID,
+			ConnectorIDs: []string{botAPIConnectorID},
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("发布Bot失败: %w", err)
+	}
+
+	return resp.BotVersion, nil
+}
+
+// RetrieveBot 获取Bot已发布的定义
+func (bot *Client) RetrieveBot(botID string) (*coze.Bot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp *coze.RetrieveBotsResp
+	err := bot.call(ctx, "bots.retrieve", true, func() (err error) {
+		resp, err = bot.Api.Bots.Retrieve(ctx, &coze.RetrieveBotsReq{BotID: botID})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取Bot失败: %w", err)
+	}
+
+	return &resp.Bot, nil
+}
@@ -3,6 +3,7 @@ package coze
 import (
 	"context"
 	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
 	"errors"
 	"fmt"
 	"io"
@@ -12,16 +13,38 @@ import (
 	"github.com/coze-dev/coze-go"
 )
 
-func (conversation *Client) CreateConversation() (string, error) {
-	botID := conversation.Config.BotID
-	ctx := context.Background()
-	metaData := map[string]string{
-		"user_id":   "157",
-		"user_phone": "13207171044",
+// 导入对话时写入Coze的历史消息上限，更早的消息只保存在本地
+const maxSeedMessages = 50
+
+// CreateConversation 在指定Bot下创建对话，botID为空时使用全局配置的Bot
+func (conversation *Client) CreateConversation(botID string, metaData map[string]string) (string, error) {
+	return conversation.createConversation(botID, metaData, nil)
+}
+
+// CreateConversationWithHistory 创建对话并写入历史消息，只写入最近的问题和回答，系统消息不写入
+func (conversation *Client) CreateConversationWithHistory(botID string, metaData map[string]string, messages []*models.Message) (string, error) {
+	history := make([]*models.Message, 0, len(messages))
+	for _, message := range messages {
+		if message.Role == "user" || message.Role == "assistant" {
+			history = append(history, message)
+		}
+	}
+	if len(history) > maxSeedMessages {
+		history = history[len(history)-maxSeedMessages:]
 	}
-	resp, err := conversation.Api.Conversations.Create(ctx, &coze.CreateConversationsReq{BotID: botID, MetaData: metaData})
+	return conversation.createConversation(botID, metaData, buildCozeMessages(history))
+}
+
+func (conversation *Client) createConversation(botID string, metaData map[string]string, messages []*coze.Message) (string, error) {
+	botID = conversation.resolveBotID(botID)
+	ctx := context.Background()
+	var resp *coze.CreateConversationsResp
+	err := conversation.call(ctx, "conversations.create", false, func() (err error) {
+		resp, err = conversation.Api.Conversations.Create(ctx, &coze.CreateConversationsReq{BotID: botID, MetaData: metaData, Messages: messages})
+		return err
+	})
 	if err != nil {
-		return "", fmt.Errorf("创建对话失败: %v", err)
+		return "", fmt.Errorf("创建对话失败: %w", err)
 	}
 
 	return resp.Conversation.ID, nil
@@ -88,93 +111,349 @@ func (conversation *Client) SendMessageStream(conversationID string, message str
 	return nil, nil
 }
 
-// SendMessageStreamWithCallback 发送流式消息并通过回调函数处理事件
-func (conversation *Client) SendMessageStreamWithCallback(conversationID string, userID uint, messageList []*models.Message, onMessage func(eventType string, data interface{})) error {
+// Chat 向指定Bot发起非流式对话并等待回复完成
+func (conversation *Client) Chat(chatReq *providers.ChatRequest) (*coze.ChatPoll, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
 	defer cancel()
-	cozeMessageList := make([]*coze.Message, 0, len(messageList))
-	for _, message := range messageList {
-		var messageType coze.MessageType
-		if message.Role == "user" {
-			messageType = coze.MessageTypeQuestion
-		} else if message.Role == "assistant" {
-			messageType = coze.MessageTypeAnswer
+
+	var resp *coze.ChatPoll
+	err := conversation.call(ctx, "chat.create", false, func() (err error) {
+		resp, err = conversation.Api.Chat.CreateAndPoll(ctx, conversation.buildChatRequest(chatReq), nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建对话失败: %w", err)
+	}
+
+	return resp, nil
+}
+
+// SendMessageStreamWithCallback 发送流式消息并通过回调函数处理事件
+// ctx取消时停止读取流并返回ctx的错误
+func (conversation *Client) SendMessageStreamWithCallback(ctx context.Context, chatReq *providers.ChatRequest, onEvent providers.EventHandler) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*2)
+	defer cancel()
+
+	var resp coze.Stream[coze.ChatEvent]
+	err := conversation.call(ctx, "chat.stream", true, func() (err error) {
+		resp, err = conversation.Api.Chat.Stream(ctx, conversation.buildChatRequest(chatReq))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("创建流式对话失败: %w", err)
+	}
+
+	return conversation.handleChatStream(ctx, resp, onEvent)
+}
+
+// SubmitToolOutputsStream 提交客户端执行的工具结果，并继续原对话的流式响应
+func (conversation *Client) SubmitToolOutputsStream(ctx context.Context, conversationID string, chatID string, outputs []*coze.ToolOutput, onEvent providers.EventHandler) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*2)
+	defer cancel()
+
+	// 先取走待提交的工具调用，避免重复提交
+	pending, err := claimPendingToolCall(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if pending.ConversationID != conversationID {
+		restorePendingToolCall(ctx, pending)
+		return errors.New("工具调用不属于该对话")
+	}
+
+	submitted := make(map[string]bool, len(outputs))
+	for _, output := range outputs {
+		submitted[output.ToolCallID] = true
+	}
+	for _, toolCallID := range pending.ToolCallIDs {
+		if !submitted[toolCallID] {
+			restorePendingToolCall(ctx, pending)
+			return fmt.Errorf("缺少工具调用 %s 的结果", toolCallID)
 		}
-		cozeMessageList = append(cozeMessageList, &coze.Message{
-			Role:    coze.MessageRole(message.Role),
-			Content: message.Content,
-			Type:    messageType,
+	}
+
+	var resp coze.Stream[coze.ChatEvent]
+	err = conversation.call(ctx, "chat.submit_tool_outputs", true, func() (err error) {
+		resp, err = conversation.Api.Chat.StreamSubmitToolOutputs(ctx, &coze.SubmitToolOutputsChatReq{
+			ConversationID: conversationID,
+			ChatID:         chatID,
+			ToolOutputs:    append(pending.Outputs, outputs...),
 		})
+		return err
+	})
+	if err != nil {
+		restorePendingToolCall(ctx, pending)
+		return fmt.Errorf("提交工具结果失败: %w", err)
 	}
-	req := &coze.CreateChatsReq{
-		BotID:  conversation.Config.BotID,
-		ConversationID: conversationID,
-		UserID: strconv.FormatUint(uint64(userID), 10),
-		Messages: cozeMessageList,
+
+	return conversation.handleChatStream(ctx, resp, onEvent)
+}
+
+// ListConversationMessages 按时间正序获取对话中的全部消息
+func (conversation *Client) ListConversationMessages(conversationID string) ([]*coze.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	order := "asc"
+	var paged coze.LastIDPaged[coze.Message]
+	err := conversation.call(ctx, "conversations.messages.list", true, func() (err error) {
+		paged, err = conversation.Api.Conversations.Messages.List(ctx, &coze.ListConversationsMessagesReq{
+			ConversationID: conversationID,
+			Order:          &order,
+			Limit:          50,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取对话消息失败: %w", err)
 	}
 
-	resp, err := conversation.Api.Chat.Stream(ctx, req)
+	messages := make([]*coze.Message, 0)
+	for paged.Next() {
+		messages = append(messages, paged.Current())
+	}
+	if err := paged.Err(); err != nil {
+		return nil, fmt.Errorf("获取对话消息失败: %w", classifyError(err))
+	}
+	return messages, nil
+}
+
+// CancelChat 取消进行中的对话，停止生成回复
+func (conversation *Client) CancelChat(conversationID string, chatID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	err := conversation.call(ctx, "chat.cancel", true, func() error {
+		_, err := conversation.Api.Chat.Cancel(ctx, &coze.CancelChatsReq{
+			ConversationID: conversationID,
+			ChatID:         chatID,
+		})
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("创建流式对话失败: %v", err)
+		return fmt.Errorf("取消对话失败: %w", err)
 	}
-	defer resp.Close()
+	return nil
+}
+
+// handleChatStream 读取流式对话事件并通过回调函数处理，遇到本地工具调用时自动执行并继续读取新的流
+func (conversation *Client) handleChatStream(ctx context.Context, resp coze.Stream[coze.ChatEvent], onEvent providers.EventHandler) error {
+	defer func() {
+		resp.Close()
+	}()
 
 	for {
 		event, err := resp.Recv()
 		if errors.Is(err, io.EOF) {
 			// 流式对话结束
-			onMessage("conversation_end", map[string]string{
-				"status": "completed",
-				"log_id": resp.Response().LogID(),
-			})
+			onEvent(providers.Event{Type: providers.EventConversationEnd, Data: providers.ConversationEnd{
+				Status: "completed",
+				LogID:  resp.Response().LogID(),
+			}})
 			break
 		}
 
 		if err != nil {
-			return fmt.Errorf("流式对话失败: %v", err)
+			if ctx.Err() != nil {
+				// 对话被取消
+				return ctx.Err()
+			}
+			return fmt.Errorf("流式对话失败: %w", classifyError(err))
 		}
 
 		// 根据不同的事件类型调用回调函数
 		switch event.Event {
+		case coze.ChatEventConversationChatCreated:
+			// 对话创建，客户端可据此取消对话
+			onEvent(providers.Event{Type: providers.EventChatCreated, Data: providers.ChatCreated{
+				ChatID:         event.Chat.ID,
+				ConversationID: event.Chat.ConversationID,
+			}})
 		case coze.ChatEventConversationMessageDelta:
 			// 消息增量更新
 			if event.Message != nil {
-				onMessage("message_delta", map[string]interface{}{
-					"content": event.Message.Content,
-					"role":    event.Message.Role,
-					"type":    event.Message.Type,
-				})
+				onEvent(providers.Event{Type: providers.EventMessageDelta, Data: providers.MessageDelta{
+					MessageID: event.Message.ID,
+					Content:   event.Message.Content,
+					Role:      string(event.Message.Role),
+					Type:      string(event.Message.Type),
+				}})
 			}
 		case coze.ChatEventConversationChatCompleted:
 			// 对话完成
-			onMessage("chat_completed", map[string]interface{}{
-				"usage": map[string]interface{}{
-					"token_count":  event.Chat.Usage.TokenCount,
-					"output_count": event.Chat.Usage.OutputCount,
-					"input_count":  event.Chat.Usage.InputCount,
-				},
-				"chat_id": event.Chat.ID,
-			})
+			onEvent(providers.Event{Type: providers.EventChatCompleted, Data: providers.ChatCompleted{
+				ChatID: event.Chat.ID,
+				Usage:  toUsage(event.Chat.Usage),
+			}})
 		case coze.ChatEventConversationChatFailed:
 			// 对话失败
-			onMessage("chat_failed", map[string]interface{}{
-				"error_code": event.Chat.LastError.Code,
-				"error_msg":  event.Chat.LastError.Msg,
-			})
+			onEvent(providers.Event{Type: providers.EventChatFailed, Data: providers.ChatFailed{
+				ErrorCode: event.Chat.LastError.Code,
+				ErrorMsg:  event.Chat.LastError.Msg,
+			}})
 		case coze.ChatEventConversationChatRequiresAction:
-			// 需要用户操作
-			onMessage("requires_action", map[string]interface{}{
-				"action": event.Chat.RequiredAction,
-			})
+			// 需要执行工具
+			next, err := conversation.handleRequiresAction(ctx, event.Chat, onEvent)
+			if err != nil {
+				return err
+			}
+			if next == nil {
+				// 存在需要客户端执行的工具，等待客户端提交结果
+				onEvent(providers.Event{Type: providers.EventConversationEnd, Data: providers.ConversationEnd{
+					Status: "requires_action",
+					LogID:  resp.Response().LogID(),
+				}})
+				return nil
+			}
+			resp.Close()
+			resp = next
 		default:
 			// 其他事件
-			onMessage("other_event", map[string]interface{}{
+			onEvent(providers.Event{Type: providers.EventOther, Data: map[string]interface{}{
 				"event":    string(event.Event),
 				"raw_data": event,
-			})
+			}})
 		}
 	}
 
 	return nil
 }
 
+// handleRequiresAction 执行已注册的本地工具，全部为本地工具时提交结果并返回继续对话的流；
+// 存在客户端工具时保存本地结果并通知客户端，返回nil
+func (conversation *Client) handleRequiresAction(ctx context.Context, chat *coze.Chat, onEvent providers.EventHandler) (coze.Stream[coze.ChatEvent], error) {
+	if chat.RequiredAction == nil || chat.RequiredAction.SubmitToolOutputs == nil {
+		return nil, errors.New("缺少工具调用信息")
+	}
+
+	outputs := make([]*coze.ToolOutput, 0)
+	clientCalls := make([]*coze.ChatToolCall, 0)
+	for _, call := range chat.RequiredAction.SubmitToolOutputs.ToolCalls {
+		tool, ok := GetTool(call.Function.Name)
+		if !ok || tool.ClientSide {
+			clientCalls = append(clientCalls, call)
+			continue
+		}
+
+		output := executeTool(ctx, tool, call)
+		outputs = append(outputs, &coze.ToolOutput{
+			ToolCallID: call.ID,
+			Output:     output,
+		})
+		onEvent(providers.Event{Type: providers.EventToolOutput, Data: map[string]interface{}{
+			"tool_call_id": call.ID,
+			"name":         call.Function.Name,
+			"output":       output,
+		}})
+	}
+
+	if len(clientCalls) > 0 {
+		pending := &PendingToolCall{
+			ConversationID: chat.ConversationID,
+			ChatID:         chat.ID,
+			Outputs:        outputs,
+		}
+		for _, call := range clientCalls {
+			pending.ToolCallIDs = append(pending.ToolCallIDs, call.ID)
+		}
+		if err := savePendingToolCall(ctx, pending); err != nil {
+			return nil, fmt.Errorf("保存工具调用失败: %v", err)
+		}
+
+		onEvent(providers.Event{Type: providers.EventRequiresAction, Data: map[string]interface{}{
+			"chat_id":         chat.ID,
+			"conversation_id": chat.ConversationID,
+			"tool_calls":      clientCalls,
+		}})
+		return nil, nil
+	}
+
+	var next coze.Stream[coze.ChatEvent]
+	err := conversation.call(ctx, "chat.submit_tool_outputs", true, func() (err error) {
+		next, err = conversation.Api.Chat.StreamSubmitToolOutputs(ctx, &coze.SubmitToolOutputsChatReq{
+			ConversationID: chat.ConversationID,
+			ChatID:         chat.ID,
+			ToolOutputs:    outputs,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("提交工具结果失败: %w", err)
+	}
+	return next, nil
+}
+
+// toUsage 将Coze的token用量转换为通用格式
+func toUsage(usage *coze.ChatUsage) providers.Usage {
+	if usage == nil {
+		return providers.Usage{}
+	}
+	return providers.Usage{
+		TokenCount:  usage.TokenCount,
+		OutputCount: usage.OutputCount,
+		InputCount:  usage.InputCount,
+	}
+}
+
+// buildChatRequest 将对话请求转换为Coze的请求，附带自定义变量和元数据
+func (conversation *Client) buildChatRequest(chatReq *providers.ChatRequest) *coze.CreateChatsReq {
+	return &coze.CreateChatsReq{
+		BotID:           conversation.resolveBotID(chatReq.BotID),
+		ConversationID:  chatReq.ConversationID,
+		UserID:          strconv.FormatUint(uint64(chatReq.UserID), 10),
+		Messages:        buildCozeMessages(chatReq.Messages),
+		CustomVariables: chatReq.CustomVariables,
+		MetaData:        chatReq.MetaData,
+	}
+}
+
+// buildCozeMessages 将本地消息转换为Coze消息，包含图片或文件的用户消息转换为object_string消息
+func buildCozeMessages(messageList []*models.Message) []*coze.Message {
+	cozeMessageList := make([]*coze.Message, 0, len(messageList))
+	for _, message := range messageList {
+		// Coze只接受问题和回答，系统消息等其他角色不发送
+		if message.Role != "user" && message.Role != "assistant" {
+			continue
+		}
+		if message.Role == "user" && message.Parts.HasAttachment() {
+			cozeMessageList = append(cozeMessageList, coze.BuildUserQuestionObjects(buildMessageObjects(message.Parts), nil))
+			continue
+		}
+
+		messageType := coze.MessageTypeAnswer
+		if message.Role == "user" {
+			messageType = coze.MessageTypeQuestion
+		}
+		cozeMessageList = append(cozeMessageList, &coze.Message{
+			Role:        coze.MessageRole(message.Role),
+			Content:     message.Content,
+			ContentType: coze.MessageContentTypeText,
+			Type:        messageType,
+		})
+	}
+	return cozeMessageList
+}
+
+// buildMessageObjects 将消息内容片段转换为Coze多模态消息对象
+func buildMessageObjects(parts models.MessageParts) []*coze.MessageObjectString {
+	objects := make([]*coze.MessageObjectString, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case models.MessagePartText:
+			objects = append(objects, coze.NewTextMessageObject(part.Text))
+		case models.MessagePartImage:
+			if part.FileID != "" {
+				objects = append(objects, coze.NewImageMessageObjectByID(part.FileID))
+			} else {
+				objects = append(objects, coze.NewImageMessageObjectByURL(part.FileURL))
+			}
+		case models.MessagePartFile:
+			if part.FileID != "" {
+				objects = append(objects, coze.NewFileMessageObjectByID(part.FileID))
+			} else {
+				objects = append(objects, coze.NewFileMessageObjectByURL(part.FileURL))
+			}
+		}
+	}
+	return objects
+}
@@ -5,97 +5,166 @@ import (
 	"coze-agent-platform/config"
 	"coze-agent-platform/utils"
 	"fmt"
-	"os"
-	"time"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/coze-dev/coze-go"
 )
 
 const (
-	COZE_TOKEN_KEY       = "coze:access_token"
-	TOKEN_EXPIRE_MINUTES = 14
+	COZE_TOKEN_KEY = "coze:access_token"
 )
 
 type Client struct {
 	Config *config.CozeConfig
 	Api    *coze.CozeAPI
+
+	auth        *TokenProvider
+	http        *http.Client // SDK未封装的接口使用，401时自动刷新令牌重试
+	workspaceId uint         // 为0时使用全局配置
 }
 
+// 工作空间客户端的缓存时间，过期后重新加载配置，配置未变化时继续使用原客户端
+const workspaceClientTTL = time.Minute
+
+// WorkspaceConfigLoader 加载工作空间的Coze配置
+type WorkspaceConfigLoader func(workspaceId uint) (*config.CozeConfig, error)
+
+// workspaceClient 缓存的工作空间客户端
+type workspaceClient struct {
+	client   *Client
+	loadedAt time.Time
+}
+
+var (
+	defaultClientMu sync.Mutex
+	defaultClient   *Client
+
+	workspaceMu      sync.Mutex
+	workspaceClients = make(map[uint]*workspaceClient)
+	workspaceLoader  WorkspaceConfigLoader
+)
+
+// New 返回进程内共享的Coze客户端，令牌由 TokenProvider 按需刷新
 func New() (*Client, error) {
-	cozeConv := &Client{
-		Config: config.GetCozeConfig(),
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	if defaultClient != nil {
+		return defaultClient, nil
 	}
-	token, err := GetToken()
+
+	cozeConfig := config.GetCozeConfig()
+	provider, err := NewTokenProvider(COZE_TOKEN_KEY, cozeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("获取Coze Token失败: %v", err)
 	}
-	httpClient := &http.Client{
-		Timeout: 120 * time.Second,
-	}
 
-	cozeApi := coze.NewCozeAPI(coze.NewTokenAuth(token), coze.WithBaseURL(cozeConv.Config.APIURL), coze.WithHttpClient(httpClient))
-	cozeConv.Api = &cozeApi
-	return cozeConv, nil
+	defaultClient = newClient(0, cozeConfig, provider)
+	return defaultClient, nil
 }
 
+// SetWorkspaceConfigLoader 设置工作空间Coze配置的加载函数
+func SetWorkspaceConfigLoader(loader WorkspaceConfigLoader) {
+	workspaceMu.Lock()
+	defer workspaceMu.Unlock()
+	workspaceLoader = loader
+}
 
-func GetToken() (string, error) {
-	ctx := context.Background()
-
-	// 首先尝试从Redis获取缓存的token
-	if utils.RDB != nil {
-		cachedToken, err := utils.RDB.Get(ctx, COZE_TOKEN_KEY).Result()
-		if err == nil && cachedToken != "" {
-			fmt.Println("使用缓存的token")
-			return cachedToken, nil
-		}
+// ForWorkspace 返回工作空间的Coze客户端，workspaceId 为0时返回使用全局配置的客户端。
+// 各工作空间的令牌分别缓存在Redis的 coze:access_token:workspace:<id> 中
+func ForWorkspace(workspaceId uint) (*Client, error) {
+	if workspaceId == 0 {
+		return New()
 	}
 
-	// Redis中没有token或者Redis不可用，从API获取新token
-	fmt.Println("从API获取新token")
+	workspaceMu.Lock()
+	defer workspaceMu.Unlock()
 
-	cozeConfig := config.GetCozeConfig()
-	// 优先使用配置文件中的私钥字符串，如果为空则尝试读取文件
-	var jwtOauthPrivateKey string
-	if cozeConfig.PrivateKey != "" {
-		jwtOauthPrivateKey = cozeConfig.PrivateKey
-	} else if cozeConfig.PrivateKeyFilePath != "" {
-		privateKeyBytes, err := os.ReadFile(cozeConfig.PrivateKeyFilePath)
-		if err != nil {
-			return "", fmt.Errorf("读取私钥文件失败: %v", err)
-		}
-		jwtOauthPrivateKey = string(privateKeyBytes)
-	} else {
-		return "", fmt.Errorf("未配置私钥")
+	cached, ok := workspaceClients[workspaceId]
+	if ok && time.Since(cached.loadedAt) < workspaceClientTTL {
+		return cached.client, nil
 	}
 
-	oauth, err := coze.NewJWTOAuthClient(coze.NewJWTOAuthClientParam{
-		PrivateKeyPEM: jwtOauthPrivateKey,
-		ClientID:      cozeConfig.ClientID,
-		PublicKey:     cozeConfig.PublicKeyID,
-	}, coze.WithAuthBaseURL(cozeConfig.APIURL))
-
+	if workspaceLoader == nil {
+		return nil, fmt.Errorf("未设置工作空间配置加载函数")
+	}
+	cozeConfig, err := workspaceLoader(workspaceId)
 	if err != nil {
-		return "", fmt.Errorf("创建JWT OAuth客户端失败: %v", err)
+		return nil, err
 	}
 
-	resp, err := oauth.GetAccessToken(ctx, nil)
-	fmt.Println("resp", resp)
+	// 配置未变化时保留原客户端及其令牌
+	if ok && *cached.client.Config == *cozeConfig {
+		cached.loadedAt = time.Now()
+		return cached.client, nil
+	}
+
+	provider, err := NewTokenProvider(workspaceTokenKey(workspaceId), cozeConfig)
 	if err != nil {
-		return "", fmt.Errorf("获取AccessToken失败: %v", err)
+		return nil, fmt.Errorf("获取工作空间Coze Token失败: %v", err)
+	}
+	if ok {
+		// 凭证已变更，原应用签发的令牌不再使用
+		provider.clearCached(context.Background())
 	}
 
-	// 将新token存储到Redis，设置14分钟过期时间
+	client := newClient(workspaceId, cozeConfig, provider)
+	workspaceClients[workspaceId] = &workspaceClient{
+		client:   client,
+		loadedAt: time.Now(),
+	}
+	return client, nil
+}
+
+// InvalidateWorkspace 工作空间凭证变更或删除后清除本实例缓存的客户端及Redis中的令牌
+func InvalidateWorkspace(workspaceId uint) {
+	workspaceMu.Lock()
+	defer workspaceMu.Unlock()
+
+	delete(workspaceClients, workspaceId)
 	if utils.RDB != nil {
-		expiration := time.Duration(TOKEN_EXPIRE_MINUTES) * time.Minute
-		err = utils.RDB.Set(ctx, COZE_TOKEN_KEY, resp.AccessToken, expiration).Err()
-		if err != nil {
-			fmt.Printf("警告: 无法将token存储到Redis: %v\n", err)
-		} else {
-			fmt.Printf("token已存储到Redis，有效期: %d分钟\n", TOKEN_EXPIRE_MINUTES)
-		}
+		utils.RDB.Del(context.Background(), workspaceTokenKey(workspaceId))
+	}
+}
+
+func workspaceTokenKey(workspaceId uint) string {
+	return fmt.Sprintf("%s:workspace:%d", COZE_TOKEN_KEY, workspaceId)
+}
+
+func newClient(workspaceId uint, cozeConfig *config.CozeConfig, provider *TokenProvider) *Client {
+	httpClient := &http.Client{
+		Timeout: 120 * time.Second,
+		Transport: &authRetryTransport{
+			base:     http.DefaultTransport,
+			provider: provider,
+		},
 	}
 
-	return resp.AccessToken, nil
+	cozeApi := coze.NewCozeAPI(provider, coze.WithBaseURL(cozeConfig.APIURL), coze.WithHttpClient(httpClient))
+	return &Client{
+		Config:      cozeConfig,
+		Api:         &cozeApi,
+		auth:        provider,
+		http:        httpClient,
+		workspaceId: workspaceId,
+	}
+}
+
+// resolveBotID 返回实际使用的BotID，未指定时回退到全局配置
+func (client *Client) resolveBotID(botID string) string {
+	if botID != "" {
+		return botID
+	}
+	return client.Config.BotID
+}
+
+// GetToken 获取当前有效的Coze访问令牌，workspaceId 为0时使用全局配置
+func GetToken(workspaceId uint) (string, error) {
+	client, err := ForWorkspace(workspaceId)
+	if err != nil {
+		return "", err
+	}
+	return client.auth.Token(context.Background())
 }
@@ -0,0 +1,116 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"coze-agent-platform/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Coze消息评价类型
+const (
+	FeedbackTypeLike   = "like"
+	FeedbackTypeUnlike = "unlike"
+)
+
+// MessageFeedback 消息评价
+type MessageFeedback struct {
+	FeedbackType string   `json:"feedback_type"`          // like、unlike
+	ReasonTypes  []string `json:"reason_types,omitempty"` // 评价原因标签
+	Comment      string   `json:"comment,omitempty"`
+}
+
+// SubmitMessageFeedback 提交或覆盖对话中某条回复的评价，SDK未封装该接口
+func (client *Client) SubmitMessageFeedback(conversationID, messageID string, feedback *MessageFeedback) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := client.call(ctx, "conversations.messages.feedback", true, func() error {
+		return client.doFeedback(ctx, http.MethodPost, conversationID, messageID, feedback)
+	})
+	if err != nil {
+		return fmt.Errorf("提交消息评价失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteMessageFeedback 删除对话中某条回复的评价
+func (client *Client) DeleteMessageFeedback(conversationID, messageID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := client.call(ctx, "conversations.messages.feedback", true, func() error {
+		return client.doFeedback(ctx, http.MethodDelete, conversationID, messageID, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("删除消息评价失败: %w", err)
+	}
+	return nil
+}
+
+func (client *Client) doFeedback(ctx context.Context, method, conversationID, messageID string, feedback *MessageFeedback) error {
+	token, err := client.auth.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if feedback != nil {
+		data, err := json.Marshal(feedback)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/conversations/%s/messages/%s/feedback",
+		strings.TrimRight(client.Config.APIURL, "/"), url.PathEscape(conversationID), url.PathEscape(messageID))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	parseErr := json.Unmarshal(data, &result)
+
+	upstreamErr := &utils.UpstreamError{
+		Code:    result.Code,
+		LogID:   resp.Header.Get("X-Tt-Logid"),
+		Message: result.Msg,
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		upstreamErr.Kind = utils.ErrorKindRateLimited
+	case resp.StatusCode >= http.StatusInternalServerError || parseErr != nil:
+		upstreamErr.Kind = utils.ErrorKindUnavailable
+		upstreamErr.Message = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	case result.Code != 0:
+		upstreamErr.Kind = cozeErrorKind(result.Code)
+	case resp.StatusCode >= http.StatusMultipleChoices:
+		upstreamErr.Kind = utils.ErrorKindBadRequest
+		upstreamErr.Message = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	default:
+		return nil
+	}
+	return upstreamErr
+}
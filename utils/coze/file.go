@@ -34,10 +34,15 @@ func (client *Client) Upload(file io.Reader) (string, error) {
 		File: tempFile,
 	}
 
-	uploadResp, err := client.Api.Files.Upload(context.Background(), uploadReq)
+	ctx := context.Background()
+	var uploadResp *coze.UploadFilesResp
+	err = client.call(ctx, "files.upload", false, func() (err error) {
+		uploadResp, err = client.Api.Files.Upload(ctx, uploadReq)
+		return err
+	})
 	tempFile.Close() // 关闭临时文件
 	if err != nil {
-		return "", fmt.Errorf("上传文件失败: %v", err)
+		return "", fmt.Errorf("上传文件失败: %w", err)
 	}
 
 	return uploadResp.FileInfo.ID, nil
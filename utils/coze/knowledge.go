@@ -1,6 +1,156 @@
 package coze
 
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// DefaultSpaceID 返回默认的Coze空间ID，未在请求中指定空间时使用
+func DefaultSpaceID() string {
+	return os.Getenv("COZE_SPACE_ID")
+}
+
+// ListDatasets 获取空间下的知识库列表
+func (knowledge *Client) ListDatasets(spaceID string, name string, page int, size int) ([]*coze.Dataset, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp coze.NumberPaged[coze.Dataset]
+	err := knowledge.call(ctx, "datasets.list", true, func() (err error) {
+		resp, err = knowledge.Api.Datasets.List(ctx, &coze.ListDatasetsReq{
+			SpaceID:  spaceID,
+			Name:     name,
+			PageNum:  page,
+			PageSize: size,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取知识库列表失败: %w", err)
+	}
+
+	return resp.Items(), resp.Total(), nil
+}
+
+// CreateDataset 创建知识库，返回知识库ID
+func (knowledge *Client) CreateDataset(spaceID string, name string, description string, formatType coze.DocumentFormatType) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp *coze.CreateDatasetResp
+	err := knowledge.call(ctx, "datasets.create", false, func() (err error) {
+		resp, err = knowledge.Api.Datasets.Create(ctx, &coze.CreateDatasetsReq{
+			Name:        name,
+			SpaceID:     spaceID,
+			FormatType:  formatType,
+			Description: description,
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("创建知识库失败: %w", err)
+	}
+
+	return resp.DatasetID, nil
+}
+
+// CreateDocumentFromFile 将本地文件内容上传到知识库
+func (knowledge *Client) CreateDocumentFromFile(datasetID string, name string, content []byte, fileType string) ([]*coze.Document, error) {
+	return knowledge.createDocuments(datasetID, coze.DocumentBaseBuildLocalFile(name, string(content), fileType))
+}
+
+// CreateDocumentFromURL 将网页内容上传到知识库
+func (knowledge *Client) CreateDocumentFromURL(datasetID string, name string, url string) ([]*coze.Document, error) {
+	return knowledge.createDocuments(datasetID, coze.DocumentBaseBuildWebPage(name, url, nil))
+}
+
+// CreateDocumentFromFileID 将通过文件上传接口上传的文件添加到知识库
+func (knowledge *Client) CreateDocumentFromFileID(datasetID string, name string, fileID string) ([]*coze.Document, error) {
+	id, err := strconv.ParseInt(fileID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("文件ID格式错误: %v", err)
+	}
+	documentSource := 5
+	return knowledge.createDocuments(datasetID, &coze.DocumentBase{
+		Name: name,
+		SourceInfo: &coze.DocumentSourceInfo{
+			SourceFileID:   &id,
+			DocumentSource: &documentSource,
+		},
+	})
+}
+
+func (knowledge *Client) createDocuments(datasetID string, documents ...*coze.DocumentBase) ([]*coze.Document, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+
+	id, err := strconv.ParseInt(datasetID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("知识库ID格式错误: %v", err)
+	}
+
+	var resp *coze.CreateDatasetsDocumentsResp
+	err = knowledge.call(ctx, "datasets.documents.create", false, func() (err error) {
+		resp, err = knowledge.Api.Datasets.Documents.Create(ctx, &coze.CreateDatasetsDocumentsReq{
+			DatasetID:     id,
+			DocumentBases: documents,
+			ChunkStrategy: &coze.DocumentChunkStrategy{ChunkType: 0},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("上传知识库文档失败: %w", err)
+	}
+
+	return resp.DocumentInfos, nil
+}
+
+// GetDocumentProgress 查询知识库文档处理进度
+func (knowledge *Client) GetDocumentProgress(datasetID string, documentIDs []string) ([]*coze.DocumentProgress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp *coze.ProcessDocumentsResp
+	err := knowledge.call(ctx, "datasets.process", true, func() (err error) {
+		resp, err = knowledge.Api.Datasets.Process(ctx, &coze.ProcessDocumentsReq{
+			DatasetID:   datasetID,
+			DocumentIDs: documentIDs,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询文档处理进度失败: %w", err)
+	}
+
+	return resp.Data, nil
+}
+
+// DeleteDocuments 删除知识库文档
+func (knowledge *Client) DeleteDocuments(documentIDs []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	ids := make([]int64, 0, len(documentIDs))
+	for _, documentID := range documentIDs {
+		id, err := strconv.ParseInt(documentID, 10, 64)
+		if err != nil {
+			return fmt.Errorf("文档ID格式错误: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	err := knowledge.call(ctx, "datasets.documents.delete", true, func() error {
+		_, err := knowledge.Api.Datasets.Documents.Delete(ctx, &coze.DeleteDatasetsDocumentsReq{DocumentIDs: ids})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("删除知识库文档失败: %w", err)
+	}
 
-func (knowledge *Client) UpdateKnowledge(knowledgeID string,fileId string) error {
 	return nil
 }
@@ -0,0 +1,169 @@
+package coze
+
+import (
+	"context"
+	"coze-agent-platform/models"
+	"coze-agent-platform/providers"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// Provider 基于Coze的对话后端，workspaceId 为0时使用全局配置的凭证
+type Provider struct {
+	workspaceId uint
+}
+
+var (
+	_ providers.ChatProvider    = (*Provider)(nil)
+	_ providers.WorkspaceScoped = (*Provider)(nil)
+	_ providers.HistorySeeder   = (*Provider)(nil)
+)
+
+func NewProvider() providers.ChatProvider {
+	return &Provider{}
+}
+
+func (p *Provider) Name() string {
+	return providers.ProviderCoze
+}
+
+func (p *Provider) ForWorkspace(workspaceId uint) providers.ChatProvider {
+	return &Provider{workspaceId: workspaceId}
+}
+
+func (p *Provider) CreateConversation(botID string, metaData map[string]string) (string, error) {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return "", err
+	}
+	return client.CreateConversation(botID, metaData)
+}
+
+func (p *Provider) CreateConversationWithHistory(botID string, metaData map[string]string, messages []*models.Message) (string, error) {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return "", err
+	}
+	return client.CreateConversationWithHistory(botID, metaData, messages)
+}
+
+func (p *Provider) Chat(req *providers.ChatRequest) (*providers.ChatResult, error) {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 对话流仅支持流式接口，收集流式事件作为结果
+	if req.WorkflowID != "" {
+		return collectChatflow(client, req)
+	}
+
+	resp, err := client.Chat(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &providers.ChatResult{
+		ChatID:         resp.Chat.ID,
+		ConversationID: resp.Chat.ConversationID,
+		Usage:          toUsage(resp.Chat.Usage),
+	}
+	for _, message := range resp.Messages {
+		if message.Type == coze.MessageTypeAnswer {
+			result.MessageID = message.ID
+			result.Content = message.Content
+			break
+		}
+	}
+	return result, nil
+}
+
+func (p *Provider) ChatStream(ctx context.Context, req *providers.ChatRequest, onEvent providers.EventHandler) error {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return err
+	}
+	if req.WorkflowID != "" {
+		return client.ChatflowStream(ctx, req, onEvent)
+	}
+	return client.SendMessageStreamWithCallback(ctx, req, onEvent)
+}
+
+// collectChatflow 运行对话流并将流式事件合并为非流式结果
+func collectChatflow(client *Client, req *providers.ChatRequest) (*providers.ChatResult, error) {
+	result := &providers.ChatResult{ConversationID: req.ConversationID}
+	var content strings.Builder
+	var failed *providers.ChatFailed
+
+	err := client.ChatflowStream(context.Background(), req, func(event providers.Event) {
+		switch data := event.Data.(type) {
+		case providers.ChatCreated:
+			result.ChatID = data.ChatID
+		case providers.MessageDelta:
+			content.WriteString(data.Content)
+			if data.MessageID != "" {
+				result.MessageID = data.MessageID
+			}
+		case providers.ChatCompleted:
+			result.Usage = data.Usage
+		case providers.ChatFailed:
+			failed = &data
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed != nil {
+		return nil, fmt.Errorf("对话流运行失败: %s", failed.ErrorMsg)
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+func (p *Provider) CancelChat(conversationID string, chatID string) error {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return err
+	}
+	return client.CancelChat(conversationID, chatID)
+}
+
+func (p *Provider) RunWorkflow(req *providers.WorkflowRequest) (*providers.WorkflowResult, error) {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.RunWorkflow(req.WorkflowID, req.Parameters, req.IsAsync)
+	if err != nil {
+		return nil, err
+	}
+
+	return &providers.WorkflowResult{
+		ExecuteID:  resp.ExecuteID,
+		Output:     resp.Data,
+		TokenCount: resp.Token,
+		LogID:      resp.LogID(),
+		DebugURL:   resp.DebugURL,
+	}, nil
+}
+
+func (p *Provider) RunWorkflowStream(req *providers.WorkflowRequest, onEvent providers.EventHandler) error {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return err
+	}
+	return client.RunWorkflowStream(req.WorkflowID, req.Parameters, onEvent)
+}
+
+func (p *Provider) UploadFile(file io.Reader) (string, error) {
+	client, err := ForWorkspace(p.workspaceId)
+	if err != nil {
+		return "", err
+	}
+	return client.Upload(file)
+}
@@ -0,0 +1,204 @@
+package coze
+
+import (
+	"context"
+	"coze-agent-platform/utils"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+// Coze业务错误码
+const (
+	cozeCodeRateLimited      = 4013
+	cozeCodeBalance          = 4019
+	cozeCodeRPMExceeded      = 4020
+	cozeCodeFreeQuota        = 4028
+	cozeCodeAuthInvalid      = 4100
+	cozeCodePermissionDenied = 4101
+	cozeCodeInternalError    = 5000
+	cozeCodeTokenInvalid     = 700012006
+)
+
+// 重试与熔断参数
+const (
+	retryAttempts    = 3
+	retryBaseDelay   = 200 * time.Millisecond
+	retryMaxDelay    = 2 * time.Second
+	breakerThreshold = 5                // 连续失败多少次后熔断
+	breakerCooldown  = 30 * time.Second // 熔断后多久允许试探请求
+)
+
+// circuitBreaker 单个工作空间下单个Coze接口的熔断器，仅限流和上游不可用计为失败。
+// 限流和配额按工作空间的应用计算，一个工作空间被限流不影响其他工作空间
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool // 熔断冷却后仅放行一个试探请求
+}
+
+// breakerKey 熔断器按工作空间和接口区分，工作空间为0时是全局配置
+type breakerKey struct {
+	workspaceId uint
+	endpoint    string
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[breakerKey]*circuitBreaker)
+)
+
+func getBreaker(workspaceId uint, endpoint string) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	key := breakerKey{workspaceId: workspaceId, endpoint: endpoint}
+	breaker, ok := breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{}
+		breakers[key] = breaker
+	}
+	return breaker
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	upstreamErr, ok := utils.AsUpstreamError(err)
+	if ok && upstreamErr.Retryable() {
+		b.failures++
+		if b.failures >= breakerThreshold {
+			b.openUntil = time.Now().Add(breakerCooldown)
+		}
+		return
+	}
+	if err == nil || ok {
+		// 接口有正常响应，说明服务可用
+		b.failures = 0
+	}
+}
+
+// call 经熔断器执行Coze调用并对错误分类。retry为true时对限流和上游不可用的错误按抖动退避重试，
+// 仅用于幂等调用或尚未开始读取的流式调用
+func (client *Client) call(ctx context.Context, endpoint string, retry bool, fn func() error) error {
+	breaker := getBreaker(client.workspaceId, endpoint)
+
+	attempts := 1
+	if retry {
+		attempts = retryAttempts
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(retryDelay(attempt)):
+			}
+		}
+
+		if !breaker.allow() {
+			return &utils.UpstreamError{
+				Kind:    utils.ErrorKindUnavailable,
+				Message: fmt.Sprintf("Coze接口 %s 暂时不可用，请稍后重试", endpoint),
+			}
+		}
+
+		err = classifyError(fn())
+		breaker.record(err)
+
+		upstreamErr, ok := utils.AsUpstreamError(err)
+		if err == nil || !ok || !upstreamErr.Retryable() {
+			return err
+		}
+	}
+	return err
+}
+
+// retryDelay 指数退避，在退避时间的一半到全部之间随机抖动
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// classifyError 将Coze SDK返回的错误转换为 utils.UpstreamError，调用方取消时原样返回
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	if _, ok := utils.AsUpstreamError(err); ok {
+		return err
+	}
+
+	if cozeErr, ok := coze.AsCozeError(err); ok {
+		return &utils.UpstreamError{
+			Kind:    cozeErrorKind(cozeErr.Code),
+			Code:    cozeErr.Code,
+			LogID:   cozeErr.LogID,
+			Message: cozeErr.Message,
+			Err:     err,
+		}
+	}
+
+	if authErr, ok := coze.AsAuthError(err); ok {
+		kind := utils.ErrorKindAuth
+		switch {
+		case authErr.HttpCode == http.StatusTooManyRequests || authErr.Code == coze.SlowDown:
+			kind = utils.ErrorKindRateLimited
+		case authErr.HttpCode >= http.StatusInternalServerError:
+			kind = utils.ErrorKindUnavailable
+		}
+		return &utils.UpstreamError{
+			Kind:    kind,
+			LogID:   authErr.LogID,
+			Message: authErr.ErrorMessage,
+			Err:     err,
+		}
+	}
+
+	// 网络异常、超时及无法解析的响应均视为上游不可用
+	return &utils.UpstreamError{
+		Kind: utils.ErrorKindUnavailable,
+		Err:  err,
+	}
+}
+
+func cozeErrorKind(code int) utils.ErrorKind {
+	switch {
+	case code == cozeCodeAuthInvalid || code == cozeCodePermissionDenied || code == cozeCodeTokenInvalid:
+		return utils.ErrorKindAuth
+	case code == cozeCodeRateLimited || code == cozeCodeRPMExceeded:
+		return utils.ErrorKindRateLimited
+	case code == cozeCodeBalance || code == cozeCodeFreeQuota:
+		return utils.ErrorKindQuota
+	case code >= cozeCodeInternalError && code < cozeCodeInternalError+1000:
+		return utils.ErrorKindUnavailable
+	default:
+		return utils.ErrorKindBadRequest
+	}
+}
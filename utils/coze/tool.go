@@ -0,0 +1,182 @@
+package coze
+
+import (
+	"context"
+	"coze-agent-platform/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coze-dev/coze-go"
+)
+
+const (
+	COZE_PENDING_TOOL_KEY       = "coze:pending_tool:"
+	PENDING_TOOL_EXPIRE_MINUTES = 10
+)
+
+// ToolHandler 本地工具处理函数，arguments为Coze传入的JSON参数，返回值作为工具输出提交给Coze
+type ToolHandler func(ctx context.Context, arguments string) (string, error)
+
+// Tool 工具定义，Handler为空的工具需要由浏览器端执行并回传结果
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	Handler     ToolHandler     `json:"-"`
+	ClientSide  bool            `json:"client_side"`
+}
+
+// PendingToolCall 等待客户端提交结果的工具调用
+type PendingToolCall struct {
+	ConversationID string             `json:"conversation_id"`
+	ChatID         string             `json:"chat_id"`
+	Outputs        []*coze.ToolOutput `json:"outputs"`       // 已由服务端执行的工具结果
+	ToolCallIDs    []string           `json:"tool_call_ids"` // 等待客户端执行的工具调用
+}
+
+var (
+	toolsMu sync.RWMutex
+	tools   = make(map[string]*Tool)
+
+	// Redis不可用时的待提交工具调用存储
+	pendingMu    sync.Mutex
+	pendingTools = make(map[string]*PendingToolCall)
+)
+
+// RegisterTool 注册工具，同名工具会被覆盖
+func RegisterTool(tool Tool) {
+	tool.ClientSide = tool.Handler == nil
+
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+	tools[tool.Name] = &tool
+}
+
+// GetTool 根据名称获取已注册的工具
+func GetTool(name string) (*Tool, bool) {
+	toolsMu.RLock()
+	defer toolsMu.RUnlock()
+	tool, ok := tools[name]
+	return tool, ok
+}
+
+// ListTools 获取全部已注册的工具
+func ListTools() []*Tool {
+	toolsMu.RLock()
+	defer toolsMu.RUnlock()
+	list := make([]*Tool, 0, len(tools))
+	for _, tool := range tools {
+		list = append(list, tool)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// RegisterBuiltinTools 注册内置工具
+func RegisterBuiltinTools() {
+	RegisterTool(Tool{
+		Name:        "get_current_time",
+		Description: "获取服务器当前时间",
+		Schema:      json.RawMessage(`{"type":"object","properties":{"timezone":{"type":"string"}}}`),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Timezone string `json:"timezone"`
+			}
+			if arguments != "" {
+				if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+					return "", err
+				}
+			}
+			now := time.Now()
+			if args.Timezone != "" {
+				location, err := time.LoadLocation(args.Timezone)
+				if err != nil {
+					return "", fmt.Errorf("未知时区: %s", args.Timezone)
+				}
+				now = now.In(location)
+			}
+			return now.Format(time.RFC3339), nil
+		},
+	})
+}
+
+// executeTool 执行本地工具，执行失败时将错误信息作为工具输出返回给模型
+func executeTool(ctx context.Context, tool *Tool, call *coze.ChatToolCall) string {
+	var arguments interface{}
+	if call.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+			return toolErrorOutput(fmt.Errorf("工具参数解析失败: %v", err))
+		}
+	}
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+	if err := utils.ValidateJSONSchema(tool.Schema, arguments); err != nil {
+		return toolErrorOutput(err)
+	}
+
+	output, err := tool.Handler(ctx, call.Function.Arguments)
+	if err != nil {
+		return toolErrorOutput(err)
+	}
+	return output
+}
+
+func toolErrorOutput(err error) string {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(data)
+}
+
+// savePendingToolCall 保存等待客户端提交的工具调用
+func savePendingToolCall(ctx context.Context, pending *PendingToolCall) error {
+	if utils.RDB != nil {
+		data, err := json.Marshal(pending)
+		if err != nil {
+			return err
+		}
+		expiration := time.Duration(PENDING_TOOL_EXPIRE_MINUTES) * time.Minute
+		return utils.RDB.Set(ctx, COZE_PENDING_TOOL_KEY+pending.ChatID, data, expiration).Err()
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pendingTools[pending.ChatID] = pending
+	return nil
+}
+
+// claimPendingToolCall 读取并删除等待客户端提交的工具调用，并发提交时只有一个请求能取到。
+// 提交失败时由调用方通过savePendingToolCall放回，以便客户端重试
+func claimPendingToolCall(ctx context.Context, chatID string) (*PendingToolCall, error) {
+	if utils.RDB != nil {
+		data, err := utils.RDB.GetDel(ctx, COZE_PENDING_TOOL_KEY+chatID).Bytes()
+		if err != nil {
+			return nil, errors.New("没有等待提交的工具调用或已过期")
+		}
+		var pending PendingToolCall
+		if err := json.Unmarshal(data, &pending); err != nil {
+			return nil, err
+		}
+		return &pending, nil
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pending, ok := pendingTools[chatID]
+	if !ok {
+		return nil, errors.New("没有等待提交的工具调用或已过期")
+	}
+	delete(pendingTools, chatID)
+	return pending, nil
+}
+
+// restorePendingToolCall 提交失败时放回工具调用，放回失败只记录日志
+func restorePendingToolCall(ctx context.Context, pending *PendingToolCall) {
+	if err := savePendingToolCall(context.WithoutCancel(ctx), pending); err != nil {
+		log.Printf("放回待提交的工具调用失败 chat_id=%s: %v", pending.ChatID, err)
+	}
+}
@@ -0,0 +1,57 @@
+package coze
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook签名相关请求头
+const (
+	WebhookSignatureHeader = "X-Coze-Signature"
+	WebhookTimestampHeader = "X-Coze-Timestamp"
+)
+
+// 请求时间戳与服务器时间的最大偏差，超出视为重放请求
+const webhookTimestampTolerance = 5 * time.Minute
+
+// WebhookSecret 返回校验Webhook签名使用的密钥
+func WebhookSecret() string {
+	return os.Getenv("COZE_WEBHOOK_SECRET")
+}
+
+// VerifyWebhookSignature 校验Webhook请求签名。
+// 签名为 HMAC-SHA256(secret, timestamp + "." + body) 的十六进制编码，可带 "sha256=" 前缀
+func VerifyWebhookSignature(secret string, timestamp string, signature string, body []byte) error {
+	if timestamp == "" || signature == "" {
+		return errors.New("缺少签名或时间戳")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("时间戳格式错误")
+	}
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew > webhookTimestampTolerance || skew < -webhookTimestampTolerance {
+		return errors.New("请求已过期")
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return errors.New("签名格式错误")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errors.New("签名校验失败")
+	}
+	return nil
+}
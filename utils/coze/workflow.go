@@ -2,6 +2,7 @@ package coze
 
 import (
 	"context"
+	"coze-agent-platform/providers"
 	"errors"
 	"fmt"
 	"io"
@@ -10,96 +11,195 @@ import (
 	"github.com/coze-dev/coze-go"
 )
 
+// resolveWorkflowID 返回实际使用的工作流ID，未指定时回退到全局配置
+func (workflow *Client) resolveWorkflowID(workflowID string) string {
+	if workflowID != "" {
+		return workflowID
+	}
+	return workflow.Config.WorkFlowID
+}
 
-func (workflow *Client) RunWorkflow(message string) (*coze.RunWorkflowsResp, error) {
+// RunWorkflow 运行工作流，parameters为已校验并注入可信参数后的工作流参数
+// 异步运行时立即返回execute_id，运行结果需通过RetrieveWorkflowRun查询
+func (workflow *Client) RunWorkflow(workflowID string, parameters map[string]interface{}, isAsync bool) (*coze.RunWorkflowsResp, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
 	defer cancel()
-	workflow_param := map[string]interface{}{
-		"ip": 	 "180.172.177.97",
-		"token": "",
-		"input": message,
-	}
 	workflowReq := &coze.RunWorkflowsReq{
-		WorkflowID:  workflow.Config.WorkFlowID,
-		Parameters: workflow_param,
-		IsAsync: false,
+		WorkflowID: workflow.resolveWorkflowID(workflowID),
+		Parameters: parameters,
+		IsAsync:    isAsync,
 	}
 
-	resp, err := workflow.Api.Workflows.Runs.Create(ctx, workflowReq)
+	var resp *coze.RunWorkflowsResp
+	err := workflow.call(ctx, "workflows.runs.create", false, func() (err error) {
+		resp, err = workflow.Api.Workflows.Runs.Create(ctx, workflowReq)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("发送消息失败: %v", err)
+		return nil, fmt.Errorf("发送消息失败: %w", err)
 	}
 
 	return resp, nil
 }
 
-func (workflow *Client) RunWorkflowStream(message string, onMessage func(eventType string, data interface{})) error {
+// RunWorkflowStream 流式运行工作流，parameters为已校验并注入可信参数后的工作流参数
+func (workflow *Client) RunWorkflowStream(workflowID string, parameters map[string]interface{}, onEvent providers.EventHandler) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
 	defer cancel()
-	workflow_param := map[string]interface{}{
-		"ip": 	 "180.172.177.97",
-		"token": "",
-		"input": message,
-	}
 	workflowReq := &coze.RunWorkflowsReq{
-		WorkflowID:  workflow.Config.WorkFlowID,
-		Parameters: workflow_param,
-		IsAsync: false,
+		WorkflowID: workflow.resolveWorkflowID(workflowID),
+		Parameters: parameters,
+		IsAsync:    false,
+	}
+
+	var resp coze.Stream[coze.WorkflowEvent]
+	err := workflow.call(ctx, "workflows.runs.stream", true, func() (err error) {
+		resp, err = workflow.Api.Workflows.Runs.Stream(ctx, workflowReq)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("发送消息失败: %w", err)
+	}
+
+	return handleWorkflowStream(resp, onEvent)
+}
+
+// RetrieveWorkflowRun 查询工作流运行记录
+func (workflow *Client) RetrieveWorkflowRun(workflowID string, executeID string) (*coze.WorkflowRunHistory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var resp *coze.RetrieveWorkflowRunsHistoriesResp
+	err := workflow.call(ctx, "workflows.runs.histories.retrieve", true, func() (err error) {
+		resp, err = workflow.Api.Workflows.Runs.Histories.Retrieve(ctx, &coze.RetrieveWorkflowsRunsHistoriesReq{
+			WorkflowID: workflowID,
+			ExecuteID:  executeID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询工作流运行记录失败: %w", err)
 	}
+	if len(resp.Histories) == 0 {
+		return nil, fmt.Errorf("工作流运行记录不存在")
+	}
+
+	return resp.Histories[0], nil
+}
+
+// ResumeWorkflowStream 恢复被中断的工作流并继续流式返回事件
+func (workflow *Client) ResumeWorkflowStream(workflowID string, eventID string, resumeData string, interruptType int, onEvent providers.EventHandler) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
 
-	resp, err := workflow.Api.Workflows.Runs.Stream(ctx, workflowReq)
+	var resp coze.Stream[coze.WorkflowEvent]
+	err := workflow.call(ctx, "workflows.runs.resume", true, func() (err error) {
+		resp, err = workflow.Api.Workflows.Runs.Resume(ctx, &coze.ResumeRunWorkflowsReq{
+			WorkflowID:    workflowID,
+			EventID:       eventID,
+			ResumeData:    resumeData,
+			InterruptType: interruptType,
+		})
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("发送消息失败: %v", err)
+		return fmt.Errorf("恢复工作流失败: %w", err)
+	}
+
+	return handleWorkflowStream(resp, onEvent)
+}
+
+// ChatflowStream 通过对话流接口流式对话，附带对话历史及Coze对话ID，事件与Bot对话相同
+func (workflow *Client) ChatflowStream(ctx context.Context, chatReq *providers.ChatRequest, onEvent providers.EventHandler) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*2)
+	defer cancel()
+
+	req := &coze.WorkflowsChatStreamReq{
+		WorkflowID:         chatReq.WorkflowID,
+		AdditionalMessages: buildCozeMessages(chatReq.Messages),
+		Ext:                chatReq.MetaData,
+	}
+	if chatReq.ConversationID != "" {
+		req.ConversationID = &chatReq.ConversationID
+	}
+	if botID := workflow.resolveBotID(chatReq.BotID); botID != "" {
+		req.BotID = &botID
+	}
+	if len(chatReq.CustomVariables) > 0 {
+		req.Parameters = make(map[string]any, len(chatReq.CustomVariables))
+		for key, value := range chatReq.CustomVariables {
+			req.Parameters[key] = value
+		}
 	}
 
-	handleWorkflowStream(resp, onMessage)
+	var resp coze.Stream[coze.ChatEvent]
+	err := workflow.call(ctx, "workflows.chat.stream", true, func() (err error) {
+		resp, err = workflow.Api.Workflows.Chat.Stream(ctx, req)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("创建对话流失败: %w", err)
+	}
 
-	return  nil
+	return workflow.handleChatStream(ctx, resp, onEvent)
 }
 
-func handleWorkflowStream(resp coze.Stream[coze.WorkflowEvent], onMessage func(eventType string, data interface{})){
+// handleWorkflowStream 读取工作流流式事件并通过回调函数处理，读取失败时返回错误，由调用方将运行记录标记为失败
+func handleWorkflowStream(resp coze.Stream[coze.WorkflowEvent], onEvent providers.EventHandler) error {
 	defer resp.Close()
+	// 中断前最后一条消息通常是问答节点向用户提出的问题
+	var lastMessage string
 	for {
 		event, err := resp.Recv()
 		if errors.Is(err, io.EOF) {
 			// 流式结束
-			onMessage("workflow_end", map[string]string{
-				"status": "completed",
-				"log_id": resp.Response().LogID(),
-			})
-			break
+			onEvent(providers.Event{Type: providers.EventWorkflowEnd, Data: providers.WorkflowMessage{
+				Status: "completed",
+				LogID:  resp.Response().LogID(),
+			}})
+			return nil
 		}
 		if err != nil {
-			fmt.Printf("工作流接收事件失败: %v\n", err)
-			return
+			return fmt.Errorf("工作流接收事件失败: %w", classifyError(err))
 		}
 
 		switch event.Event {
 		case coze.WorkflowEventTypeMessage:
 			// 流式增量
-			onMessage("message_delta", map[string]string{
-				"status": "delta",
-				"log_id": resp.Response().LogID(),
-				"content": event.Message.Content,
-			})
-			fmt.Printf("步骤开始: %s\n", event.Message.Content)
+			onEvent(providers.Event{Type: providers.EventMessageDelta, Data: providers.WorkflowMessage{
+				Status:  "delta",
+				LogID:   resp.Response().LogID(),
+				Content: event.Message.Content,
+			}})
+			lastMessage = event.Message.Content
 		case coze.WorkflowEventTypeError:
-			onMessage("workflow_error", map[string]string{
-				"status": "error",
-				"log_id": resp.Response().LogID(),
-				"content": event.Error.ErrorMessage,
-			})
-			fmt.Printf("步骤结束\n",)
+			onEvent(providers.Event{Type: providers.EventWorkflowError, Data: providers.WorkflowMessage{
+				Status:  "error",
+				LogID:   resp.Response().LogID(),
+				Content: event.Error.ErrorMessage,
+			}})
 		case coze.WorkflowEventTypeDone:
-			onMessage("workflow_complated", map[string]string{
-				"status": "completed",
-				"log_id": resp.Response().LogID(),
-				"content": "",
-			})
-			fmt.Println("流式响应结束")
-			return
+			onEvent(providers.Event{Type: providers.EventWorkflowCompleted, Data: providers.WorkflowMessage{
+				Status: "completed",
+				LogID:  resp.Response().LogID(),
+			}})
+			return nil
+		case coze.WorkflowEventTypeInterrupt:
+			// 工作流中断，等待用户输入后通过ResumeWorkflowStream恢复
+			interrupt := providers.WorkflowInterrupt{
+				Status:    "interrupted",
+				LogID:     resp.Response().LogID(),
+				NodeTitle: event.Interrupt.NodeTitle,
+				Prompt:    lastMessage,
+			}
+			if event.Interrupt.InterruptData != nil {
+				interrupt.EventID = event.Interrupt.InterruptData.EventID
+				interrupt.InterruptType = event.Interrupt.InterruptData.Type
+			}
+			onEvent(providers.Event{Type: providers.EventWorkflowInterrupt, Data: interrupt})
+			return nil
 		default:
-			fmt.Printf("未知事件: %v\n", event)
+			// 心跳等其他事件，忽略
 		}
 	}
 }
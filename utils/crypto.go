@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+)
+
+// credentialKey 返回加密凭证使用的AES-256密钥，由环境变量 CREDENTIAL_ENCRYPTION_KEY 派生
+func credentialKey() ([]byte, error) {
+	secret := os.Getenv("CREDENTIAL_ENCRYPTION_KEY")
+	if secret == "" {
+		return nil, errors.New("未配置凭证加密密钥 CREDENTIAL_ENCRYPTION_KEY")
+	}
+	key := sha256.Sum256([]byte(secret))
+	return key[:], nil
+}
+
+func credentialCipher() (cipher.AEAD, error) {
+	key, err := credentialKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptCredential 使用AES-GCM加密凭证，返回 base64(nonce + 密文)
+func EncryptCredential(plaintext string) (string, error) {
+	gcm, err := credentialCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptCredential 解密 EncryptCredential 加密的凭证
+func DecryptCredential(ciphertext string) (string, error) {
+	gcm, err := credentialCipher()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", errors.New("凭证格式错误")
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("凭证格式错误")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("凭证解密失败，请检查加密密钥")
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind 上游接口错误分类
+type ErrorKind string
+
+const (
+	ErrorKindAuth        ErrorKind = "auth"                 // 服务端凭证无效或无权限
+	ErrorKindRateLimited ErrorKind = "rate_limited"         // 请求过于频繁
+	ErrorKindQuota       ErrorKind = "quota"                // 额度或余额不足
+	ErrorKindBadRequest  ErrorKind = "bad_request"          // 请求参数有误
+	ErrorKindUnavailable ErrorKind = "upstream_unavailable" // 网络异常、超时、上游服务错误或熔断中
+)
+
+// UpstreamError 调用Coze等上游接口失败时的错误，Code和LogID为上游返回的错误码和日志ID
+type UpstreamError struct {
+	Kind    ErrorKind
+	Code    int
+	LogID   string
+	Message string
+	Err     error
+}
+
+func (e *UpstreamError) Error() string {
+	msg := e.Message
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Code != 0 {
+		msg = fmt.Sprintf("%s (code=%d)", msg, e.Code)
+	}
+	if e.LogID != "" {
+		msg = fmt.Sprintf("%s (log_id=%s)", msg, e.LogID)
+	}
+	return msg
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable 限流和上游不可用的错误可以重试
+func (e *UpstreamError) Retryable() bool {
+	return e.Kind == ErrorKindRateLimited || e.Kind == ErrorKindUnavailable
+}
+
+// HTTPStatus 错误对应的HTTP状态码
+func (e *UpstreamError) HTTPStatus() int {
+	switch e.Kind {
+	case ErrorKindRateLimited, ErrorKindQuota:
+		return http.StatusTooManyRequests
+	case ErrorKindAuth:
+		return http.StatusBadGateway
+	case ErrorKindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// AsUpstreamError 判断错误链中是否包含上游接口错误
+func AsUpstreamError(err error) (*UpstreamError, bool) {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ValidateJSONSchema 使用JSON Schema校验数据
+// 仅支持常用关键字：type、properties、required、additionalProperties、enum、items
+func ValidateJSONSchema(schema json.RawMessage, value interface{}) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return fmt.Errorf("JSON Schema格式错误: %v", err)
+	}
+
+	// 统一转换为encoding/json解码后的类型，便于按类型判断
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("参数序列化失败: %v", err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return fmt.Errorf("参数解析失败: %v", err)
+	}
+
+	return validateSchemaNode(parsed, normalized, "")
+}
+
+func validateSchemaNode(schema map[string]interface{}, value interface{}, path string) error {
+	name := path
+	if name == "" {
+		name = "参数"
+	}
+
+	if schemaType, ok := schema["type"].(string); ok {
+		if !matchSchemaType(schemaType, value) {
+			return fmt.Errorf("%s 类型应为 %s", name, schemaType)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		matched := false
+		for _, item := range enum {
+			if fmt.Sprint(item) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s 取值不在允许范围内", name)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, key := range required {
+				field := fmt.Sprint(key)
+				if _, exists := v[field]; !exists {
+					return fmt.Errorf("缺少必填参数 %s", joinSchemaPath(path, field))
+				}
+			}
+		}
+		for field, fieldValue := range v {
+			fieldSchema, ok := properties[field].(map[string]interface{})
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("不支持的参数 %s", joinSchemaPath(path, field))
+				}
+				continue
+			}
+			if err := validateSchemaNode(fieldSchema, fieldValue, joinSchemaPath(path, field)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchemaNode(items, item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func matchSchemaType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == float64(int64(number))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func joinSchemaPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return strings.Join([]string{path, field}, ".")
+}
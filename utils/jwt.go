@@ -7,17 +7,25 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// 注入工作流参数的用户令牌只用于工作流回调，不能访问平台接口
+const (
+	WorkflowTokenAudience = "workflow_callback"
+	WorkflowTokenExpire   = 10 * time.Minute
+)
+
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	Role     int    `json:"role"` // 1:普通用户 2:管理员
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成JWT token
-func GenerateToken(userId uint, username string, secret string, expireTime int) (string, error) {
+func GenerateToken(userId uint, username string, role int, secret string, expireTime int) (string, error) {
 	claims := Claims{
 		UserID:   userId,
 		Username: username,
+		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expireTime) * time.Second)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -39,6 +47,42 @@ func ParseToken(tokenString string, secret string) (*Claims, error) {
 		return nil, err
 	}
 
+	// 指定了受众的令牌不是登录令牌
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && len(claims.Audience) == 0 {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// GenerateWorkflowToken 生成注入工作流参数的短期令牌，只包含用户ID。
+// 工作流运行记录和调试日志对工作流作者可见，不能注入登录令牌
+func GenerateWorkflowToken(userId uint, secret string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID: userId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{WorkflowTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(WorkflowTokenExpire)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// ParseWorkflowToken 校验工作流回调携带的令牌
+func ParseWorkflowToken(tokenString string, secret string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithAudience(WorkflowTokenAudience), jwt.WithExpirationRequired())
+
+	if err != nil {
+		return nil, err
+	}
+
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 		return claims, nil
 	}
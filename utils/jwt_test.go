@@ -0,0 +1,39 @@
+package utils
+
+import "testing"
+
+const testSecret = "test_secret"
+
+func TestWorkflowTokenCannotAuthenticate(t *testing.T) {
+	token, err := GenerateWorkflowToken(7, testSecret)
+	if err != nil {
+		t.Fatalf("GenerateWorkflowToken() = %v", err)
+	}
+
+	claims, err := ParseWorkflowToken(token, testSecret)
+	if err != nil {
+		t.Fatalf("ParseWorkflowToken() = %v", err)
+	}
+	if claims.UserID != 7 {
+		t.Errorf("UserID = %d, want 7", claims.UserID)
+	}
+
+	// 工作流令牌不能当作登录令牌使用
+	if _, err := ParseToken(token, testSecret); err == nil {
+		t.Error("ParseToken() 接受了工作流令牌")
+	}
+}
+
+func TestSessionTokenIsNotWorkflowToken(t *testing.T) {
+	token, err := GenerateToken(7, "alice", 1, testSecret, 3600)
+	if err != nil {
+		t.Fatalf("GenerateToken() = %v", err)
+	}
+
+	if _, err := ParseToken(token, testSecret); err != nil {
+		t.Fatalf("ParseToken() = %v", err)
+	}
+	if _, err := ParseWorkflowToken(token, testSecret); err == nil {
+		t.Error("ParseWorkflowToken() 接受了登录令牌")
+	}
+}
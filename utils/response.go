@@ -12,6 +12,11 @@ type Response struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+
+	// 上游接口错误的分类、错误码和日志ID，便于排查
+	ErrorKind    ErrorKind `json:"error_kind,omitempty"`
+	UpstreamCode int       `json:"upstream_code,omitempty"`
+	LogID        string    `json:"log_id,omitempty"`
 }
 
 type PageResponse struct {
@@ -23,6 +28,16 @@ type PageResponse struct {
 	Size    int         `json:"size"`
 }
 
+// CursorResponse 游标分页响应，向前翻页时以 first_id 作为 before，向后翻页时以 last_id 作为 after
+type CursorResponse struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+	HasMore bool        `json:"has_more"`
+	FirstID uint        `json:"first_id"`
+	LastID  uint        `json:"last_id"`
+}
+
 // Success 成功响应
 func Success(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, Response{
@@ -49,6 +64,24 @@ func Error(c *gin.Context, code int, message string) {
 	})
 }
 
+// ErrorWithCause 根据错误类型返回对应状态码：上游接口错误按分类映射为429、502、503等，其他错误返回400
+func ErrorWithCause(c *gin.Context, message string, err error) {
+	upstreamErr, ok := AsUpstreamError(err)
+	if !ok {
+		BadRequest(c, message+err.Error())
+		return
+	}
+
+	status := upstreamErr.HTTPStatus()
+	c.JSON(status, Response{
+		Code:         status,
+		Message:      message + err.Error(),
+		ErrorKind:    upstreamErr.Kind,
+		UpstreamCode: upstreamErr.Code,
+		LogID:        upstreamErr.LogID,
+	})
+}
+
 // BadRequest 400错误
 func BadRequest(c *gin.Context, message string) {
 	Error(c, http.StatusBadRequest, message)
@@ -59,6 +92,11 @@ func Unauthorized(c *gin.Context, message string) {
 	Error(c, http.StatusUnauthorized, message)
 }
 
+// Forbidden 403错误
+func Forbidden(c *gin.Context, message string) {
+	Error(c, http.StatusForbidden, message)
+}
+
 // NotFound 404错误
 func NotFound(c *gin.Context, message string) {
 	Error(c, http.StatusNotFound, message)
@@ -81,6 +119,18 @@ func PageSuccess(c *gin.Context, data interface{}, total int64, page, size int)
 	})
 }
 
+// CursorSuccess 游标分页成功响应
+func CursorSuccess(c *gin.Context, data interface{}, hasMore bool, firstId, lastId uint) {
+	c.JSON(http.StatusOK, CursorResponse{
+		Code:    200,
+		Message: "success",
+		Data:    data,
+		HasMore: hasMore,
+		FirstID: firstId,
+		LastID:  lastId,
+	})
+}
+
 // GenerateSnowflakeId 生成简单的唯一ID
 func GenerateSnowflakeId() int64 {
 	return time.Now().UnixNano() + rand.Int63n(1000)